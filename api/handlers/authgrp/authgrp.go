@@ -0,0 +1,158 @@
+// Package authgrp provides the http handlers for issuing tokens.
+package authgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/mid"
+	"github.com/hamidoujand/sales/internal/web"
+)
+
+type Handler struct {
+	UserBus  *userbus.UserBus
+	TokenBus *tokenbus.TokenBus
+	Auth     *auth.Auth
+	TokenTTL time.Duration
+}
+
+// Token exchanges the email and password of a user for a signed token.
+func (h *Handler) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var tr TokenRequest
+	if err := web.Decode(r, &tr); err != nil {
+		return err
+	}
+
+	email, err := mail.ParseAddress(tr.Email)
+	if err != nil {
+		return fmt.Errorf("parse email: %w", err)
+	}
+
+	usr, err := h.UserBus.Authenticate(ctx, *email, tr.Password)
+	if err != nil {
+		return fmt.Errorf("authenticate: %w", err)
+	}
+
+	tkn, err := h.issue(ctx, h.TokenBus, usr, uuid.Nil)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, tkn)
+}
+
+// Refresh swaps a refresh token for a new access token, the refresh token is rotated on every use.
+func (h *Handler) Refresh(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var rr RefreshRequest
+	if err := web.Decode(r, &rr); err != nil {
+		return err
+	}
+
+	//using the token and storing its replacement commit together.
+	bus, err := h.tokenBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	rt, err := bus.Use(ctx, rr.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("use refresh token: %w", err)
+	}
+
+	usr, err := h.UserBus.QueryByID(ctx, rt.UserID)
+	if err != nil {
+		//the token outlived its user, it is as good as a token that does not exist.
+		if errors.Is(err, userbus.ErrUserNotFound) {
+			return tokenbus.ErrInvalidToken
+		}
+		return fmt.Errorf("query user[%s]: %w", rt.UserID, err)
+	}
+
+	if !usr.Enabled {
+		return userbus.ErrUserDisabled
+	}
+
+	tkn, err := h.issue(ctx, bus, usr, rt.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, tkn)
+}
+
+// Revoke revokes the whole family of the given refresh token alongside the access tokens issued with it.
+func (h *Handler) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var rr RefreshRequest
+	if err := web.Decode(r, &rr); err != nil {
+		return err
+	}
+
+	if err := h.TokenBus.RevokeFamily(ctx, rr.RefreshToken); err != nil {
+		return fmt.Errorf("revoke refresh token: %w", err)
+	}
+
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
+
+// issue mints an access token for the user alongside a refresh token in the given family.
+func (h *Handler) issue(ctx context.Context, bus *tokenbus.TokenBus, usr userbus.User, familyID uuid.UUID) (Token, error) {
+	now := time.Now()
+	expiresAt := now.Add(h.TokenTTL)
+	jti := uuid.NewString()
+
+	claims := auth.Claims{
+		Roles: userbus.EncodeRoles(usr.Roles),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   usr.ID.String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := h.Auth.GenerateToken(claims)
+	if err != nil {
+		return Token{}, errs.Newf(http.StatusInternalServerError, "generate token: %s", err)
+	}
+
+	refreshToken, _, err := bus.Create(ctx, tokenbus.NewRefreshToken{
+		UserID:          usr.ID,
+		FamilyID:        familyID,
+		AccessJTI:       jti,
+		AccessExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return Token{}, errs.Newf(http.StatusInternalServerError, "create refresh token: %s", err)
+	}
+
+	return Token{
+		Token:        token,
+		ExpiresAt:    expiresAt.Format(time.RFC3339),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// tokenBus returns the bus bound to the transaction of the request when it runs within one.
+func (h *Handler) tokenBus(ctx context.Context) (*tokenbus.TokenBus, error) {
+	tx, ok := mid.GetTran(ctx)
+	if !ok {
+		return h.TokenBus, nil
+	}
+
+	bus, err := h.TokenBus.NewWithTx(tx)
+	if err != nil {
+		return nil, errs.Newf(http.StatusInternalServerError, "binding token bus to transaction: %s", err)
+	}
+
+	return bus, nil
+}
@@ -0,0 +1,225 @@
+package authgrp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/sales/api/handlers/authgrp"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus/tokendb"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+func TestToken(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "auth_token")
+
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: dbtest.NewKeyStore(t),
+		Issuer:    "auth-service",
+		ActiveKID: dbtest.KID,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+
+	active := createUser(ctx, t, bus, "active@gmail.com", true)
+	disabled := createUser(ctx, t, bus, "disabled@gmail.com", false)
+
+	h := authgrp.Handler{
+		UserBus:  bus,
+		TokenBus: tokenbus.New(tokendb.NewStore(database.Log, database.DB), time.Hour),
+		Auth:     authClient,
+		TokenTTL: time.Minute,
+	}
+
+	tests := map[string]struct {
+		req        authgrp.TokenRequest
+		statusCode int
+	}{
+		"valid_credentials": {
+			req:        authgrp.TokenRequest{Email: active.Email.Address, Password: "password"},
+			statusCode: http.StatusOK,
+		},
+		"wrong_password": {
+			req:        authgrp.TokenRequest{Email: active.Email.Address, Password: "wrong-password"},
+			statusCode: http.StatusUnauthorized,
+		},
+		"unknown_email": {
+			req:        authgrp.TokenRequest{Email: "unknown@gmail.com", Password: "password"},
+			statusCode: http.StatusUnauthorized,
+		},
+		"disabled_user": {
+			req:        authgrp.TokenRequest{Email: disabled.Email.Address, Password: "password"},
+			statusCode: http.StatusUnauthorized,
+		},
+		"invalid_request": {
+			req:        authgrp.TokenRequest{Email: "not-an-email"},
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var body bytes.Buffer
+			if err := json.NewEncoder(&body).Encode(test.req); err != nil {
+				t.Fatalf("encoding body: %s", err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/auth/token", &body)
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			err := h.Token(ctx, w, r)
+			if test.statusCode != http.StatusOK {
+				//business errors are left to the registry, mid.Error answers them.
+				var appErr *errs.Error
+				if !errors.As(err, &appErr) {
+					var ok bool
+					if appErr, ok = errs.Resolve(err); !ok {
+						t.Fatalf("expected the returned error to resolve to a status, got %v", err)
+					}
+				}
+
+				if appErr.Code != test.statusCode {
+					t.Errorf("status=%d, got %d", test.statusCode, appErr.Code)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to issue token: %s", err)
+			}
+
+			var tkn authgrp.Token
+			if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+				t.Fatalf("decoding token: %s", err)
+			}
+
+			claims, err := authClient.Authenticate(ctx, "Bearer "+tkn.Token)
+			if err != nil {
+				t.Fatalf("failed to authenticate issued token: %s", err)
+			}
+
+			if claims.Subject != active.ID.String() {
+				t.Errorf("subject=%s, got %s", active.ID, claims.Subject)
+			}
+
+			if len(claims.Roles) != 1 || claims.Roles[0] != userbus.RoleUser.String() {
+				t.Errorf("roles=%v, got %v", []string{userbus.RoleUser.String()}, claims.Roles)
+			}
+
+			if tkn.RefreshToken == "" {
+				t.Errorf("expected a refresh token to be issued")
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "auth_refresh")
+
+	tokenBus := tokenbus.New(tokendb.NewStore(database.Log, database.DB), time.Hour)
+	authClient, err := auth.New(auth.Config{
+		KeyLookup:   dbtest.NewKeyStore(t),
+		Issuer:      "auth-service",
+		ActiveKID:   dbtest.KID,
+		Revocations: tokenBus,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	usr := createUser(ctx, t, bus, "active@gmail.com", true)
+
+	h := authgrp.Handler{
+		UserBus:  bus,
+		TokenBus: tokenBus,
+		Auth:     authClient,
+		TokenTTL: time.Minute,
+	}
+
+	call := func(fn func(context.Context, http.ResponseWriter, *http.Request) error, body any) (authgrp.Token, error) {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encoding body: %s", err)
+		}
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/auth", &buf)
+		r.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		if err := fn(ctx, w, r); err != nil {
+			return authgrp.Token{}, err
+		}
+
+		var tkn authgrp.Token
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+				t.Fatalf("decoding token: %s", err)
+			}
+		}
+		return tkn, nil
+	}
+
+	first, err := call(h.Token, authgrp.TokenRequest{Email: usr.Email.Address, Password: "password"})
+	if err != nil {
+		t.Fatalf("failed to issue token: %s", err)
+	}
+
+	second, err := call(h.Refresh, authgrp.RefreshRequest{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("failed to refresh token: %s", err)
+	}
+
+	if second.RefreshToken == first.RefreshToken {
+		t.Errorf("expected refresh token to be rotated")
+	}
+
+	if _, err := authClient.Authenticate(ctx, "Bearer "+second.Token); err != nil {
+		t.Fatalf("failed to authenticate refreshed token: %s", err)
+	}
+
+	//reusing the first refresh token revokes the family, including the access token issued with it.
+	if _, err := call(h.Refresh, authgrp.RefreshRequest{RefreshToken: first.RefreshToken}); err == nil {
+		t.Fatal("expected reused refresh token to fail")
+	}
+
+	if _, err := authClient.Authenticate(ctx, "Bearer "+second.Token); !errors.Is(err, auth.ErrRevoked) {
+		t.Errorf("err=%v, got=%v", auth.ErrRevoked, err)
+	}
+
+	if _, err := call(h.Refresh, authgrp.RefreshRequest{RefreshToken: second.RefreshToken}); err == nil {
+		t.Error("expected refresh tokens of a revoked family to fail")
+	}
+}
+
+func createUser(ctx context.Context, t *testing.T, bus *userbus.UserBus, email string, enabled bool) userbus.User {
+	t.Helper()
+
+	usr := dbtest.CreateUser(ctx, t, bus, email, userbus.RoleUser)
+	if enabled {
+		return usr
+	}
+
+	usr, err := bus.Update(ctx, usr, userbus.UpdateUser{Enabled: &enabled})
+	if err != nil {
+		t.Fatalf("disabling user: %s", err)
+	}
+
+	return usr
+}
@@ -0,0 +1,49 @@
+package authgrp
+
+import (
+	"net/mail"
+
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+// TokenRequest represents the credentials a client exchanges for a token.
+type TokenRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Validate is called by web.Decode.
+func (tr TokenRequest) Validate() error {
+	fields := make(errs.FieldErrors)
+
+	if _, err := mail.ParseAddress(tr.Email); err != nil {
+		fields["email"] = "email is not a valid email address"
+	}
+
+	if tr.Password == "" {
+		fields["password"] = "password is required"
+	}
+
+	return fields.Err()
+}
+
+// RefreshRequest represents the refresh token a client wants to use or revoke.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Validate is called by web.Decode.
+func (rr RefreshRequest) Validate() error {
+	fields := make(errs.FieldErrors)
+	if rr.RefreshToken == "" {
+		fields["refreshToken"] = "refreshToken is required"
+	}
+	return fields.Err()
+}
+
+// Token represents the signed token returned to the client alongside the refresh token.
+type Token struct {
+	Token        string `json:"token"`
+	ExpiresAt    string `json:"expiresAt"`
+	RefreshToken string `json:"refreshToken"`
+}
@@ -0,0 +1,62 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+func TestRegisteredErrors(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		status int
+		code   string
+	}{
+		"user_not_found":        {err: userbus.ErrUserNotFound, status: http.StatusNotFound, code: "USER_NOT_FOUND"},
+		"duplicated_email":      {err: userbus.ErrDuplicatedEmail, status: http.StatusConflict, code: "DUPLICATED_EMAIL"},
+		"authentication_failed": {err: userbus.ErrAuthenticationFailure, status: http.StatusUnauthorized, code: "AUTHENTICATION_FAILED"},
+		"user_disabled":         {err: userbus.ErrUserDisabled, status: http.StatusUnauthorized, code: "USER_DISABLED"},
+		"password_too_short":    {err: userbus.ErrPasswordTooShort, status: http.StatusBadRequest, code: "PASSWORD_TOO_SHORT"},
+		"user_has_orders":       {err: userbus.ErrUserHasOrders, status: http.StatusConflict, code: "USER_HAS_ORDERS"},
+		"product_not_found":     {err: productbus.ErrProductNotFound, status: http.StatusNotFound, code: "PRODUCT_NOT_FOUND"},
+		"duplicated_sku":        {err: productbus.ErrDuplicatedSKU, status: http.StatusConflict, code: "DUPLICATED_SKU"},
+		"order_not_found":       {err: orderbus.ErrOrderNotFound, status: http.StatusNotFound, code: "ORDER_NOT_FOUND"},
+		"empty_order":           {err: orderbus.ErrEmptyOrder, status: http.StatusBadRequest, code: "EMPTY_ORDER"},
+		"unknown_product":       {err: orderbus.ErrUnknownProduct, status: http.StatusBadRequest, code: "UNKNOWN_PRODUCT"},
+		"insufficient_stock":    {err: orderbus.ErrInsufficientStock, status: http.StatusConflict, code: "INSUFFICIENT_STOCK"},
+		"invalid_transition":    {err: orderbus.ErrInvalidTransition, status: http.StatusConflict, code: "INVALID_STATUS_TRANSITION"},
+		"invalid_quantity":      {err: orderbus.ErrInvalidQuantity, status: http.StatusBadRequest, code: "INVALID_QUANTITY"},
+		"invalid_refresh_token": {err: tokenbus.ErrInvalidToken, status: http.StatusUnauthorized, code: "INVALID_REFRESH_TOKEN"},
+		"refresh_token_expired": {err: tokenbus.ErrTokenExpired, status: http.StatusUnauthorized, code: "REFRESH_TOKEN_EXPIRED"},
+		"refresh_token_reused":  {err: tokenbus.ErrTokenReused, status: http.StatusUnauthorized, code: "REFRESH_TOKEN_REUSED"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			spec, ok := errs.Lookup(test.err)
+			if !ok {
+				t.Fatalf("expected %q to be registered", test.err)
+			}
+
+			if spec.Status != test.status || spec.Code != test.code {
+				t.Errorf("expected %d %s, got %d %s", test.status, test.code, spec.Status, spec.Code)
+			}
+
+			//handlers wrap the errors of the business packages with context.
+			appErr, ok := errs.Resolve(fmt.Errorf("handling request: %w", test.err))
+			if !ok {
+				t.Fatalf("expected the wrapped %q to resolve", test.err)
+			}
+
+			if appErr.Code != test.status || appErr.AppCode != test.code || appErr.Message != spec.Message {
+				t.Errorf("expected the error to carry the spec, got %+v", appErr)
+			}
+		})
+	}
+}
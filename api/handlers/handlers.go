@@ -3,31 +3,127 @@ package handlers
 import (
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/hamidoujand/sales/api/handlers/authgrp"
 	"github.com/hamidoujand/sales/api/handlers/health"
+	"github.com/hamidoujand/sales/api/handlers/jwksgrp"
+	"github.com/hamidoujand/sales/api/handlers/ordergrp"
+	"github.com/hamidoujand/sales/api/handlers/productgrp"
+	"github.com/hamidoujand/sales/api/handlers/usergrp"
 	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/domain/orderbus/orderdb"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/domain/productbus/productdb"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
 	"github.com/hamidoujand/sales/internal/mid"
+	"github.com/hamidoujand/sales/internal/sqldb"
 	"github.com/hamidoujand/sales/internal/web"
 	"github.com/jmoiron/sqlx"
 )
 
-func APIMux(build string, logger *slog.Logger, db *sqlx.DB, authClient *auth.Auth) *web.Router {
+// Config represents all the dependencies required by the api handlers.
+type Config struct {
+	Build    string
+	Log      *slog.Logger
+	DB       *sqlx.DB
+	Auth     *auth.Auth
+	TokenBus *tokenbus.TokenBus
+	TokenTTL time.Duration
+	Keys     jwksgrp.PublicKeyStore
+}
+
+func APIMux(cfg Config) *web.Router {
 	const version = "v1"
-	mux := web.NewRouter(logger,
-		mid.Logger(logger),
-		mid.Error(logger),
+	mux := web.NewRouter(cfg.Log,
+		mid.Logger(cfg.Log),
+		mid.Error(cfg.Log),
 		mid.Metrics(),
 		mid.Panic(),
 	)
 
+	userBus := userbus.New(userdb.NewStore(cfg.Log, cfg.DB))
+	productBus := productbus.New(productdb.NewStore(cfg.Log, cfg.DB))
+	orderBus := orderbus.New(orderdb.NewStore(cfg.Log, cfg.DB))
+
 	//health handlers
 	hh := health.Handler{
-		DB:    db,
-		Build: build,
+		DB:    cfg.DB,
+		Build: cfg.Build,
 	}
 
 	mux.HandleFuncNoMid(http.MethodGet, version, "/readiness", hh.Readiness)
 	mux.HandleFuncNoMid(http.MethodGet, version, "/liveness", hh.Liveness)
 
+	//jwks handlers
+	jh := jwksgrp.Handler{
+		Keys: cfg.Keys,
+	}
+
+	mux.HandleFunc(http.MethodGet, "", "/.well-known/jwks.json", jh.JWKS)
+
+	//auth handlers
+	ah := authgrp.Handler{
+		UserBus:  userBus,
+		TokenBus: cfg.TokenBus,
+		Auth:     cfg.Auth,
+		TokenTTL: cfg.TokenTTL,
+	}
+
+	//changes commit only when the handler succeeds.
+	transaction := mid.BeginCommitRollback(cfg.Log, sqldb.NewBeginner(cfg.DB))
+
+	mux.HandleFunc(http.MethodPost, version, "/auth/token", ah.Token)
+	mux.HandleFunc(http.MethodPost, version, "/auth/refresh", ah.Refresh, transaction)
+	mux.HandleFunc(http.MethodPost, version, "/auth/revoke", ah.Revoke)
+
+	//user handlers
+	uh := usergrp.Handler{
+		UserBus: userBus,
+	}
+
+	authenticated := mid.Authenticate(cfg.Auth)
+	adminOnly := mid.Authorize(cfg.Auth, auth.RuleAdmin)
+	adminOrOwner := mid.Authorize(cfg.Auth, auth.RuleAdminOrOwner)
+
+	mux.HandleFunc(http.MethodPost, version, "/users", uh.Create, authenticated, adminOnly, transaction)
+	mux.HandleFunc(http.MethodGet, version, "/users", uh.Query, authenticated, adminOnly)
+	mux.HandleFunc(http.MethodGet, version, "/users/{user_id}", uh.QueryByID, authenticated, adminOrOwner)
+	mux.HandleFunc(http.MethodPut, version, "/users/{user_id}", uh.Update, authenticated, adminOrOwner, transaction)
+	mux.HandleFunc(http.MethodPut, version, "/users/role/{user_id}", uh.UpdateRole, authenticated, adminOnly, transaction)
+	mux.HandleFunc(http.MethodPut, version, "/users/status/{user_id}", uh.UpdateStatus, authenticated, adminOnly, transaction)
+	mux.HandleFunc(http.MethodDelete, version, "/users/{user_id}", uh.Delete, authenticated, adminOrOwner, transaction)
+
+	//product handlers, the catalog is readable by every user while changes are left to the
+	//owner of the product or an admin.
+	ph := productgrp.Handler{
+		ProductBus: productBus,
+	}
+
+	anybody := mid.Authorize(cfg.Auth, auth.RuleAnybody)
+	productOwner := mid.AuthorizeOwner(cfg.Auth, "product_id", ph.Owner)
+
+	mux.HandleFunc(http.MethodPost, version, "/products", ph.Create, authenticated, anybody, transaction)
+	mux.HandleFunc(http.MethodGet, version, "/products", ph.Query, authenticated, anybody)
+	mux.HandleFunc(http.MethodGet, version, "/products/{product_id}", ph.QueryByID, authenticated, anybody)
+	mux.HandleFunc(http.MethodPut, version, "/products/{product_id}", ph.Update, authenticated, productOwner, transaction)
+	mux.HandleFunc(http.MethodDelete, version, "/products/{product_id}", ph.Delete, authenticated, productOwner, transaction)
+
+	//order handlers, users see their own orders while admins see all of them.
+	oh := ordergrp.Handler{
+		OrderBus: orderBus,
+		Auth:     cfg.Auth,
+	}
+
+	orderOwner := mid.AuthorizeOwner(cfg.Auth, "order_id", oh.Owner)
+
+	mux.HandleFunc(http.MethodPost, version, "/orders", oh.Create, authenticated, anybody, transaction)
+	mux.HandleFunc(http.MethodGet, version, "/orders", oh.Query, authenticated, anybody)
+	mux.HandleFunc(http.MethodGet, version, "/orders/{order_id}", oh.QueryByID, authenticated, orderOwner)
+	mux.HandleFunc(http.MethodPut, version, "/orders/{order_id}/status", oh.UpdateStatus, authenticated, orderOwner, transaction)
+
 	return mux
 }
@@ -0,0 +1,26 @@
+// Package jwksgrp provides the http handler that publishes the public signing keys.
+package jwksgrp
+
+import (
+	"context"
+	"crypto"
+	"net/http"
+
+	"github.com/hamidoujand/sales/internal/web"
+	"github.com/hamidoujand/sales/pkg/jwks"
+)
+
+// PublicKeyStore defines the required behavior in order to list every public key.
+type PublicKeyStore interface {
+	PublicKeys() map[string]crypto.PublicKey
+}
+
+type Handler struct {
+	Keys PublicKeyStore
+}
+
+// JWKS publishes every public key in the store with its kid.
+func (h *Handler) JWKS(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	return web.Respond(ctx, w, http.StatusOK, jwks.NewSet(h.Keys.PublicKeys()))
+}
@@ -0,0 +1,93 @@
+package ordergrp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+// queryParams represents the set of query string params that can be used for listing orders.
+type queryParams struct {
+	Page             string
+	Rows             string
+	OrderBy          string
+	ID               string
+	UserID           string
+	Status           string
+	StartCreatedDate string
+	EndCreatedDate   string
+}
+
+func parseQueryParams(r *http.Request) queryParams {
+	values := r.URL.Query()
+
+	return queryParams{
+		Page:             values.Get("page"),
+		Rows:             values.Get("rows"),
+		OrderBy:          values.Get("orderBy"),
+		ID:               values.Get("order_id"),
+		UserID:           values.Get("user_id"),
+		Status:           values.Get("status"),
+		StartCreatedDate: values.Get("start_created_date"),
+		EndCreatedDate:   values.Get("end_created_date"),
+	}
+}
+
+func parseFilter(qp queryParams) (orderbus.QueryFilter, error) {
+	fields := make(map[string]string)
+	var filter orderbus.QueryFilter
+
+	if qp.ID != "" {
+		id, err := uuid.Parse(qp.ID)
+		if err != nil {
+			fields["order_id"] = "order_id is not a valid uuid"
+		} else {
+			filter.ID = &id
+		}
+	}
+
+	if qp.UserID != "" {
+		id, err := uuid.Parse(qp.UserID)
+		if err != nil {
+			fields["user_id"] = "user_id is not a valid uuid"
+		} else {
+			filter.UserID = &id
+		}
+	}
+
+	if qp.Status != "" {
+		status, err := orderbus.ParseStatus(qp.Status)
+		if err != nil {
+			fields["status"] = err.Error()
+		} else {
+			filter.Status = &status
+		}
+	}
+
+	if qp.StartCreatedDate != "" {
+		t, err := time.Parse(time.RFC3339, qp.StartCreatedDate)
+		if err != nil {
+			fields["start_created_date"] = "start_created_date must be in RFC3339 format"
+		} else {
+			filter.StartCreatedAt = &t
+		}
+	}
+
+	if qp.EndCreatedDate != "" {
+		t, err := time.Parse(time.RFC3339, qp.EndCreatedDate)
+		if err != nil {
+			fields["end_created_date"] = "end_created_date must be in RFC3339 format"
+		} else {
+			filter.EndCreatedAt = &t
+		}
+	}
+
+	if len(fields) > 0 {
+		return orderbus.QueryFilter{}, errs.NewValidation(http.StatusBadRequest, fields, "invalid filter")
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,140 @@
+package ordergrp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+// Order represents the order that is returned to the client, costs are in minor units.
+type Order struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userID"`
+	Status      string `json:"status"`
+	Items       []Item `json:"items"`
+	Total       int64  `json:"total"`
+	DateCreated string `json:"dateCreated"`
+	DateUpdated string `json:"dateUpdated"`
+}
+
+// Item represents a line of an order, productID is empty once the product is deleted.
+type Item struct {
+	ProductID string `json:"productID"`
+	Quantity  int    `json:"quantity"`
+	Cost      int64  `json:"cost"`
+}
+
+func toAppOrder(ord orderbus.Order) Order {
+	items := make([]Item, len(ord.Items))
+	for i, item := range ord.Items {
+		var productID string
+		if item.ProductID != uuid.Nil {
+			productID = item.ProductID.String()
+		}
+
+		items[i] = Item{
+			ProductID: productID,
+			Quantity:  item.Quantity,
+			Cost:      item.Cost,
+		}
+	}
+
+	return Order{
+		ID:          ord.ID.String(),
+		UserID:      ord.UserID.String(),
+		Status:      ord.Status.String(),
+		Items:       items,
+		Total:       ord.Total,
+		DateCreated: ord.DateCreated.Format(time.RFC3339),
+		DateUpdated: ord.DateUpdated.Format(time.RFC3339),
+	}
+}
+
+func toAppOrders(orders []orderbus.Order) []Order {
+	app := make([]Order, len(orders))
+	for i, ord := range orders {
+		app[i] = toAppOrder(ord)
+	}
+	return app
+}
+
+//==============================================================================
+
+// NewOrder represents the data required to place an order, the caller becomes its owner.
+type NewOrder struct {
+	Items []NewItem `json:"items"`
+}
+
+type NewItem struct {
+	ProductID string `json:"productID"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Validate is called by web.Decode.
+func (no NewOrder) Validate() error {
+	fields := make(errs.FieldErrors)
+
+	if len(no.Items) == 0 {
+		fields["items"] = "at least one item is required"
+	}
+
+	for i, ni := range no.Items {
+		if _, err := uuid.Parse(ni.ProductID); err != nil {
+			fields[fmt.Sprintf("items[%d].productID", i)] = "productID is not a valid uuid"
+		}
+
+		if ni.Quantity <= 0 {
+			fields[fmt.Sprintf("items[%d].quantity", i)] = "quantity must be positive"
+		}
+	}
+
+	return fields.Err()
+}
+
+func toBusNewOrder(userID uuid.UUID, no NewOrder) (orderbus.NewOrder, error) {
+	items := make([]orderbus.NewItem, len(no.Items))
+	for i, ni := range no.Items {
+		productID, err := uuid.Parse(ni.ProductID)
+		if err != nil {
+			return orderbus.NewOrder{}, fmt.Errorf("parse items[%d].productID: %w", i, err)
+		}
+
+		items[i] = orderbus.NewItem{
+			ProductID: productID,
+			Quantity:  ni.Quantity,
+		}
+	}
+
+	return orderbus.NewOrder{
+		UserID: userID,
+		Items:  items,
+	}, nil
+}
+
+//==============================================================================
+
+// UpdateStatus represents the data required to move an order into another status.
+type UpdateStatus struct {
+	Status string `json:"status"`
+}
+
+// Validate is called by web.Decode.
+func (us UpdateStatus) Validate() error {
+	fields := make(errs.FieldErrors)
+	if _, err := orderbus.ParseStatus(us.Status); err != nil {
+		fields["status"] = err.Error()
+	}
+	return fields.Err()
+}
+
+func toBusStatus(us UpdateStatus) (orderbus.Status, error) {
+	status, err := orderbus.ParseStatus(us.Status)
+	if err != nil {
+		return orderbus.Status{}, fmt.Errorf("parse status: %w", err)
+	}
+
+	return status, nil
+}
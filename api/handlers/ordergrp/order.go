@@ -0,0 +1,12 @@
+package ordergrp
+
+import "github.com/hamidoujand/sales/internal/domain/orderbus"
+
+// orderByFields maps the fields that clients can order by into business order fields.
+var orderByFields = map[string]string{
+	"order_id":     orderbus.OrderByID,
+	"user_id":      orderbus.OrderByUserID,
+	"status":       orderbus.OrderByStatus,
+	"total":        orderbus.OrderByTotal,
+	"date_created": orderbus.OrderByDateCreated,
+}
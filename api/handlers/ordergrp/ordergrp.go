@@ -0,0 +1,188 @@
+// Package ordergrp provides the http handlers for placing and managing orders.
+package ordergrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/mid"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/web"
+)
+
+type Handler struct {
+	OrderBus *orderbus.OrderBus
+	Auth     *auth.Auth
+}
+
+func (h *Handler) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var no NewOrder
+	if err := web.Decode(r, &no); err != nil {
+		return err
+	}
+
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
+	}
+
+	busNewOrder, err := toBusNewOrder(userID, no)
+	if err != nil {
+		return err
+	}
+
+	bus, err := h.orderBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	ord, err := bus.Create(ctx, busNewOrder)
+	if err != nil {
+		return fmt.Errorf("create order: %w", err)
+	}
+
+	return web.Respond(ctx, w, http.StatusCreated, toAppOrder(ord))
+}
+
+// UpdateStatus expects the caller to be authorized by mid.AuthorizeOwner. Owners can only cancel
+// their pending orders, the other transitions are left to admins.
+func (h *Handler) UpdateStatus(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var us UpdateStatus
+	if err := web.Decode(r, &us); err != nil {
+		return err
+	}
+
+	status, err := toBusStatus(us)
+	if err != nil {
+		return err
+	}
+
+	bus, err := h.orderBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	ord, err := queryOrder(ctx, bus, r)
+	if err != nil {
+		return err
+	}
+
+	//once an order is paid, cancelling it means a refund which only admins can issue.
+	cancelPending := status.Equal(orderbus.StatusCancelled) && ord.Status.Equal(orderbus.StatusPending)
+	if !cancelPending && !h.isAdmin(ctx) {
+		return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
+	}
+
+	updated, err := bus.UpdateStatus(ctx, ord, status)
+	if err != nil {
+		return fmt.Errorf("update order status[%s] to %s: %w", ord.ID, status, err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, toAppOrder(updated))
+}
+
+// QueryByID expects the caller to be authorized by mid.AuthorizeOwner.
+func (h *Handler) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ord, err := queryOrder(ctx, h.OrderBus, r)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, toAppOrder(ord))
+}
+
+// Query lists every order for admins, other users only see their own orders.
+func (h *Handler) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	qp := parseQueryParams(r)
+
+	pg, err := page.Parse(qp.Page, qp.Rows)
+	if err != nil {
+		return errs.NewValidation(http.StatusBadRequest, map[string]string{"page": err.Error()}, "invalid paging")
+	}
+
+	filter, err := parseFilter(qp)
+	if err != nil {
+		return err
+	}
+
+	if !h.isAdmin(ctx) {
+		userID, err := auth.GetUserID(ctx)
+		if err != nil {
+			return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
+		}
+		filter.UserID = &userID
+	}
+
+	orderBy, err := order.Parse(orderByFields, qp.OrderBy, orderbus.DefaultOrderBy)
+	if err != nil {
+		return errs.NewValidation(http.StatusBadRequest, map[string]string{"orderBy": err.Error()}, "invalid order")
+	}
+
+	orders, err := h.OrderBus.Query(ctx, filter, orderBy, pg)
+	if err != nil {
+		return fmt.Errorf("query orders: %w", err)
+	}
+
+	total, err := h.OrderBus.Count(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("count orders: %w", err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, page.NewDocument(toAppOrders(orders), total, pg))
+}
+
+// Owner returns the user that placed the order, it is the lookup of mid.AuthorizeOwner.
+func (h *Handler) Owner(ctx context.Context, orderID uuid.UUID) (uuid.UUID, error) {
+	ord, err := h.OrderBus.QueryByID(ctx, orderID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return ord.UserID, nil
+}
+
+// queryOrder loads the order referenced by the "order_id" path param.
+func queryOrder(ctx context.Context, bus *orderbus.OrderBus, r *http.Request) (orderbus.Order, error) {
+	orderID, err := uuid.Parse(r.PathValue("order_id"))
+	if err != nil {
+		return orderbus.Order{}, errs.Newf(http.StatusBadRequest, "invalid order id: %q", r.PathValue("order_id"))
+	}
+
+	ord, err := bus.QueryByID(ctx, orderID)
+	if err != nil {
+		return orderbus.Order{}, fmt.Errorf("query order[%s]: %w", orderID, err)
+	}
+
+	return ord, nil
+}
+
+// isAdmin checks the claims in the ctx against the admin rule.
+func (h *Handler) isAdmin(ctx context.Context) bool {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return false
+	}
+
+	return h.Auth.Authorize(ctx, claims, claims.Subject, auth.RuleAdmin) == nil
+}
+
+// orderBus returns the bus bound to the transaction of the request when it runs within one.
+func (h *Handler) orderBus(ctx context.Context) (*orderbus.OrderBus, error) {
+	tx, ok := mid.GetTran(ctx)
+	if !ok {
+		return h.OrderBus, nil
+	}
+
+	bus, err := h.OrderBus.NewWithTx(tx)
+	if err != nil {
+		return nil, errs.Newf(http.StatusInternalServerError, "binding order bus to transaction: %s", err)
+	}
+
+	return bus, nil
+}
@@ -0,0 +1,276 @@
+package ordergrp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/api/handlers"
+	"github.com/hamidoujand/sales/api/handlers/ordergrp"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/domain/orderbus/orderdb"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/domain/productbus/productdb"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/page"
+)
+
+func TestOrderAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "order_api")
+
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: dbtest.NewKeyStore(t),
+		Issuer:    "auth-service",
+		ActiveKID: dbtest.KID,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+	userBus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	orderBus := orderbus.New(orderdb.NewStore(database.Log, database.DB))
+
+	admin := dbtest.CreateUser(ctx, t, userBus, "admin@gmail.com", userbus.RoleAdmin)
+	buyer := dbtest.CreateUser(ctx, t, userBus, "buyer@gmail.com", userbus.RoleUser)
+	other := dbtest.CreateUser(ctx, t, userBus, "other@gmail.com", userbus.RoleUser)
+
+	adminToken := dbtest.GenerateToken(t, authClient, admin)
+	buyerToken := dbtest.GenerateToken(t, authClient, buyer)
+	otherToken := dbtest.GenerateToken(t, authClient, other)
+
+	plush, err := productBus.Create(ctx, productbus.NewProduct{
+		UserID:   admin.ID,
+		Name:     "Gopher Plush",
+		SKU:      "GPH-001",
+		Cost:     1999,
+		Quantity: 5,
+	})
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	buyerOrder := createOrder(ctx, t, orderBus, buyer.ID, plush.ID)
+	pendingOrder := createOrder(ctx, t, orderBus, buyer.ID, plush.ID)
+	createOrder(ctx, t, orderBus, other.ID, plush.ID)
+
+	mux := handlers.APIMux(handlers.Config{
+		Build: "test",
+		Log:   slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		DB:    database.DB,
+		Auth:  authClient,
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := map[string]struct {
+		method     string
+		path       string
+		token      string
+		body       any
+		statusCode int
+		total      int
+	}{
+		"buyer_places_order": {
+			method: http.MethodPost,
+			path:   "/v1/orders",
+			token:  buyerToken,
+			body: ordergrp.NewOrder{
+				Items: []ordergrp.NewItem{{ProductID: plush.ID.String(), Quantity: 1}},
+			},
+			statusCode: http.StatusCreated,
+		},
+		"insufficient_stock": {
+			method: http.MethodPost,
+			path:   "/v1/orders",
+			token:  buyerToken,
+			body: ordergrp.NewOrder{
+				Items: []ordergrp.NewItem{{ProductID: plush.ID.String(), Quantity: 10}},
+			},
+			statusCode: http.StatusConflict,
+		},
+		"unknown_product": {
+			method: http.MethodPost,
+			path:   "/v1/orders",
+			token:  buyerToken,
+			body: ordergrp.NewOrder{
+				Items: []ordergrp.NewItem{{ProductID: uuid.NewString(), Quantity: 1}},
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		"invalid_new_order": {
+			method:     http.MethodPost,
+			path:       "/v1/orders",
+			token:      buyerToken,
+			body:       ordergrp.NewOrder{Items: []ordergrp.NewItem{{ProductID: "not-a-uuid"}}},
+			statusCode: http.StatusBadRequest,
+		},
+		"buyer_queries_own_order": {
+			method:     http.MethodGet,
+			path:       "/v1/orders/" + buyerOrder.ID.String(),
+			token:      buyerToken,
+			statusCode: http.StatusOK,
+		},
+		"user_queries_someone_else_order": {
+			method:     http.MethodGet,
+			path:       "/v1/orders/" + buyerOrder.ID.String(),
+			token:      otherToken,
+			statusCode: http.StatusUnauthorized,
+		},
+		"buyer_can_not_mark_paid": {
+			method:     http.MethodPut,
+			path:       "/v1/orders/" + buyerOrder.ID.String() + "/status",
+			token:      buyerToken,
+			body:       ordergrp.UpdateStatus{Status: "PAID"},
+			statusCode: http.StatusUnauthorized,
+		},
+		"admin_marks_paid": {
+			method:     http.MethodPut,
+			path:       "/v1/orders/" + buyerOrder.ID.String() + "/status",
+			token:      adminToken,
+			body:       ordergrp.UpdateStatus{Status: "PAID"},
+			statusCode: http.StatusOK,
+		},
+		"invalid_transition": {
+			method:     http.MethodPut,
+			path:       "/v1/orders/" + buyerOrder.ID.String() + "/status",
+			token:      adminToken,
+			body:       ordergrp.UpdateStatus{Status: "PENDING"},
+			statusCode: http.StatusConflict,
+		},
+		"buyer_can_not_cancel_paid_order": {
+			method:     http.MethodPut,
+			path:       "/v1/orders/" + buyerOrder.ID.String() + "/status",
+			token:      buyerToken,
+			body:       ordergrp.UpdateStatus{Status: "CANCELLED"},
+			statusCode: http.StatusUnauthorized,
+		},
+		"buyer_cancels_pending_order": {
+			method:     http.MethodPut,
+			path:       "/v1/orders/" + pendingOrder.ID.String() + "/status",
+			token:      buyerToken,
+			body:       ordergrp.UpdateStatus{Status: "CANCELLED"},
+			statusCode: http.StatusOK,
+		},
+		"admin_cancels_paid_order": {
+			method:     http.MethodPut,
+			path:       "/v1/orders/" + buyerOrder.ID.String() + "/status",
+			token:      adminToken,
+			body:       ordergrp.UpdateStatus{Status: "CANCELLED"},
+			statusCode: http.StatusOK,
+		},
+		"buyer_lists_own_orders": {
+			method:     http.MethodGet,
+			path:       "/v1/orders?user_id=" + other.ID.String(),
+			token:      buyerToken,
+			statusCode: http.StatusOK,
+			total:      3,
+		},
+		"admin_lists_all_orders": {
+			method:     http.MethodGet,
+			path:       "/v1/orders?orderBy=total,DESC",
+			token:      adminToken,
+			statusCode: http.StatusOK,
+			total:      4,
+		},
+		"missing_token": {
+			method:     http.MethodGet,
+			path:       "/v1/orders",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, name := range []string{
+		"buyer_places_order", "insufficient_stock", "unknown_product", "invalid_new_order",
+		"buyer_queries_own_order", "user_queries_someone_else_order", "buyer_can_not_mark_paid",
+		"admin_marks_paid", "invalid_transition", "buyer_can_not_cancel_paid_order", "buyer_cancels_pending_order",
+		"admin_cancels_paid_order", "buyer_lists_own_orders", "admin_lists_all_orders", "missing_token",
+	} {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			var body bytes.Buffer
+			if test.body != nil {
+				if err := json.NewEncoder(&body).Encode(test.body); err != nil {
+					t.Fatalf("encoding body: %s", err)
+				}
+			}
+
+			req, err := http.NewRequest(test.method, server.URL+test.path, &body)
+			if err != nil {
+				t.Fatalf("creating request: %s", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("making the request: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.statusCode {
+				t.Fatalf("status=%d, got %d", test.statusCode, resp.StatusCode)
+			}
+
+			switch {
+			case name == "invalid_new_order":
+				var appErr errs.Error
+				if err := json.NewDecoder(resp.Body).Decode(&appErr); err != nil {
+					t.Fatalf("decoding error response: %s", err)
+				}
+
+				if len(appErr.Fields) == 0 {
+					t.Errorf("expected validation errors to have fields")
+				}
+
+			case name == "buyer_places_order":
+				var ord ordergrp.Order
+				if err := json.NewDecoder(resp.Body).Decode(&ord); err != nil {
+					t.Fatalf("decoding order: %s", err)
+				}
+
+				if ord.UserID != buyer.ID.String() || ord.Status != "PENDING" || ord.Total != plush.Cost {
+					t.Errorf("expected a pending order of the buyer, got %+v", ord)
+				}
+
+			case test.total != 0:
+				var doc page.Document[ordergrp.Order]
+				if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+					t.Fatalf("decoding document: %s", err)
+				}
+
+				if doc.Total != test.total || len(doc.Items) != test.total {
+					t.Errorf("total=%d, got %d with %d items", test.total, doc.Total, len(doc.Items))
+				}
+			}
+		})
+	}
+}
+
+func createOrder(ctx context.Context, t *testing.T, bus *orderbus.OrderBus, userID uuid.UUID, productID uuid.UUID) orderbus.Order {
+	t.Helper()
+
+	ord, err := bus.Create(ctx, orderbus.NewOrder{
+		UserID: userID,
+		Items:  []orderbus.NewItem{{ProductID: productID, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("creating order: %s", err)
+	}
+
+	return ord
+}
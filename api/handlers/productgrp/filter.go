@@ -0,0 +1,119 @@
+package productgrp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+// queryParams represents the set of query string params that can be used for listing products.
+type queryParams struct {
+	Page             string
+	Rows             string
+	OrderBy          string
+	ID               string
+	UserID           string
+	Name             string
+	SKU              string
+	MinCost          string
+	MaxCost          string
+	StartCreatedDate string
+	EndCreatedDate   string
+}
+
+func parseQueryParams(r *http.Request) queryParams {
+	values := r.URL.Query()
+
+	return queryParams{
+		Page:             values.Get("page"),
+		Rows:             values.Get("rows"),
+		OrderBy:          values.Get("orderBy"),
+		ID:               values.Get("product_id"),
+		UserID:           values.Get("user_id"),
+		Name:             values.Get("name"),
+		SKU:              values.Get("sku"),
+		MinCost:          values.Get("min_cost"),
+		MaxCost:          values.Get("max_cost"),
+		StartCreatedDate: values.Get("start_created_date"),
+		EndCreatedDate:   values.Get("end_created_date"),
+	}
+}
+
+func parseFilter(qp queryParams) (productbus.QueryFilter, error) {
+	fields := make(map[string]string)
+	var filter productbus.QueryFilter
+
+	if qp.ID != "" {
+		id, err := uuid.Parse(qp.ID)
+		if err != nil {
+			fields["product_id"] = "product_id is not a valid uuid"
+		} else {
+			filter.ID = &id
+		}
+	}
+
+	if qp.UserID != "" {
+		id, err := uuid.Parse(qp.UserID)
+		if err != nil {
+			fields["user_id"] = "user_id is not a valid uuid"
+		} else {
+			filter.UserID = &id
+		}
+	}
+
+	if qp.Name != "" {
+		name := qp.Name
+		filter.Name = &name
+	}
+
+	if qp.SKU != "" {
+		sku := qp.SKU
+		filter.SKU = &sku
+	}
+
+	if qp.MinCost != "" {
+		cost, err := strconv.ParseInt(qp.MinCost, 10, 64)
+		if err != nil {
+			fields["min_cost"] = "min_cost must be an integer in minor units"
+		} else {
+			filter.MinCost = &cost
+		}
+	}
+
+	if qp.MaxCost != "" {
+		cost, err := strconv.ParseInt(qp.MaxCost, 10, 64)
+		if err != nil {
+			fields["max_cost"] = "max_cost must be an integer in minor units"
+		} else {
+			filter.MaxCost = &cost
+		}
+	}
+
+	if qp.StartCreatedDate != "" {
+		t, err := time.Parse(time.RFC3339, qp.StartCreatedDate)
+		if err != nil {
+			fields["start_created_date"] = "start_created_date must be in RFC3339 format"
+		} else {
+			filter.StartCreatedAt = &t
+		}
+	}
+
+	if qp.EndCreatedDate != "" {
+		t, err := time.Parse(time.RFC3339, qp.EndCreatedDate)
+		if err != nil {
+			fields["end_created_date"] = "end_created_date must be in RFC3339 format"
+		} else {
+			filter.EndCreatedAt = &t
+		}
+	}
+
+	if len(fields) > 0 {
+		return productbus.QueryFilter{}, errs.NewValidation(http.StatusBadRequest, fields, "invalid filter")
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,127 @@
+package productgrp
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+// Product represents the product that is returned to the client, cost is in minor units.
+type Product struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userID"`
+	Name        string `json:"name"`
+	SKU         string `json:"sku"`
+	Cost        int64  `json:"cost"`
+	Quantity    int    `json:"quantity"`
+	DateCreated string `json:"dateCreated"`
+	DateUpdated string `json:"dateUpdated"`
+}
+
+func toAppProduct(prd productbus.Product) Product {
+	return Product{
+		ID:          prd.ID.String(),
+		UserID:      prd.UserID.String(),
+		Name:        prd.Name,
+		SKU:         prd.SKU,
+		Cost:        prd.Cost,
+		Quantity:    prd.Quantity,
+		DateCreated: prd.DateCreated.Format(time.RFC3339),
+		DateUpdated: prd.DateUpdated.Format(time.RFC3339),
+	}
+}
+
+func toAppProducts(products []productbus.Product) []Product {
+	app := make([]Product, len(products))
+	for i, prd := range products {
+		app[i] = toAppProduct(prd)
+	}
+	return app
+}
+
+//==============================================================================
+
+// NewProduct represents the data required to create a product, the caller becomes its owner.
+type NewProduct struct {
+	Name     string `json:"name"`
+	SKU      string `json:"sku"`
+	Cost     int64  `json:"cost"`
+	Quantity int    `json:"quantity"`
+}
+
+// Validate is called by web.Decode.
+func (np NewProduct) Validate() error {
+	fields := make(errs.FieldErrors)
+
+	if np.Name == "" {
+		fields["name"] = "name is required"
+	}
+
+	if np.SKU == "" {
+		fields["sku"] = "sku is required"
+	}
+
+	if np.Cost < 0 {
+		fields["cost"] = "cost can not be negative"
+	}
+
+	if np.Quantity < 0 {
+		fields["quantity"] = "quantity can not be negative"
+	}
+
+	return fields.Err()
+}
+
+func toBusNewProduct(userID uuid.UUID, np NewProduct) productbus.NewProduct {
+	return productbus.NewProduct{
+		UserID:   userID,
+		Name:     np.Name,
+		SKU:      np.SKU,
+		Cost:     np.Cost,
+		Quantity: np.Quantity,
+	}
+}
+
+//==============================================================================
+
+// UpdateProduct represents the data that can be updated by the owner, all fields are optional.
+type UpdateProduct struct {
+	Name     *string `json:"name"`
+	SKU      *string `json:"sku"`
+	Cost     *int64  `json:"cost"`
+	Quantity *int    `json:"quantity"`
+}
+
+// Validate is called by web.Decode.
+func (up UpdateProduct) Validate() error {
+	fields := make(errs.FieldErrors)
+
+	if up.Name != nil && *up.Name == "" {
+		fields["name"] = "name can not be empty"
+	}
+
+	if up.SKU != nil && *up.SKU == "" {
+		fields["sku"] = "sku can not be empty"
+	}
+
+	if up.Cost != nil && *up.Cost < 0 {
+		fields["cost"] = "cost can not be negative"
+	}
+
+	if up.Quantity != nil && *up.Quantity < 0 {
+		fields["quantity"] = "quantity can not be negative"
+	}
+
+	return fields.Err()
+}
+
+func toBusUpdateProduct(up UpdateProduct) productbus.UpdateProduct {
+	return productbus.UpdateProduct{
+		Name:     up.Name,
+		SKU:      up.SKU,
+		Cost:     up.Cost,
+		Quantity: up.Quantity,
+	}
+}
@@ -0,0 +1,13 @@
+package productgrp
+
+import "github.com/hamidoujand/sales/internal/domain/productbus"
+
+// orderByFields maps the fields that clients can order by into business order fields.
+var orderByFields = map[string]string{
+	"product_id": productbus.OrderByID,
+	"user_id":    productbus.OrderByUserID,
+	"name":       productbus.OrderByName,
+	"sku":        productbus.OrderBySKU,
+	"cost":       productbus.OrderByCost,
+	"quantity":   productbus.OrderByQuantity,
+}
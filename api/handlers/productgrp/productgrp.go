@@ -0,0 +1,178 @@
+// Package productgrp provides the http handlers for managing products.
+package productgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/mid"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/web"
+)
+
+type Handler struct {
+	ProductBus *productbus.ProductBus
+}
+
+func (h *Handler) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var np NewProduct
+	if err := web.Decode(r, &np); err != nil {
+		return err
+	}
+
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
+	}
+
+	busNewProduct := toBusNewProduct(userID, np)
+
+	bus, err := h.productBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	prd, err := bus.Create(ctx, busNewProduct)
+	if err != nil {
+		return fmt.Errorf("create product: %w", err)
+	}
+
+	return web.Respond(ctx, w, http.StatusCreated, toAppProduct(prd))
+}
+
+// Update expects the caller to be authorized by mid.AuthorizeOwner.
+func (h *Handler) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var up UpdateProduct
+	if err := web.Decode(r, &up); err != nil {
+		return err
+	}
+
+	busUpdateProduct := toBusUpdateProduct(up)
+
+	bus, err := h.productBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	prd, err := queryProduct(ctx, bus, r)
+	if err != nil {
+		return err
+	}
+
+	updated, err := bus.Update(ctx, prd, busUpdateProduct)
+	if err != nil {
+		return fmt.Errorf("update product[%s]: %w", prd.ID, err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, toAppProduct(updated))
+}
+
+// Delete expects the caller to be authorized by mid.AuthorizeOwner.
+func (h *Handler) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	bus, err := h.productBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	prd, err := queryProduct(ctx, bus, r)
+	if err != nil {
+		return err
+	}
+
+	if err := bus.Delete(ctx, prd); err != nil {
+		return fmt.Errorf("delete product[%s]: %w", prd.ID, err)
+	}
+
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
+
+func (h *Handler) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	productID, err := uuid.Parse(r.PathValue("product_id"))
+	if err != nil {
+		return errs.Newf(http.StatusBadRequest, "invalid product id: %q", r.PathValue("product_id"))
+	}
+
+	prd, err := h.ProductBus.QueryByID(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("query product[%s]: %w", productID, err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, toAppProduct(prd))
+}
+
+func (h *Handler) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	qp := parseQueryParams(r)
+
+	pg, err := page.Parse(qp.Page, qp.Rows)
+	if err != nil {
+		return errs.NewValidation(http.StatusBadRequest, map[string]string{"page": err.Error()}, "invalid paging")
+	}
+
+	filter, err := parseFilter(qp)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := order.Parse(orderByFields, qp.OrderBy, productbus.DefaultOrderBy)
+	if err != nil {
+		return errs.NewValidation(http.StatusBadRequest, map[string]string{"orderBy": err.Error()}, "invalid order")
+	}
+
+	products, err := h.ProductBus.Query(ctx, filter, orderBy, pg)
+	if err != nil {
+		return fmt.Errorf("query products: %w", err)
+	}
+
+	total, err := h.ProductBus.Count(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("count products: %w", err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, page.NewDocument(toAppProducts(products), total, pg))
+}
+
+// Owner returns the user that created the product, it is the lookup of mid.AuthorizeOwner.
+func (h *Handler) Owner(ctx context.Context, productID uuid.UUID) (uuid.UUID, error) {
+	prd, err := h.ProductBus.QueryByID(ctx, productID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return prd.UserID, nil
+}
+
+// queryProduct loads the product referenced by the "product_id" path param.
+func queryProduct(ctx context.Context, bus *productbus.ProductBus, r *http.Request) (productbus.Product, error) {
+	productID, err := uuid.Parse(r.PathValue("product_id"))
+	if err != nil {
+		return productbus.Product{}, errs.Newf(http.StatusBadRequest, "invalid product id: %q", r.PathValue("product_id"))
+	}
+
+	prd, err := bus.QueryByID(ctx, productID)
+	if err != nil {
+		return productbus.Product{}, fmt.Errorf("query product[%s]: %w", productID, err)
+	}
+
+	return prd, nil
+}
+
+// productBus returns the bus bound to the transaction of the request when it runs within one.
+func (h *Handler) productBus(ctx context.Context) (*productbus.ProductBus, error) {
+	tx, ok := mid.GetTran(ctx)
+	if !ok {
+		return h.ProductBus, nil
+	}
+
+	bus, err := h.ProductBus.NewWithTx(tx)
+	if err != nil {
+		return nil, errs.Newf(http.StatusInternalServerError, "binding product bus to transaction: %s", err)
+	}
+
+	return bus, nil
+}
@@ -0,0 +1,226 @@
+package productgrp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/sales/api/handlers"
+	"github.com/hamidoujand/sales/api/handlers/productgrp"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/domain/productbus/productdb"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/page"
+)
+
+func TestProductAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "product_api")
+
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: dbtest.NewKeyStore(t),
+		Issuer:    "auth-service",
+		ActiveKID: dbtest.KID,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+	userBus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+
+	admin := dbtest.CreateUser(ctx, t, userBus, "admin@gmail.com", userbus.RoleAdmin)
+	owner := dbtest.CreateUser(ctx, t, userBus, "owner@gmail.com", userbus.RoleUser)
+	other := dbtest.CreateUser(ctx, t, userBus, "other@gmail.com", userbus.RoleUser)
+
+	adminToken := dbtest.GenerateToken(t, authClient, admin)
+	ownerToken := dbtest.GenerateToken(t, authClient, owner)
+	otherToken := dbtest.GenerateToken(t, authClient, other)
+
+	plush := dbtest.CreateProduct(ctx, t, productBus, owner.ID, "GPH-001")
+	mug := dbtest.CreateProduct(ctx, t, productBus, owner.ID, "GPH-002")
+
+	mux := handlers.APIMux(handlers.Config{
+		Build: "test",
+		Log:   slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		DB:    database.DB,
+		Auth:  authClient,
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := map[string]struct {
+		method     string
+		path       string
+		token      string
+		body       any
+		statusCode int
+	}{
+		"user_creates_product": {
+			method:     http.MethodPost,
+			path:       "/v1/products",
+			token:      otherToken,
+			body:       productgrp.NewProduct{Name: "Gopher Cap", SKU: "GPH-003", Cost: 999, Quantity: 5},
+			statusCode: http.StatusCreated,
+		},
+		"duplicated_sku": {
+			method:     http.MethodPost,
+			path:       "/v1/products",
+			token:      otherToken,
+			body:       productgrp.NewProduct{Name: "Gopher Cap", SKU: plush.SKU, Cost: 999, Quantity: 5},
+			statusCode: http.StatusConflict,
+		},
+		"invalid_new_product": {
+			method:     http.MethodPost,
+			path:       "/v1/products",
+			token:      otherToken,
+			body:       productgrp.NewProduct{Cost: -1, Quantity: -1},
+			statusCode: http.StatusBadRequest,
+		},
+		"user_queries_product": {
+			method:     http.MethodGet,
+			path:       "/v1/products/" + plush.ID.String(),
+			token:      otherToken,
+			statusCode: http.StatusOK,
+		},
+		"owner_updates_product": {
+			method:     http.MethodPut,
+			path:       "/v1/products/" + plush.ID.String(),
+			token:      ownerToken,
+			body:       map[string]any{"cost": 2499},
+			statusCode: http.StatusOK,
+		},
+		"user_can_not_update_someone_else_product": {
+			method:     http.MethodPut,
+			path:       "/v1/products/" + plush.ID.String(),
+			token:      otherToken,
+			body:       map[string]any{"cost": 1},
+			statusCode: http.StatusUnauthorized,
+		},
+		"user_can_not_delete_someone_else_product": {
+			method:     http.MethodDelete,
+			path:       "/v1/products/" + mug.ID.String(),
+			token:      otherToken,
+			statusCode: http.StatusUnauthorized,
+		},
+		"admin_deletes_product": {
+			method:     http.MethodDelete,
+			path:       "/v1/products/" + mug.ID.String(),
+			token:      adminToken,
+			statusCode: http.StatusNoContent,
+		},
+		"product_not_found": {
+			method:     http.MethodPut,
+			path:       "/v1/products/" + "00000000-0000-0000-0000-000000000000",
+			token:      adminToken,
+			body:       map[string]any{"cost": 1},
+			statusCode: http.StatusNotFound,
+		},
+		"user_lists_products_of_owner": {
+			method:     http.MethodGet,
+			path:       "/v1/products?page=1&rows=10&orderBy=cost,DESC&user_id=" + owner.ID.String(),
+			token:      otherToken,
+			statusCode: http.StatusOK,
+		},
+		"invalid_filter": {
+			method:     http.MethodGet,
+			path:       "/v1/products?min_cost=cheap",
+			token:      otherToken,
+			statusCode: http.StatusBadRequest,
+		},
+		"missing_token": {
+			method:     http.MethodGet,
+			path:       "/v1/products",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, name := range []string{
+		"user_creates_product", "duplicated_sku", "invalid_new_product", "user_queries_product",
+		"owner_updates_product", "user_can_not_update_someone_else_product",
+		"user_can_not_delete_someone_else_product", "admin_deletes_product", "product_not_found",
+		"user_lists_products_of_owner", "invalid_filter", "missing_token",
+	} {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			var body bytes.Buffer
+			if test.body != nil {
+				if err := json.NewEncoder(&body).Encode(test.body); err != nil {
+					t.Fatalf("encoding body: %s", err)
+				}
+			}
+
+			req, err := http.NewRequest(test.method, server.URL+test.path, &body)
+			if err != nil {
+				t.Fatalf("creating request: %s", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("making the request: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.statusCode {
+				t.Fatalf("status=%d, got %d", test.statusCode, resp.StatusCode)
+			}
+
+			switch name {
+			case "invalid_new_product", "invalid_filter":
+				var appErr errs.Error
+				if err := json.NewDecoder(resp.Body).Decode(&appErr); err != nil {
+					t.Fatalf("decoding error response: %s", err)
+				}
+
+				if len(appErr.Fields) == 0 {
+					t.Errorf("expected validation errors to have fields")
+				}
+
+			case "user_creates_product":
+				var prd productgrp.Product
+				if err := json.NewDecoder(resp.Body).Decode(&prd); err != nil {
+					t.Fatalf("decoding product: %s", err)
+				}
+
+				if prd.UserID != other.ID.String() {
+					t.Errorf("userID=%s, got %s", other.ID, prd.UserID)
+				}
+
+			case "owner_updates_product":
+				var prd productgrp.Product
+				if err := json.NewDecoder(resp.Body).Decode(&prd); err != nil {
+					t.Fatalf("decoding product: %s", err)
+				}
+
+				if prd.Cost != 2499 || prd.Name != plush.Name {
+					t.Errorf("expected only the cost to change, got %s/%d", prd.Name, prd.Cost)
+				}
+
+			case "user_lists_products_of_owner":
+				var doc page.Document[productgrp.Product]
+				if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+					t.Fatalf("decoding document: %s", err)
+				}
+
+				//the mug is deleted by the admin.
+				if doc.Total != 1 || len(doc.Items) != 1 {
+					t.Errorf("total=%d, got %d with %d items", 1, doc.Total, len(doc.Items))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package usergrp
+
+import (
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+// queryParams represents the set of query string params that can be used for listing users.
+type queryParams struct {
+	Page             string
+	Rows             string
+	OrderBy          string
+	ID               string
+	Name             string
+	Email            string
+	StartCreatedDate string
+	EndCreatedDate   string
+}
+
+func parseQueryParams(r *http.Request) queryParams {
+	values := r.URL.Query()
+
+	return queryParams{
+		Page:             values.Get("page"),
+		Rows:             values.Get("rows"),
+		OrderBy:          values.Get("orderBy"),
+		ID:               values.Get("user_id"),
+		Name:             values.Get("name"),
+		Email:            values.Get("email"),
+		StartCreatedDate: values.Get("start_created_date"),
+		EndCreatedDate:   values.Get("end_created_date"),
+	}
+}
+
+func parseFilter(qp queryParams) (userbus.QueryFilter, error) {
+	fields := make(map[string]string)
+	var filter userbus.QueryFilter
+
+	if qp.ID != "" {
+		id, err := uuid.Parse(qp.ID)
+		if err != nil {
+			fields["user_id"] = "user_id is not a valid uuid"
+		} else {
+			filter.ID = &id
+		}
+	}
+
+	if qp.Name != "" {
+		name := qp.Name
+		filter.Name = &name
+	}
+
+	if qp.Email != "" {
+		email, err := mail.ParseAddress(qp.Email)
+		if err != nil {
+			fields["email"] = "email is not a valid email address"
+		} else {
+			filter.Email = email
+		}
+	}
+
+	if qp.StartCreatedDate != "" {
+		t, err := time.Parse(time.RFC3339, qp.StartCreatedDate)
+		if err != nil {
+			fields["start_created_date"] = "start_created_date must be in RFC3339 format"
+		} else {
+			filter.StartCreatedAt = &t
+		}
+	}
+
+	if qp.EndCreatedDate != "" {
+		t, err := time.Parse(time.RFC3339, qp.EndCreatedDate)
+		if err != nil {
+			fields["end_created_date"] = "end_created_date must be in RFC3339 format"
+		} else {
+			filter.EndCreatedAt = &t
+		}
+	}
+
+	if len(fields) > 0 {
+		return userbus.QueryFilter{}, errs.NewValidation(http.StatusBadRequest, fields, "invalid filter")
+	}
+
+	return filter, nil
+}
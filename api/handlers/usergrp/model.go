@@ -0,0 +1,208 @@
+package usergrp
+
+import (
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+// User represents the user that is returned to the client.
+type User struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles"`
+	Enabled     bool     `json:"enabled"`
+	DateCreated string   `json:"dateCreated"`
+	DateUpdated string   `json:"dateUpdated"`
+}
+
+func toAppUser(usr userbus.User) User {
+	return User{
+		ID:          usr.ID.String(),
+		Name:        usr.Name,
+		Email:       usr.Email.Address,
+		Roles:       userbus.EncodeRoles(usr.Roles),
+		Enabled:     usr.Enabled,
+		DateCreated: usr.DateCreated.Format(time.RFC3339),
+		DateUpdated: usr.DateUpdated.Format(time.RFC3339),
+	}
+}
+
+func toAppUsers(users []userbus.User) []User {
+	app := make([]User, len(users))
+	for i, usr := range users {
+		app[i] = toAppUser(usr)
+	}
+	return app
+}
+
+//==============================================================================
+
+// NewUser represents the data required to create a user.
+type NewUser struct {
+	Name            string   `json:"name"`
+	Email           string   `json:"email"`
+	Roles           []string `json:"roles"`
+	Password        string   `json:"password"`
+	PasswordConfirm string   `json:"passwordConfirm"`
+}
+
+// Validate is called by web.Decode.
+func (nu NewUser) Validate() error {
+	fields := make(errs.FieldErrors)
+
+	if nu.Name == "" {
+		fields["name"] = "name is required"
+	}
+
+	if _, err := mail.ParseAddress(nu.Email); err != nil {
+		fields["email"] = "email is not a valid email address"
+	}
+
+	if len(nu.Roles) == 0 {
+		fields["roles"] = "at least one role is required"
+	} else if _, err := userbus.ParseSliceOfRoles(nu.Roles); err != nil {
+		fields["roles"] = err.Error()
+	}
+
+	if err := userbus.CheckPassword(nu.Password); err != nil {
+		fields["password"] = err.Error()
+	}
+
+	if nu.Password != nu.PasswordConfirm {
+		fields["passwordConfirm"] = "passwordConfirm does not match password"
+	}
+
+	return fields.Err()
+}
+
+func toBusNewUser(nu NewUser) (userbus.NewUser, error) {
+	email, err := mail.ParseAddress(nu.Email)
+	if err != nil {
+		return userbus.NewUser{}, fmt.Errorf("parse email: %w", err)
+	}
+
+	roles, err := userbus.ParseSliceOfRoles(nu.Roles)
+	if err != nil {
+		return userbus.NewUser{}, fmt.Errorf("parse roles: %w", err)
+	}
+
+	return userbus.NewUser{
+		Name:     nu.Name,
+		Email:    *email,
+		Roles:    roles,
+		Password: nu.Password,
+	}, nil
+}
+
+//==============================================================================
+
+// UpdateUser represents the data that can be updated by a user, all fields are optional.
+type UpdateUser struct {
+	Name            *string `json:"name"`
+	Email           *string `json:"email"`
+	Password        *string `json:"password"`
+	PasswordConfirm *string `json:"passwordConfirm"`
+}
+
+// Validate is called by web.Decode.
+func (uu UpdateUser) Validate() error {
+	fields := make(errs.FieldErrors)
+
+	if uu.Name != nil && *uu.Name == "" {
+		fields["name"] = "name can not be empty"
+	}
+
+	if uu.Email != nil {
+		if _, err := mail.ParseAddress(*uu.Email); err != nil {
+			fields["email"] = "email is not a valid email address"
+		}
+	}
+
+	if uu.Password != nil {
+		if err := userbus.CheckPassword(*uu.Password); err != nil {
+			fields["password"] = err.Error()
+		}
+
+		if uu.PasswordConfirm == nil || *uu.Password != *uu.PasswordConfirm {
+			fields["passwordConfirm"] = "passwordConfirm does not match password"
+		}
+	}
+
+	return fields.Err()
+}
+
+func toBusUpdateUser(uu UpdateUser) (userbus.UpdateUser, error) {
+	var email *mail.Address
+	if uu.Email != nil {
+		addr, err := mail.ParseAddress(*uu.Email)
+		if err != nil {
+			return userbus.UpdateUser{}, fmt.Errorf("parse email: %w", err)
+		}
+		email = addr
+	}
+
+	return userbus.UpdateUser{
+		Name:     uu.Name,
+		Email:    email,
+		Password: uu.Password,
+	}, nil
+}
+
+//==============================================================================
+
+// UpdateUserStatus represents the data required to enable or disable a user, only admins can
+// change it so disabled users can not turn themselves back on.
+type UpdateUserStatus struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// Validate is called by web.Decode.
+func (uus UpdateUserStatus) Validate() error {
+	fields := make(errs.FieldErrors)
+	if uus.Enabled == nil {
+		fields["enabled"] = "enabled is required"
+	}
+	return fields.Err()
+}
+
+func toBusUpdateUserStatus(uus UpdateUserStatus) userbus.UpdateUser {
+	return userbus.UpdateUser{
+		Enabled: uus.Enabled,
+	}
+}
+
+//==============================================================================
+
+// UpdateUserRole represents the data required to change the roles of a user.
+type UpdateUserRole struct {
+	Roles []string `json:"roles"`
+}
+
+// Validate is called by web.Decode.
+func (uur UpdateUserRole) Validate() error {
+	fields := make(errs.FieldErrors)
+
+	if len(uur.Roles) == 0 {
+		fields["roles"] = "at least one role is required"
+	} else if _, err := userbus.ParseSliceOfRoles(uur.Roles); err != nil {
+		fields["roles"] = err.Error()
+	}
+
+	return fields.Err()
+}
+
+func toBusUpdateUserRole(uur UpdateUserRole) (userbus.UpdateUser, error) {
+	roles, err := userbus.ParseSliceOfRoles(uur.Roles)
+	if err != nil {
+		return userbus.UpdateUser{}, fmt.Errorf("parse roles: %w", err)
+	}
+
+	return userbus.UpdateUser{
+		Roles: roles,
+	}, nil
+}
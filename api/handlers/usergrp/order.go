@@ -0,0 +1,12 @@
+package usergrp
+
+import "github.com/hamidoujand/sales/internal/domain/userbus"
+
+// orderByFields maps the fields that clients can order by into business order fields.
+var orderByFields = map[string]string{
+	"user_id": userbus.OrderByID,
+	"name":    userbus.OrderByName,
+	"email":   userbus.OrderByEmail,
+	"roles":   userbus.OrderByRoles,
+	"enabled": userbus.OrderByEnabled,
+}
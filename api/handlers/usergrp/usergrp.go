@@ -0,0 +1,222 @@
+// Package usergrp provides the http handlers for managing users.
+package usergrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/mid"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/web"
+)
+
+type Handler struct {
+	UserBus *userbus.UserBus
+}
+
+func (h *Handler) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nu NewUser
+	if err := web.Decode(r, &nu); err != nil {
+		return err
+	}
+
+	busNewUser, err := toBusNewUser(nu)
+	if err != nil {
+		return err
+	}
+
+	bus, err := h.userBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	usr, err := bus.Create(ctx, busNewUser)
+	if err != nil {
+		return fmt.Errorf("create user: %w", err)
+	}
+
+	return web.Respond(ctx, w, http.StatusCreated, toAppUser(usr))
+}
+
+func (h *Handler) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var uu UpdateUser
+	if err := web.Decode(r, &uu); err != nil {
+		return err
+	}
+
+	busUpdateUser, err := toBusUpdateUser(uu)
+	if err != nil {
+		return err
+	}
+
+	usr, err := h.queryUser(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	bus, err := h.userBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	updated, err := bus.Update(ctx, usr, busUpdateUser)
+	if err != nil {
+		return fmt.Errorf("update user[%s]: %w", usr.ID, err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, toAppUser(updated))
+}
+
+func (h *Handler) UpdateRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var uur UpdateUserRole
+	if err := web.Decode(r, &uur); err != nil {
+		return err
+	}
+
+	busUpdateUser, err := toBusUpdateUserRole(uur)
+	if err != nil {
+		return err
+	}
+
+	usr, err := h.queryUser(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	bus, err := h.userBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	updated, err := bus.Update(ctx, usr, busUpdateUser)
+	if err != nil {
+		return fmt.Errorf("update user role[%s]: %w", usr.ID, err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, toAppUser(updated))
+}
+
+// UpdateStatus enables or disables the user.
+func (h *Handler) UpdateStatus(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var uus UpdateUserStatus
+	if err := web.Decode(r, &uus); err != nil {
+		return err
+	}
+
+	busUpdateUser := toBusUpdateUserStatus(uus)
+
+	usr, err := h.queryUser(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	bus, err := h.userBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	updated, err := bus.Update(ctx, usr, busUpdateUser)
+	if err != nil {
+		return fmt.Errorf("update user status[%s]: %w", usr.ID, err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, toAppUser(updated))
+}
+
+func (h *Handler) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := h.queryUser(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	bus, err := h.userBus(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := bus.Delete(ctx, usr); err != nil {
+		return fmt.Errorf("delete user[%s]: %w", usr.ID, err)
+	}
+
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
+
+func (h *Handler) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := h.queryUser(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, toAppUser(usr))
+}
+
+func (h *Handler) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	qp := parseQueryParams(r)
+
+	pg, err := page.Parse(qp.Page, qp.Rows)
+	if err != nil {
+		return errs.NewValidation(http.StatusBadRequest, map[string]string{"page": err.Error()}, "invalid paging")
+	}
+
+	filter, err := parseFilter(qp)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := order.Parse(orderByFields, qp.OrderBy, userbus.DefaultOrderBy)
+	if err != nil {
+		return errs.NewValidation(http.StatusBadRequest, map[string]string{"orderBy": err.Error()}, "invalid order")
+	}
+
+	users, err := h.UserBus.Query(ctx, filter, orderBy, pg)
+	if err != nil {
+		return fmt.Errorf("query users: %w", err)
+	}
+
+	total, err := h.UserBus.Count(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("count users: %w", err)
+	}
+
+	return web.Respond(ctx, w, http.StatusOK, page.NewDocument(toAppUsers(users), total, pg))
+}
+
+// queryUser loads the user that is referenced by the "user_id" path param.
+func (h *Handler) queryUser(ctx context.Context, r *http.Request) (userbus.User, error) {
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		return userbus.User{}, errs.Newf(http.StatusBadRequest, "invalid user id: %q", r.PathValue("user_id"))
+	}
+
+	bus, err := h.userBus(ctx)
+	if err != nil {
+		return userbus.User{}, err
+	}
+
+	usr, err := bus.QueryByID(ctx, userID)
+	if err != nil {
+		return userbus.User{}, fmt.Errorf("query user[%s]: %w", userID, err)
+	}
+
+	return usr, nil
+}
+
+// userBus returns the bus bound to the transaction of the request when it runs within one.
+func (h *Handler) userBus(ctx context.Context) (*userbus.UserBus, error) {
+	tx, ok := mid.GetTran(ctx)
+	if !ok {
+		return h.UserBus, nil
+	}
+
+	bus, err := h.UserBus.NewWithTx(tx)
+	if err != nil {
+		return nil, errs.Newf(http.StatusInternalServerError, "binding user bus to transaction: %s", err)
+	}
+
+	return bus, nil
+}
@@ -0,0 +1,257 @@
+package usergrp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/sales/api/handlers"
+	"github.com/hamidoujand/sales/api/handlers/usergrp"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/page"
+)
+
+func TestUserAPI(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "user_api")
+
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: dbtest.NewKeyStore(t),
+		Issuer:    "auth-service",
+		ActiveKID: dbtest.KID,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+
+	admin := dbtest.CreateUser(ctx, t, bus, "admin@gmail.com", userbus.RoleAdmin)
+	usr := dbtest.CreateUser(ctx, t, bus, "user@gmail.com", userbus.RoleUser)
+	other := dbtest.CreateUser(ctx, t, bus, "other@gmail.com", userbus.RoleUser)
+
+	adminToken := dbtest.GenerateToken(t, authClient, admin)
+	userToken := dbtest.GenerateToken(t, authClient, usr)
+
+	mux := handlers.APIMux(handlers.Config{
+		Build: "test",
+		Log:   slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		DB:    database.DB,
+		Auth:  authClient,
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	enabled := true
+	tests := map[string]struct {
+		method     string
+		path       string
+		token      string
+		body       any
+		statusCode int
+		fields     bool //expects a validation error with failed fields.
+		total      int  //expected total of a listed document.
+		rows       int  //expected rows per page of a listed document.
+	}{
+		"admin_creates_user": {
+			method: http.MethodPost,
+			path:   "/v1/users",
+			token:  adminToken,
+			body: usergrp.NewUser{
+				Name:            "John",
+				Email:           "john@gmail.com",
+				Roles:           []string{"USER"},
+				Password:        "password",
+				PasswordConfirm: "password",
+			},
+			statusCode: http.StatusCreated,
+		},
+		"duplicated_email": {
+			method: http.MethodPost,
+			path:   "/v1/users",
+			token:  adminToken,
+			body: usergrp.NewUser{
+				Name:            "John",
+				Email:           usr.Email.Address,
+				Roles:           []string{"USER"},
+				Password:        "password",
+				PasswordConfirm: "password",
+			},
+			statusCode: http.StatusConflict,
+		},
+		"invalid_new_user": {
+			method:     http.MethodPost,
+			path:       "/v1/users",
+			token:      adminToken,
+			body:       usergrp.NewUser{Email: "not-an-email", Roles: []string{"UNKNOWN"}},
+			statusCode: http.StatusBadRequest,
+			fields:     true,
+		},
+		"user_can_not_create_users": {
+			method:     http.MethodPost,
+			path:       "/v1/users",
+			token:      userToken,
+			body:       usergrp.NewUser{},
+			statusCode: http.StatusUnauthorized,
+		},
+		"owner_queries_itself": {
+			method:     http.MethodGet,
+			path:       "/v1/users/" + usr.ID.String(),
+			token:      userToken,
+			statusCode: http.StatusOK,
+		},
+		"user_queries_someone_else": {
+			method:     http.MethodGet,
+			path:       "/v1/users/" + other.ID.String(),
+			token:      userToken,
+			statusCode: http.StatusUnauthorized,
+		},
+		"owner_updates_itself": {
+			method:     http.MethodPut,
+			path:       "/v1/users/" + usr.ID.String(),
+			token:      userToken,
+			body:       map[string]string{"name": "Jane"},
+			statusCode: http.StatusOK,
+		},
+		"user_can_not_enable_itself": {
+			method:     http.MethodPut,
+			path:       "/v1/users/" + usr.ID.String(),
+			token:      userToken,
+			body:       map[string]any{"enabled": true},
+			statusCode: http.StatusBadRequest,
+		},
+		"user_can_not_change_status": {
+			method:     http.MethodPut,
+			path:       "/v1/users/status/" + usr.ID.String(),
+			token:      userToken,
+			body:       usergrp.UpdateUserStatus{Enabled: &enabled},
+			statusCode: http.StatusUnauthorized,
+		},
+		"admin_changes_status": {
+			method:     http.MethodPut,
+			path:       "/v1/users/status/" + other.ID.String(),
+			token:      adminToken,
+			body:       usergrp.UpdateUserStatus{Enabled: &enabled},
+			statusCode: http.StatusOK,
+		},
+		"user_can_not_change_roles": {
+			method:     http.MethodPut,
+			path:       "/v1/users/role/" + usr.ID.String(),
+			token:      userToken,
+			body:       usergrp.UpdateUserRole{Roles: []string{"ADMIN"}},
+			statusCode: http.StatusUnauthorized,
+		},
+		"admin_changes_roles": {
+			method:     http.MethodPut,
+			path:       "/v1/users/role/" + other.ID.String(),
+			token:      adminToken,
+			body:       usergrp.UpdateUserRole{Roles: []string{"ADMIN"}},
+			statusCode: http.StatusOK,
+		},
+		"admin_deletes_user": {
+			method:     http.MethodDelete,
+			path:       "/v1/users/" + other.ID.String(),
+			token:      adminToken,
+			statusCode: http.StatusNoContent,
+		},
+		"user_not_found": {
+			method:     http.MethodGet,
+			path:       "/v1/users/" + "00000000-0000-0000-0000-000000000000",
+			token:      adminToken,
+			statusCode: http.StatusNotFound,
+		},
+		"admin_lists_users": {
+			method:     http.MethodGet,
+			path:       "/v1/users?page=1&rows=2&orderBy=name,DESC&email=user@gmail.com",
+			token:      adminToken,
+			statusCode: http.StatusOK,
+			total:      1,
+			rows:       2,
+		},
+		"invalid_order_by": {
+			method:     http.MethodGet,
+			path:       "/v1/users?orderBy=password,DESC",
+			token:      adminToken,
+			statusCode: http.StatusBadRequest,
+			fields:     true,
+		},
+		"missing_token": {
+			method:     http.MethodGet,
+			path:       "/v1/users",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, name := range []string{
+		"admin_creates_user", "duplicated_email", "invalid_new_user", "user_can_not_create_users",
+		"owner_queries_itself", "user_queries_someone_else", "owner_updates_itself",
+		"user_can_not_enable_itself", "user_can_not_change_status", "admin_changes_status",
+		"user_can_not_change_roles", "admin_changes_roles", "admin_deletes_user",
+		"user_not_found", "admin_lists_users", "invalid_order_by", "missing_token",
+	} {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			var body bytes.Buffer
+			if test.body != nil {
+				if err := json.NewEncoder(&body).Encode(test.body); err != nil {
+					t.Fatalf("encoding body: %s", err)
+				}
+			}
+
+			req, err := http.NewRequest(test.method, server.URL+test.path, &body)
+			if err != nil {
+				t.Fatalf("creating request: %s", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("making the request: %s", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.statusCode {
+				t.Fatalf("status=%d, got %d", test.statusCode, resp.StatusCode)
+			}
+
+			if test.fields {
+				var appErr errs.Error
+				if err := json.NewDecoder(resp.Body).Decode(&appErr); err != nil {
+					t.Fatalf("decoding error response: %s", err)
+				}
+
+				if len(appErr.Fields) == 0 {
+					t.Errorf("expected validation errors to have fields")
+				}
+			}
+
+			if test.rows != 0 {
+				var doc page.Document[usergrp.User]
+				if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+					t.Fatalf("decoding document: %s", err)
+				}
+
+				if doc.Total != test.total || len(doc.Items) != test.total {
+					t.Errorf("total=%d, got %d with %d items", test.total, doc.Total, len(doc.Items))
+				}
+
+				if doc.RowsPerPage != test.rows {
+					t.Errorf("rowsPerPage=%d, got %d", test.rows, doc.RowsPerPage)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// envPrefix is shared with the sales service, so SALES_DB_HOST points both at the same database.
+const envPrefix = "SALES"
+
+// errUsage is returned when a command is called with the wrong arguments, the usage of the
+// command is printed next to it.
+var errUsage = errors.New("invalid usage")
+
+// command represents a node of the command tree, a node runs, groups subcommands or both.
+type command struct {
+	name  string
+	short string
+	args  string //synopsis of the positional arguments.
+	flags func(fs *flagSet) runFunc
+	subs  []*command
+}
+
+type runFunc func(args []string, out *output) error
+
+// flagSet adds env var fallbacks to flag.FlagSet.
+type flagSet struct {
+	*flag.FlagSet
+	env map[string]string
+}
+
+func newFlagSet(name string) *flagSet {
+	return &flagSet{
+		FlagSet: flag.NewFlagSet(name, flag.ContinueOnError),
+		env:     make(map[string]string),
+	}
+}
+
+// bindEnv makes the flag fall back to the env var SALES_<key> when it is not passed.
+func (fs *flagSet) bindEnv(name string, key string) {
+	env := envPrefix + "_" + key
+	fs.env[name] = env
+
+	if f := fs.Lookup(name); f != nil {
+		f.Usage += fmt.Sprintf(" (env %s)", env)
+	}
+}
+
+func (fs *flagSet) parse(args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	passed := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { passed[f.Name] = true })
+
+	for name, env := range fs.env {
+		value, ok := os.LookupEnv(env)
+		if passed[name] || !ok {
+			continue
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+	}
+
+	return nil
+}
+
+// output prints either the human readable form of a result or its JSON encoding.
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+func (o *output) print(v any, text func(w io.Writer)) error {
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	text(tw)
+	return tw.Flush()
+}
+
+// execute finds the command named by args and runs it.
+func execute(root *command, args []string) error {
+	cmd, path, args := root.find(args)
+
+	if cmd.flags == nil {
+		//a group: list its subcommands.
+		if len(args) > 0 && args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			cmd.printHelp(os.Stderr, path)
+			return fmt.Errorf("unknown command %q", strings.Join(append(path, args[0]), " "))
+		}
+
+		cmd.printHelp(os.Stdout, path)
+		return nil
+	}
+
+	fs := newFlagSet(strings.Join(path, " "))
+	run := cmd.flags(fs)
+	jsonOut := fs.Bool("json", false, "print the result as JSON.")
+	fs.Usage = func() { cmd.printUsage(fs.Output(), path, fs) }
+
+	if err := fs.parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	err := run(fs.Args(), &output{w: os.Stdout, json: *jsonOut})
+	if errors.Is(err, errUsage) {
+		fs.SetOutput(os.Stderr)
+		fs.Usage()
+	}
+
+	return err
+}
+
+// find walks down the tree as long as args name subcommands.
+func (c *command) find(args []string) (*command, []string, []string) {
+	cmd, path := c, []string{c.name}
+
+	for len(args) > 0 {
+		next := cmd.sub(args[0])
+		if next == nil {
+			break
+		}
+
+		cmd, path, args = next, append(path, next.name), args[1:]
+	}
+
+	return cmd, path, args
+}
+
+func (c *command) sub(name string) *command {
+	for _, sub := range c.subs {
+		if sub.name == name {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// printHelp lists the subcommands of a group.
+func (c *command) printHelp(w io.Writer, path []string) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\n", strings.Join(path, " "))
+	if c.short != "" {
+		fmt.Fprintf(w, "%s\n\n", c.short)
+	}
+
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, sub := range c.subs {
+		fmt.Fprintf(tw, "  %s\t%s\n", sub.name, sub.short)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", strings.Join(path, " "))
+}
+
+// printUsage prints the synopsis and the flags of a runnable command.
+func (c *command) printUsage(w io.Writer, path []string, fs *flagSet) {
+	synopsis := strings.Join(path, " ") + " [flags]"
+	if c.args != "" {
+		synopsis += " " + c.args
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n\n", synopsis, c.short)
+
+	if len(c.subs) > 0 {
+		fmt.Fprintln(w, "Commands:")
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, sub := range c.subs {
+			fmt.Fprintf(tw, "  %s %s\t%s\n", sub.name, sub.args, sub.short)
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "Flags:")
+	fs.PrintDefaults()
+}
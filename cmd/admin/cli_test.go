@@ -0,0 +1,180 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+func TestFind(t *testing.T) {
+	tests := map[string]struct {
+		args     []string
+		path     []string
+		rest     []string
+		runnable bool
+	}{
+		"root":              {args: nil, path: []string{"admin"}},
+		"group":             {args: []string{"user"}, path: []string{"admin", "user"}},
+		"command":           {args: []string{"user", "add", "-name=john"}, path: []string{"admin", "user", "add"}, rest: []string{"-name=john"}, runnable: true},
+		"runnable_group":    {args: []string{"migrate", "up"}, path: []string{"admin", "migrate", "up"}, runnable: true},
+		"unknown_command":   {args: []string{"user", "unknown"}, path: []string{"admin", "user"}, rest: []string{"unknown"}},
+		"flags_stop_search": {args: []string{"seed", "user"}, path: []string{"admin", "seed"}, rest: []string{"user"}, runnable: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd, path, rest := root().find(test.args)
+
+			if !slices.Equal(path, test.path) {
+				t.Errorf("path=%v, got %v", test.path, path)
+			}
+
+			if !slices.Equal(rest, test.rest) {
+				t.Errorf("rest=%v, got %v", test.rest, rest)
+			}
+
+			if (cmd.flags != nil) != test.runnable {
+				t.Errorf("runnable=%t, got %t", test.runnable, cmd.flags != nil)
+			}
+		})
+	}
+}
+
+func TestResolveLegacy(t *testing.T) {
+	for name, path := range legacy {
+		t.Run(name, func(t *testing.T) {
+			args := resolveLegacy([]string{name, "-json"})
+
+			cmd, found, rest := root().find(args)
+			if !slices.Equal(found[1:], path) {
+				t.Fatalf("path=%v, got %v", path, found[1:])
+			}
+
+			if cmd.flags == nil {
+				t.Errorf("expected %s to resolve to a runnable command", name)
+			}
+
+			if !slices.Equal(rest, []string{"-json"}) {
+				t.Errorf("expected the remaining args to be kept, got %v", rest)
+			}
+		})
+	}
+
+	//the map must not be changed by resolving.
+	resolveLegacy([]string{"useradd", "-name=john"})
+	if !slices.Equal(legacy["useradd"], []string{"user", "add"}) {
+		t.Errorf("legacy path changed to %v", legacy["useradd"])
+	}
+
+	if args := resolveLegacy([]string{"user", "add"}); !slices.Equal(args, []string{"user", "add"}) {
+		t.Errorf("expected current names to be kept, got %v", args)
+	}
+}
+
+func TestEnvPrecedence(t *testing.T) {
+	tests := map[string]struct {
+		env        map[string]string
+		args       []string
+		host       string
+		rows       int
+		shouldFail bool
+	}{
+		"defaults":         {host: "localhost", rows: 10},
+		"env_over_default": {env: map[string]string{"SALES_TEST_HOST": "from-env"}, host: "from-env", rows: 10},
+		"flag_over_env":    {env: map[string]string{"SALES_TEST_HOST": "from-env"}, args: []string{"-host=from-flag"}, host: "from-flag", rows: 10},
+		"typed_env":        {env: map[string]string{"SALES_TEST_ROWS": "25"}, host: "localhost", rows: 25},
+		"invalid_env":      {env: map[string]string{"SALES_TEST_ROWS": "many"}, shouldFail: true},
+		"unbound_is_off":   {env: map[string]string{"SALES_HOST": "wrong"}, host: "localhost", rows: 10},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+
+			fs := newFlagSet("test")
+			host := fs.String("host", "localhost", "host.")
+			rows := fs.Int("rows", 10, "rows.")
+			fs.bindEnv("host", "TEST_HOST")
+			fs.bindEnv("rows", "TEST_ROWS")
+
+			err := fs.parse(test.args)
+			if test.shouldFail {
+				if err == nil {
+					t.Fatal("expected parsing to fail")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parsing flags: %s", err)
+			}
+
+			if *host != test.host {
+				t.Errorf("host=%s, got %s", test.host, *host)
+			}
+
+			if *rows != test.rows {
+				t.Errorf("rows=%d, got %d", test.rows, *rows)
+			}
+		})
+	}
+}
+
+// TestComposeEnv feeds the env of the sales service in the compose file to the database flags,
+// so the admin CLI keeps reading the names the service does.
+func TestComposeEnv(t *testing.T) {
+	bs, err := os.ReadFile("../../infra/compose/docker-compose.yaml")
+	if err != nil {
+		t.Fatalf("reading compose file: %s", err)
+	}
+
+	env := make(map[string]string)
+	for _, line := range strings.Split(string(bs), "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "- "), "=")
+		if !ok || !strings.HasPrefix(key, envPrefix+"_DB_") {
+			continue
+		}
+		env[key] = value
+	}
+
+	if len(env) == 0 {
+		t.Fatal("expected the compose file to configure the database through env vars")
+	}
+
+	fs := newFlagSet("test")
+	dbConfig := dbFlags(fs)
+
+	bound := make(map[string]bool)
+	for _, name := range fs.env {
+		bound[name] = true
+	}
+
+	for key, value := range env {
+		if !bound[key] {
+			t.Errorf("%s is set by compose but not read by the admin CLI", key)
+		}
+		t.Setenv(key, value)
+	}
+
+	if err := fs.parse(nil); err != nil {
+		t.Fatalf("parsing flags: %s", err)
+	}
+
+	expected := sqldb.Config{
+		User:       env["SALES_DB_USER"],
+		Password:   env["SALES_DB_PASSWORD"],
+		Host:       env["SALES_DB_HOST"],
+		Name:       "postgres",
+		DisableTLS: env["SALES_DB_DISABLE_TLS"] == "true",
+	}
+
+	if got := dbConfig(); got != expected {
+		t.Errorf("config=%+v, got %+v", expected, got)
+	}
+}
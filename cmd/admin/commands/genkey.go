@@ -1,79 +1,145 @@
 package commands
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/pkg/keystore"
 )
 
-func GenerateKey(keysize int) error {
-	fmt.Printf("generating key with size %d...\n", keysize)
+// Supported key algorithms.
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgES384 = "ES384"
+	AlgEdDSA = "EdDSA"
+)
+
+// KeyConfig represents the settings used to generate a key pair.
+type KeyConfig struct {
+	Alg        string
+	Size       int    //only used by RS256.
+	Passphrase []byte //optional, the private key is written as encrypted PKCS8 when set.
+	Output     string //directory the key files and active.txt are written to.
+}
+
+// Key represents a generated key pair.
+type Key struct {
+	KID        string `json:"kid"`
+	Alg        string `json:"alg"`
+	PrivateKey string `json:"privateKey"`
+	PublicKey  string `json:"publicKey"`
+	Encrypted  bool   `json:"encrypted"`
+}
 
-	privateKey, err := rsa.GenerateKey(rand.Reader, keysize)
+// GenerateKey generates a private/public key pair for the given algorithm and marks it active.
+func GenerateKey(cfg KeyConfig) (Key, error) {
+	privateKey, err := newPrivateKey(cfg.Alg, cfg.Size)
 	if err != nil {
-		return fmt.Errorf("generate rsa private key: %w", err)
+		return Key{}, fmt.Errorf("generate private key: %w", err)
 	}
 
-	privateDER := x509.MarshalPKCS1PrivateKey(privateKey)
-	privateBlock := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: privateDER,
+	privateBlock, err := privatePEM(privateKey, cfg.Passphrase)
+	if err != nil {
+		return Key{}, fmt.Errorf("marshalling private key: %w", err)
 	}
 
-	//create folder keys if not already
-	if err := os.Mkdir("keys", 0755); err != nil {
-		if !os.IsExist(err) {
-			return fmt.Errorf("creating keys folder: %w", err)
-		}
+	//create the output folder if not already
+	if err := os.MkdirAll(cfg.Output, 0755); err != nil {
+		return Key{}, fmt.Errorf("creating keys folder: %w", err)
 	}
 
 	//create the key file
 	keyID := uuid.NewString()
-	filepath := "keys/" + keyID + "-private.pem"
+	privatePath := filepath.Join(cfg.Output, keyID+"-private.pem")
 
-	file, err := os.Create(filepath)
+	file, err := os.OpenFile(privatePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
-		return fmt.Errorf("creating private key file: %w", err)
+		return Key{}, fmt.Errorf("creating private key file: %w", err)
 	}
 	defer file.Close()
 
-	if err := pem.Encode(file, &privateBlock); err != nil {
-		return fmt.Errorf("encoding into pem: %w", err)
+	if err := pem.Encode(file, privateBlock); err != nil {
+		return Key{}, fmt.Errorf("encoding into pem: %w", err)
 	}
 	// ==========================================================================
-	publicKeyDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
 	if err != nil {
-		return fmt.Errorf("marshalling public key into DER: %w", err)
+		return Key{}, fmt.Errorf("marshalling public key into DER: %w", err)
 	}
 
-	filepath = "keys/" + keyID + "-public.pem"
+	publicPath := filepath.Join(cfg.Output, keyID+"-public.pem")
 
-	publicFile, err := os.Create(filepath)
+	publicFile, err := os.Create(publicPath)
 	if err != nil {
-		return fmt.Errorf("creating public key file: %w", err)
+		return Key{}, fmt.Errorf("creating public key file: %w", err)
 	}
 	defer publicFile.Close()
 
 	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
+		Type:  "PUBLIC KEY",
 		Bytes: publicKeyDER,
 	}
 
 	if err := pem.Encode(publicFile, &publicBlock); err != nil {
-		return fmt.Errorf("encoding public key into pem: %w", err)
+		return Key{}, fmt.Errorf("encoding public key into pem: %w", err)
 	}
 
 	//make this key as active key
-	activeKeyFilePath := "keys/active.txt"
+	activeKeyFilePath := filepath.Join(cfg.Output, "active.txt")
 	if err := os.WriteFile(activeKeyFilePath, []byte(keyID), 0644); err != nil {
-		return fmt.Errorf("write active key file: %w", err)
+		return Key{}, fmt.Errorf("write active key file: %w", err)
 	}
 
-	fmt.Println("private and public key files generated")
-	return nil
+	return Key{
+		KID:        keyID,
+		Alg:        cfg.Alg,
+		PrivateKey: privatePath,
+		PublicKey:  publicPath,
+		Encrypted:  len(cfg.Passphrase) > 0,
+	}, nil
+}
+
+func newPrivateKey(alg string, keysize int) (crypto.Signer, error) {
+	switch alg {
+	case AlgRS256:
+		return rsa.GenerateKey(rand.Reader, keysize)
+	case AlgES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgES384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case AlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// privatePEM keeps rsa keys in PKCS1 so older deployments can still read them, every other
+// key type is written as PKCS8. Encrypted keys are always PKCS8.
+func privatePEM(key crypto.Signer, passphrase []byte) (*pem.Block, error) {
+	if len(passphrase) > 0 {
+		return keystore.EncryptPEM(key, passphrase)
+	}
+
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
 }
@@ -1,93 +1,85 @@
 package commands
 
 import (
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
+	"context"
 	"fmt"
-	"io"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/pkg/keystore"
 )
 
-const adminRole = "admin"
+const adminRole = "ADMIN"
+
+// TokenConfig represents the settings used by the CLI to mint a token, issuer and audiences must
+// be accepted by the sales service configuration.
+type TokenConfig struct {
+	KeyPath    string
+	UserID     string
+	KID        string
+	Issuer     string
+	Audiences  []string
+	TTL        time.Duration
+	Passphrase []byte //optional, decrypts encrypted private keys.
+}
+
+// Token represents a generated token.
+type Token struct {
+	Token     string    `json:"token"`
+	KID       string    `json:"kid"`
+	Subject   string    `json:"subject"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
 
-func GenerateToken(keyPath string, userId string, kid string) error {
+func GenerateToken(cfg TokenConfig) (Token, error) {
 	//TODO: need to add database check for the userId to make sure is authorized.
-	claims := struct {
-		jwt.RegisteredClaims
-		Roles []string `json:"roles"`
-	}{
-		Roles: []string{adminRole},
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    "admin-cli",
-			Subject:   userId,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	ks := keystore.NewWithConfig(keystore.Config{Passphrase: cfg.Passphrase})
+	activeKID, err := ks.LoadKeys(os.DirFS(cfg.KeyPath))
+	if err != nil {
+		return Token{}, fmt.Errorf("loading keys: %w", err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = kid
-
-	//load the private key
-	path := keyPath + "/" + kid + "-private.pem"
-
-	privateFile, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("open private key file: %w", err)
+	//sign with the active key unless a kid is asked for.
+	if cfg.KID == "" {
+		cfg.KID = activeKID
 	}
-	defer privateFile.Close()
 
-	bs, err := io.ReadAll(privateFile)
+	a, err := auth.New(auth.Config{
+		KeyLookup: ks,
+		Issuer:    cfg.Issuer,
+		ActiveKID: cfg.KID,
+		Audiences: cfg.Audiences,
+	})
 	if err != nil {
-		return fmt.Errorf("readAll: %w", err)
+		return Token{}, fmt.Errorf("creating auth: %w", err)
 	}
 
-	block, _ := pem.Decode(bs)
-	if block == nil {
-		//invalid pem data
-		return fmt.Errorf("no pem block found in the file")
+	now := time.Now()
+	claims := auth.Claims{
+		Roles: []string{adminRole},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   cfg.UserID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.TTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
 	}
 
-	//parse the private key
-	var privateKey *rsa.PrivateKey
-
-	switch block.Type {
-	case "RSA PRIVATE KEY":
-		//use PKCS1
-		var err error
-		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
-		if err != nil {
-			return fmt.Errorf("parsing private key: %w", err)
-		}
-
-	case "PRIVATE KEY":
-		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
-		if err != nil {
-			return fmt.Errorf("parsing private key: %w", err)
-		}
-
-		var ok bool
-		privateKey, ok = key.(*rsa.PrivateKey)
-		if !ok {
-			return fmt.Errorf("key is not a rsa private key: %T", key)
-		}
-
-	default:
-		return fmt.Errorf("unsupported PEM type: %s", block.Type)
+	tkn, err := a.GenerateToken(claims)
+	if err != nil {
+		return Token{}, fmt.Errorf("generating token: %w", err)
 	}
 
-	//sign token
-	tkn, err := token.SignedString(privateKey)
-	if err != nil {
-		return fmt.Errorf("signing token: %w", err)
+	//make sure the token passes the same checks the service runs.
+	if _, err := a.Authenticate(context.Background(), "Bearer "+tkn); err != nil {
+		return Token{}, fmt.Errorf("validating generated token: %w", err)
 	}
 
-	fmt.Println("==============================TOKEN================================")
-	fmt.Println(tkn)
-	fmt.Println("==============================End================================")
-	return nil
+	return Token{
+		Token:     tkn,
+		KID:       cfg.KID,
+		Subject:   cfg.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
 }
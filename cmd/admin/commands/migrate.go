@@ -2,18 +2,36 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/hamidoujand/sales/internal/sqldb"
 )
 
-func Migrate(cfg sqldb.Config) error {
-	fmt.Printf("applying migrations against %s.\n", cfg.Host)
+// MigrationsDir is where new migration files are scaffolded, relative to the repository root.
+const MigrationsDir = "internal/sqldb/sql"
 
+// Migrate runs a migration action against the database:
+//
+//	status      print the current version and every migration
+//	up [N]      apply the next N migrations, all when N is omitted
+//	down [N]    roll back the last N migrations, 1 when N is omitted
+//	goto V      migrate up or down to version V
+//	force V     set the version to V and clear the dirty flag
+//
+// It returns the status of the schema after the action and sqldb.ErrDirty when the schema is left
+// dirty.
+func Migrate(cfg sqldb.Config, action string, args []string) (MigrationStatus, error) {
 	db, err := sqldb.Open(cfg)
 	if err != nil {
-		return fmt.Errorf("open: %w", err)
+		return MigrationStatus{}, fmt.Errorf("open: %w", err)
 	}
 
 	defer db.Close()
@@ -22,14 +40,201 @@ func Migrate(cfg sqldb.Config) error {
 	defer cancel()
 
 	if err := sqldb.StatusCheck(ctx, db); err != nil {
-		return fmt.Errorf("statusCheck: %w", err)
+		return MigrationStatus{}, fmt.Errorf("statusCheck: %w", err)
 	}
 
-	//run migrations
-	if err := sqldb.Migrate(ctx, db, cfg.Name); err != nil {
-		return fmt.Errorf("migrate: %w", err)
+	mg, err := sqldb.NewMigrator(db, cfg.Name)
+	if err != nil {
+		return MigrationStatus{}, fmt.Errorf("migrator: %w", err)
+	}
+
+	//the status is reported even when the action fails, it shows where the schema was left.
+	actionErr := runMigration(mg, action, args)
+
+	status, err := mg.Status()
+	if err != nil {
+		return MigrationStatus{}, fmt.Errorf("status: %w", err)
+	}
+
+	ms := toMigrationStatus(cfg, status)
+	if actionErr != nil {
+		return ms, actionErr
+	}
+
+	if status.Dirty {
+		return ms, fmt.Errorf("version %d: %w, fix it and run: migrate force %d", status.Version, sqldb.ErrDirty, status.Version)
+	}
+
+	return ms, nil
+}
+
+func runMigration(mg *sqldb.Migrator, action string, args []string) error {
+	switch action {
+	case "status":
+		//status only reports, the dirty check decides the exit code.
+		return nil
+
+	case "up":
+		n, err := optionalInt(args, 0)
+		if err != nil {
+			return err
+		}
+		return mg.Up(n)
+
+	case "down":
+		n, err := optionalInt(args, 1)
+		if err != nil {
+			return err
+		}
+		return mg.Down(n)
+
+	case "goto":
+		v, err := requiredInt(args, "version")
+		if err != nil {
+			return err
+		}
+
+		if v < 0 {
+			return errors.New("version must not be negative")
+		}
+		return mg.Goto(uint(v))
+
+	case "force":
+		v, err := requiredInt(args, "version")
+		if err != nil {
+			return err
+		}
+		return mg.Force(v)
+
+	default:
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+}
+
+// MigrationStatus represents the state of the schema.
+type MigrationStatus struct {
+	Database   string      `json:"database"`
+	Version    uint        `json:"version"`
+	Dirty      bool        `json:"dirty"`
+	Pending    int         `json:"pending"`
+	Migrations []Migration `json:"migrations"`
+}
+
+// Migration represents one migration and whether it is applied.
+type Migration struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+}
+
+func toMigrationStatus(cfg sqldb.Config, status sqldb.MigrationStatus) MigrationStatus {
+	ms := MigrationStatus{
+		Database:   cfg.Host + "/" + cfg.Name,
+		Version:    status.Version,
+		Dirty:      status.Dirty,
+		Pending:    len(status.Pending()),
+		Migrations: make([]Migration, len(status.Migrations)),
+	}
+
+	for i, m := range status.Migrations {
+		state := "pending"
+		switch {
+		case m.Version == status.Version && status.Dirty:
+			state = "dirty"
+		case status.Applied(m):
+			state = "applied"
+		}
+
+		ms.Migrations[i] = Migration{Version: m.Version, Name: m.Name, State: state}
+	}
+
+	return ms
+}
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// NewMigration scaffolds the next numbered up and down files in dir and returns their paths.
+func NewMigration(dir string, name string) ([]string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q, use letters, digits and underscores", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations dir: %w", err)
+	}
+
+	var last uint
+	for _, entry := range entries {
+		m, err := source.Parse(entry.Name())
+		if err != nil {
+			continue
+		}
+		last = max(last, m.Version)
+	}
+
+	var paths []string
+	prefix := fmt.Sprintf("%06d_%s", last+1, name)
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, prefix+"."+direction+".sql")
+
+		if err := writeMigration(path, direction, name); err != nil {
+			//a lone up migration would hold on to the version, so the pair is created or none.
+			for _, p := range paths {
+				os.Remove(p)
+			}
+			return nil, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// writeMigration creates the file of one direction, it is removed again when it can not be written.
+func writeMigration(path string, direction string, name string) error {
+	//O_EXCL so we never overwrite an existing migration.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+
+	if _, err := fmt.Fprintf(f, "-- %s: %s\n", direction, name); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("closing %s: %w", path, err)
 	}
 
-	fmt.Println("migrations applied successfully.")
 	return nil
 }
+
+func optionalInt(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of steps %q", args[0])
+	}
+	return n, nil
+}
+
+func requiredInt(args []string, name string) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("%s is required", name)
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, args[0])
+	}
+	return n, nil
+}
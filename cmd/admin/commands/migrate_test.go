@@ -0,0 +1,45 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hamidoujand/sales/cmd/admin/commands"
+)
+
+func TestNewMigration(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "000001_init.up.sql"), nil, 0644); err != nil {
+		t.Fatalf("writing migration: %s", err)
+	}
+
+	paths, err := commands.NewMigration(dir, "Add-Orders")
+	if err != nil {
+		t.Fatalf("creating migration: %s", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "000002_add_orders.up.sql"),
+		filepath.Join(dir, "000002_add_orders.down.sql"),
+	}
+	if len(paths) != 2 || paths[0] != expected[0] || paths[1] != expected[1] {
+		t.Errorf("paths=%v, got %v", expected, paths)
+	}
+
+	//the down file name is over the file name limit while the up file still fits.
+	long := strings.Repeat("a", 240)
+	if _, err := commands.NewMigration(dir, long); err == nil {
+		t.Fatal("expected a migration whose down file can not be created to fail")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading migrations dir: %s", err)
+	}
+
+	if len(entries) != 3 {
+		t.Errorf("expected the up file of the failed migration to be removed, got %d files", len(entries))
+	}
+}
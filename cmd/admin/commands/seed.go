@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hamidoujand/sales/internal/seed"
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+// SeedResult represents what a seeding run created.
+type SeedResult struct {
+	Seed     uint64 `json:"seed"`
+	Users    int    `json:"users"`
+	Products int    `json:"products"`
+	Orders   int    `json:"orders"`
+	Skipped  int    `json:"skipped"`
+}
+
+// Seed fills the database with the embedded fixtures and generated data.
+func Seed(cfg sqldb.Config, seedCfg seed.Config) (SeedResult, error) {
+	db, err := sqldb.Open(cfg)
+	if err != nil {
+		return SeedResult{}, fmt.Errorf("open: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+	defer cancel()
+
+	if err := sqldb.StatusCheck(ctx, db); err != nil {
+		return SeedResult{}, fmt.Errorf("statusCheck: %w", err)
+	}
+
+	res, err := seed.Run(ctx, storeLog, db, seedCfg)
+	if err != nil {
+		return SeedResult{}, fmt.Errorf("seed: %w", err)
+	}
+
+	return SeedResult{
+		Seed:     seedCfg.Seed,
+		Users:    len(res.Users),
+		Products: len(res.Products),
+		Orders:   len(res.Orders),
+		Skipped:  res.Skipped,
+	}, nil
+}
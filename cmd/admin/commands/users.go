@@ -0,0 +1,279 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/sqldb"
+	"golang.org/x/term"
+)
+
+// NewUser represents the input of useradd.
+type NewUser struct {
+	Name     string
+	Email    string
+	Roles    []string
+	Password string
+}
+
+// UpdateUser represents the input of usermod, nil fields are left untouched.
+type UpdateUser struct {
+	Name    *string
+	Email   *string
+	Roles   []string
+	Enabled *bool
+}
+
+// User represents a user in the output of the user commands.
+type User struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Roles       []string  `json:"roles"`
+	Enabled     bool      `json:"enabled"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+func toUser(usr userbus.User) User {
+	return User{
+		ID:          usr.ID.String(),
+		Name:        usr.Name,
+		Email:       usr.Email.Address,
+		Roles:       userbus.EncodeRoles(usr.Roles),
+		Enabled:     usr.Enabled,
+		DateCreated: usr.DateCreated,
+		DateUpdated: usr.DateUpdated,
+	}
+}
+
+// UserAdd creates a user through the userbus, so the password is hashed the same way the api does.
+func UserAdd(cfg sqldb.Config, nu NewUser) (User, error) {
+	email, err := mail.ParseAddress(nu.Email)
+	if err != nil {
+		return User{}, fmt.Errorf("parsing email: %w", err)
+	}
+
+	roles, err := userbus.ParseSliceOfRoles(nu.Roles)
+	if err != nil {
+		return User{}, fmt.Errorf("parsing roles: %w", err)
+	}
+
+	if err := userbus.CheckPassword(nu.Password); err != nil {
+		return User{}, err
+	}
+
+	var usr userbus.User
+	err = withUserBus(cfg, func(ctx context.Context, bus *userbus.UserBus) error {
+		usr, err = bus.Create(ctx, userbus.NewUser{
+			Name:     nu.Name,
+			Email:    *email,
+			Roles:    roles,
+			Password: nu.Password,
+		})
+		if err != nil {
+			return fmt.Errorf("create: %w", err)
+		}
+		return nil
+	})
+
+	return toUser(usr), err
+}
+
+// UserMod updates the user found by its id or email.
+func UserMod(cfg sqldb.Config, ref string, uu UpdateUser) (User, error) {
+	var updates userbus.UpdateUser
+	updates.Name = uu.Name
+	updates.Enabled = uu.Enabled
+
+	if uu.Email != nil {
+		email, err := mail.ParseAddress(*uu.Email)
+		if err != nil {
+			return User{}, fmt.Errorf("parsing email: %w", err)
+		}
+		updates.Email = email
+	}
+
+	if uu.Roles != nil {
+		roles, err := userbus.ParseSliceOfRoles(uu.Roles)
+		if err != nil {
+			return User{}, fmt.Errorf("parsing roles: %w", err)
+		}
+		updates.Roles = roles
+	}
+
+	var usr userbus.User
+	err := withUserBus(cfg, func(ctx context.Context, bus *userbus.UserBus) error {
+		current, err := lookupUser(ctx, bus, ref)
+		if err != nil {
+			return err
+		}
+
+		usr, err = bus.Update(ctx, current, updates)
+		if err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		return nil
+	})
+
+	return toUser(usr), err
+}
+
+// UserDel deletes the user found by its id or email and returns it.
+func UserDel(cfg sqldb.Config, ref string) (User, error) {
+	var usr userbus.User
+	err := withUserBus(cfg, func(ctx context.Context, bus *userbus.UserBus) error {
+		var err error
+		usr, err = lookupUser(ctx, bus, ref)
+		if err != nil {
+			return err
+		}
+
+		if err := bus.Delete(ctx, usr); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+		return nil
+	})
+
+	return toUser(usr), err
+}
+
+// UserList returns a page of users ordered by name.
+func UserList(cfg sqldb.Config, pageNumber int, rows int) (page.Document[User], error) {
+	pg, err := page.Parse(strconv.Itoa(pageNumber), strconv.Itoa(rows))
+	if err != nil {
+		return page.Document[User]{}, fmt.Errorf("parsing page: %w", err)
+	}
+
+	var doc page.Document[User]
+	err = withUserBus(cfg, func(ctx context.Context, bus *userbus.UserBus) error {
+		users, err := bus.Query(ctx, userbus.QueryFilter{}, order.NewBy(userbus.OrderByName, order.ASC), pg)
+		if err != nil {
+			return fmt.Errorf("query: %w", err)
+		}
+
+		total, err := bus.Count(ctx, userbus.QueryFilter{})
+		if err != nil {
+			return fmt.Errorf("count: %w", err)
+		}
+
+		items := make([]User, len(users))
+		for i, usr := range users {
+			items[i] = toUser(usr)
+		}
+
+		doc = page.NewDocument(items, total, pg)
+		return nil
+	})
+
+	return doc, err
+}
+
+// Passwd sets a new password for the user found by its id or email.
+func Passwd(cfg sqldb.Config, ref string, password string) (User, error) {
+	if err := userbus.CheckPassword(password); err != nil {
+		return User{}, err
+	}
+
+	var usr userbus.User
+	err := withUserBus(cfg, func(ctx context.Context, bus *userbus.UserBus) error {
+		current, err := lookupUser(ctx, bus, ref)
+		if err != nil {
+			return err
+		}
+
+		usr, err = bus.Update(ctx, current, userbus.UpdateUser{Password: &password})
+		if err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+		return nil
+	})
+
+	return toUser(usr), err
+}
+
+// ReadPassword reads the password from file when set, otherwise a single line from r, so passwords
+// never end up in the shell history or the process list. Terminal input is not echoed.
+func ReadPassword(file string, r io.Reader) (string, error) {
+	if file != "" {
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("reading password file: %w", err)
+		}
+		return strings.TrimRight(string(bs), "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "password: ")
+
+	if f, ok := r.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		pass, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("reading password: %w", err)
+		}
+		return string(pass), nil
+	}
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// storeLog receives the query logs of the stores, only warnings and errors reach the terminal.
+var storeLog = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+func withUserBus(cfg sqldb.Config, fn func(ctx context.Context, bus *userbus.UserBus) error) error {
+	db, err := sqldb.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	if err := sqldb.StatusCheck(ctx, db); err != nil {
+		return fmt.Errorf("statusCheck: %w", err)
+	}
+
+	return fn(ctx, userbus.New(userdb.NewStore(storeLog, db)))
+}
+
+// lookupUser finds a user by id when ref is a uuid, otherwise by email.
+func lookupUser(ctx context.Context, bus *userbus.UserBus, ref string) (userbus.User, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		usr, err := bus.QueryByID(ctx, id)
+		if err != nil {
+			return userbus.User{}, fmt.Errorf("query by id: %w", err)
+		}
+		return usr, nil
+	}
+
+	email, err := mail.ParseAddress(ref)
+	if err != nil {
+		return userbus.User{}, fmt.Errorf("%q is neither a user id nor an email", ref)
+	}
+
+	usr, err := bus.QueryByEmail(ctx, *email)
+	if err != nil {
+		return userbus.User{}, fmt.Errorf("query by email: %w", err)
+	}
+	return usr, nil
+}
@@ -0,0 +1,150 @@
+package commands_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/sales/cmd/admin/commands"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+)
+
+func TestUserCommands(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "admin_user_commands")
+	cfg := database.Config
+
+	if _, err := commands.UserAdd(cfg, commands.NewUser{
+		Name:     "John",
+		Email:    "john@gmail.com",
+		Roles:    []string{"USER"},
+		Password: "short",
+	}); !errors.Is(err, userbus.ErrPasswordTooShort) {
+		t.Fatalf("err=%v, got %v", userbus.ErrPasswordTooShort, err)
+	}
+
+	usr, err := commands.UserAdd(cfg, commands.NewUser{
+		Name:     "John",
+		Email:    "john@gmail.com",
+		Roles:    []string{"USER"},
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatalf("adding user: %s", err)
+	}
+
+	if usr.Email != "john@gmail.com" || !usr.Enabled {
+		t.Errorf("expected an enabled user with the given email, got %+v", usr)
+	}
+
+	//users are found by email as well as by id.
+	name := "Jane"
+	disabled := false
+	updated, err := commands.UserMod(cfg, usr.Email, commands.UpdateUser{
+		Name:    &name,
+		Roles:   []string{"ADMIN"},
+		Enabled: &disabled,
+	})
+	if err != nil {
+		t.Fatalf("modifying user: %s", err)
+	}
+
+	if updated.ID != usr.ID || updated.Name != name || updated.Enabled {
+		t.Errorf("expected the same user renamed and disabled, got %+v", updated)
+	}
+
+	if len(updated.Roles) != 1 || updated.Roles[0] != "ADMIN" {
+		t.Errorf("roles=%v, got %v", []string{"ADMIN"}, updated.Roles)
+	}
+
+	if _, err := commands.UserMod(cfg, "not-a-reference", commands.UpdateUser{Name: &name}); err == nil {
+		t.Error("expected a reference that is neither an id nor an email to fail")
+	}
+
+	if _, err := commands.Passwd(cfg, usr.ID, "short"); !errors.Is(err, userbus.ErrPasswordTooShort) {
+		t.Errorf("err=%v, got %v", userbus.ErrPasswordTooShort, err)
+	}
+
+	if _, err := commands.Passwd(cfg, usr.ID, "new-password"); err != nil {
+		t.Fatalf("changing password: %s", err)
+	}
+
+	if _, err := commands.UserAdd(cfg, commands.NewUser{
+		Name:     "Other",
+		Email:    "other@gmail.com",
+		Roles:    []string{"USER"},
+		Password: "password",
+	}); err != nil {
+		t.Fatalf("adding user: %s", err)
+	}
+
+	doc, err := commands.UserList(cfg, 1, 1)
+	if err != nil {
+		t.Fatalf("listing users: %s", err)
+	}
+
+	if doc.Total != 2 || len(doc.Items) != 1 {
+		t.Errorf("expected the first of 2 users, got %d of %d", len(doc.Items), doc.Total)
+	}
+
+	//users are listed by name.
+	if doc.Items[0].Name != name {
+		t.Errorf("name=%s, got %s", name, doc.Items[0].Name)
+	}
+
+	deleted, err := commands.UserDel(cfg, usr.ID)
+	if err != nil {
+		t.Fatalf("deleting user: %s", err)
+	}
+
+	if deleted.ID != usr.ID {
+		t.Errorf("id=%s, got %s", usr.ID, deleted.ID)
+	}
+
+	if _, err := commands.UserDel(cfg, usr.ID); !errors.Is(err, userbus.ErrUserNotFound) {
+		t.Errorf("err=%v, got %v", userbus.ErrUserNotFound, err)
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("writing password file: %s", err)
+	}
+
+	tests := map[string]struct {
+		file     string
+		input    string
+		expected string
+	}{
+		"file_wins":           {file: file, input: "from-stdin\n", expected: "from-file"},
+		"reads_a_line":        {input: "from-stdin\nsecond-line\n", expected: "from-stdin"},
+		"crlf_is_trimmed":     {input: "from-stdin\r\n", expected: "from-stdin"},
+		"no_trailing_newline": {input: "from-stdin", expected: "from-stdin"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := commands.ReadPassword(test.file, strings.NewReader(test.input))
+			if err != nil {
+				t.Fatalf("reading password: %s", err)
+			}
+
+			if got != test.expected {
+				t.Errorf("password=%q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestReadPasswordMissingFile(t *testing.T) {
+	if _, err := commands.ReadPassword(filepath.Join(t.TempDir(), "missing"), strings.NewReader("from-stdin\n")); err == nil {
+		t.Fatal("expected a missing password file to fail instead of falling back to stdin")
+	}
+}
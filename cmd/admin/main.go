@@ -1,82 +1,526 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hamidoujand/sales/cmd/admin/commands"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/seed"
 	"github.com/hamidoujand/sales/internal/sqldb"
+	"github.com/hamidoujand/sales/pkg/keystore"
 )
 
+// legacy maps the old flat command names to their place in the tree, so existing scripts and
+// deployments keep working.
+var legacy = map[string][]string{
+	"genkey":   {"key", "gen"},
+	"gentoken": {"token", "gen"},
+	"useradd":  {"user", "add"},
+	"usermod":  {"user", "mod"},
+	"userdel":  {"user", "del"},
+	"userlist": {"user", "list"},
+	"passwd":   {"user", "passwd"},
+}
+
 func main() {
-	if err := run(); err != nil {
-		fmt.Println("admin <subcommand> [...args]")
-		fmt.Println("==========================SubCommands===========================")
-		fmt.Println("genkey: generate a set of private/public key files.")
-		fmt.Println("gentoken: generate a JWT token for userid using a key.")
-		fmt.Println("migrate: migrates the database.")
-		fmt.Println("=====================================================")
-		fmt.Println(err)
+	if err := execute(root(), resolveLegacy(os.Args[1:])); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	if len(os.Args) < 2 {
-		return errors.New("expected subcommands to be passed")
+func root() *command {
+	return &command{
+		name:  "admin",
+		short: "Administrative tasks of the sales service. Flags fall back to the SALES_* env vars of the service.",
+		subs: []*command{
+			{
+				name:  "key",
+				short: "Manage signing keys.",
+				subs:  []*command{keyGen()},
+			},
+			{
+				name:  "token",
+				short: "Manage tokens.",
+				subs:  []*command{tokenGen()},
+			},
+			migrate(),
+			{
+				name:  "user",
+				short: "Manage users.",
+				subs:  []*command{userAdd(), userMod(), userDel(), userList(), userPasswd()},
+			},
+			seedCmd(),
+		},
+	}
+}
+
+// resolveLegacy rewrites a legacy command name into its path in the tree.
+func resolveLegacy(args []string) []string {
+	if len(args) == 0 {
+		return args
 	}
 
-	switch os.Args[1] {
-	case "genkey":
-		genkeyCommand := flag.NewFlagSet("genkey", flag.ExitOnError)
-		keySize := genkeyCommand.Int("size", 2048, "key size in bits.")
-		//parse the args
-		genkeyCommand.Parse(os.Args[2:])
-		if err := commands.GenerateKey(*keySize); err != nil {
-			return fmt.Errorf("generateKey: %w", err)
-		}
-	case "gentoken":
-		genTokenCommand := flag.NewFlagSet("gentoken", flag.ExitOnError)
-		userID := genTokenCommand.String("userid", "", "id of the userbus that token will belong.")
-		kid := genTokenCommand.String("kid", "", "ID of the private key used to sign the token.")
-		keyPath := genTokenCommand.String("keypath", "infra/keys", "path to the dir the holds private and public key pairs.")
+	path, ok := legacy[args[0]]
+	if !ok {
+		return args
+	}
+
+	return append(append([]string{}, path...), args[1:]...)
+}
+
+// =============================================================================
+// Keys and tokens
+
+func keyGen() *command {
+	return &command{
+		name:  "gen",
+		short: "Generate a private/public key pair and mark it active.",
+		flags: func(fs *flagSet) runFunc {
+			size := fs.Int("size", 2048, "key size in bits, only used by RS256.")
+			alg := fs.String("alg", commands.AlgRS256, "key algorithm: RS256, ES256, ES384 or EdDSA.")
+			outDir := fs.String("output", "keys", "directory the key files are written to.")
+			passFile := fs.String("passfile", "", "file holding the passphrase to encrypt the private key.")
+			fs.bindEnv("output", "AUTH_KEYS_DIR")
+			fs.bindEnv("passfile", "AUTH_KEYS_PASSPHRASE_FILE")
+
+			return func(args []string, out *output) error {
+				passphrase, err := keystore.ReadPassphrase(os.Getenv(envPrefix+"_AUTH_KEYS_PASSPHRASE"), *passFile)
+				if err != nil {
+					return fmt.Errorf("reading passphrase: %w", err)
+				}
+
+				key, err := commands.GenerateKey(commands.KeyConfig{
+					Alg:        *alg,
+					Size:       *size,
+					Passphrase: passphrase,
+					Output:     *outDir,
+				})
+				if err != nil {
+					return fmt.Errorf("generate key: %w", err)
+				}
+
+				return out.print(key, func(w io.Writer) {
+					fmt.Fprintf(w, "kid\t%s\n", key.KID)
+					fmt.Fprintf(w, "alg\t%s\n", key.Alg)
+					fmt.Fprintf(w, "private key\t%s\n", key.PrivateKey)
+					fmt.Fprintf(w, "public key\t%s\n", key.PublicKey)
+					fmt.Fprintf(w, "encrypted\t%t\n", key.Encrypted)
+				})
+			}
+		},
+	}
+}
+
+func tokenGen() *command {
+	return &command{
+		name:  "gen",
+		short: "Generate an ADMIN token for a user.",
+		flags: func(fs *flagSet) runFunc {
+			userID := fs.String("userid", "", "id of the user the token belongs to.")
+			kid := fs.String("kid", "", "id of the private key used to sign the token, the active key when empty.")
+			keyPath := fs.String("keypath", "keys", "path to the dir that holds the private and public key pairs.")
+			issuer := fs.String("issuer", "admin-cli", "issuer of the token, must be accepted by SALES_AUTH_ISSUERS.")
+			aud := fs.String("aud", "sales-api", "semicolon separated audiences, must match SALES_AUTH_AUDIENCES.")
+			ttl := fs.Duration("ttl", time.Hour, "lifetime of the token.")
+			passFile := fs.String("passfile", "", "file holding the passphrase of encrypted private keys.")
+			fs.bindEnv("keypath", "AUTH_KEYS_DIR")
+			fs.bindEnv("aud", "AUTH_AUDIENCES")
+			fs.bindEnv("ttl", "AUTH_TOKEN_TTL")
+			fs.bindEnv("passfile", "AUTH_KEYS_PASSPHRASE_FILE")
+
+			return func(args []string, out *output) error {
+				if *userID == "" {
+					return fmt.Errorf("%w: userid is required", errUsage)
+				}
+
+				passphrase, err := keystore.ReadPassphrase(os.Getenv(envPrefix+"_AUTH_KEYS_PASSPHRASE"), *passFile)
+				if err != nil {
+					return fmt.Errorf("reading passphrase: %w", err)
+				}
+
+				tkn, err := commands.GenerateToken(commands.TokenConfig{
+					KeyPath:    *keyPath,
+					UserID:     *userID,
+					KID:        *kid,
+					Issuer:     *issuer,
+					Audiences:  strings.Split(*aud, ";"),
+					TTL:        *ttl,
+					Passphrase: passphrase,
+				})
+				if err != nil {
+					return fmt.Errorf("generate token: %w", err)
+				}
+
+				return out.print(tkn, func(w io.Writer) {
+					fmt.Fprintf(w, "token\t%s\n", tkn.Token)
+					fmt.Fprintf(w, "kid\t%s\n", tkn.KID)
+					fmt.Fprintf(w, "expires at\t%s\n", tkn.ExpiresAt.Format(time.RFC3339))
+				})
+			}
+		},
+	}
+}
 
-		genTokenCommand.Parse(os.Args[2:])
+// =============================================================================
+// Migrations
 
-		if *userID == "" || *kid == "" {
-			fmt.Println("Usage: gentoken kid=<key id> userid=<userbus id> [keypath=<path to keys folder>]")
-			return errors.New("kid and userid are required")
+func migrate() *command {
+	action := func(name string, args string, short string) *command {
+		return &command{
+			name:  name,
+			args:  args,
+			short: short,
+			flags: func(fs *flagSet) runFunc {
+				dbConfig := dbFlags(fs)
+				return func(args []string, out *output) error {
+					return runMigrate(dbConfig(), name, args, out)
+				}
+			},
 		}
+	}
+
+	return &command{
+		name:  "migrate",
+		short: "Apply every pending migration, or run one of the migration commands.",
+		flags: func(fs *flagSet) runFunc {
+			dbConfig := dbFlags(fs)
+			return func(args []string, out *output) error {
+				if len(args) > 0 {
+					return fmt.Errorf("%w: unknown migrate command %q", errUsage, args[0])
+				}
+				return runMigrate(dbConfig(), "up", nil, out)
+			}
+		},
+		subs: []*command{
+			action("status", "", "Print the version of the schema and every migration, exits non-zero when dirty."),
+			action("up", "[N]", "Apply the next N migrations, every pending one when N is omitted."),
+			action("down", "[N]", "Roll back the last N migrations, 1 when N is omitted."),
+			action("goto", "V", "Migrate up or down to version V."),
+			action("force", "V", "Set the version to V and clear the dirty flag, after fixing a failed migration by hand."),
+			{
+				name:  "new",
+				args:  "NAME",
+				short: "Create the next numbered up and down migration files.",
+				flags: func(fs *flagSet) runFunc {
+					outDir := fs.String("output", commands.MigrationsDir, "directory the migration files are created in.")
+
+					return func(args []string, out *output) error {
+						if len(args) == 0 {
+							return fmt.Errorf("%w: migration name is required", errUsage)
+						}
+
+						paths, err := commands.NewMigration(*outDir, args[0])
+						if err != nil {
+							return fmt.Errorf("migrate new: %w", err)
+						}
+
+						return out.print(paths, func(w io.Writer) {
+							for _, path := range paths {
+								fmt.Fprintf(w, "created\t%s\n", path)
+							}
+						})
+					}
+				},
+			},
+		},
+	}
+}
+
+func runMigrate(cfg sqldb.Config, action string, args []string, out *output) error {
+	status, err := commands.Migrate(cfg, action, args)
 
-		if err := commands.GenerateToken(*keyPath, *userID, *kid); err != nil {
-			fmt.Println("Usage: gentoken kid=<key id> userid=<userbus id> [keypath=<path to keys folder>]")
-			return fmt.Errorf("generate token: %w", err)
+	//the status is printed even on failure, unless we never got to the database.
+	if status.Database != "" {
+		if printErr := out.print(status, func(w io.Writer) { printStatus(w, status) }); printErr != nil {
+			return printErr
 		}
-	case "migrate":
-		migrateCommand := flag.NewFlagSet("migrate", flag.ExitOnError)
-		user := migrateCommand.String("user", "postgres", "user is the database user.")
-		pass := migrateCommand.String("pass", "password", "password for the database user.")
-		host := migrateCommand.String("host", "localhost:5432", "database host.")
-		db := migrateCommand.String("dbname", "postgres", "name of the db that you want to run migrations againt it.")
-
-		migrateCommand.Parse(os.Args[2:])
-		cfg := sqldb.Config{
+	}
+
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", action, err)
+	}
+	return nil
+}
+
+func printStatus(w io.Writer, status commands.MigrationStatus) {
+	fmt.Fprintf(w, "database\t%s\n", status.Database)
+	fmt.Fprintf(w, "version\t%d\n", status.Version)
+	fmt.Fprintf(w, "dirty\t%t\n", status.Dirty)
+	fmt.Fprintf(w, "pending\t%d\n\n", status.Pending)
+
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE")
+	for _, m := range status.Migrations {
+		fmt.Fprintf(w, "%06d\t%s\t%s\n", m.Version, m.Name, m.State)
+	}
+}
+
+// =============================================================================
+// Users
+
+func userAdd() *command {
+	return &command{
+		name:  "add",
+		short: "Create a user, the password is read from stdin unless a password file is given.",
+		flags: func(fs *flagSet) runFunc {
+			dbConfig := dbFlags(fs)
+			name := fs.String("name", "", "name of the user.")
+			email := fs.String("email", "", "email of the user.")
+			roles := fs.String("roles", "USER", "comma separated roles: USER, ADMIN.")
+			passFile := fs.String("password-file", "", "file holding the password of the user.")
+
+			return func(args []string, out *output) error {
+				if *name == "" || *email == "" {
+					return fmt.Errorf("%w: name and email are required", errUsage)
+				}
+
+				pass, err := commands.ReadPassword(*passFile, os.Stdin)
+				if err != nil {
+					return err
+				}
+
+				usr, err := commands.UserAdd(dbConfig(), commands.NewUser{
+					Name:     *name,
+					Email:    *email,
+					Roles:    strings.Split(*roles, ","),
+					Password: pass,
+				})
+				if err != nil {
+					return fmt.Errorf("user add: %w", err)
+				}
+
+				return out.print(usr, func(w io.Writer) { printUser(w, usr) })
+			}
+		},
+	}
+}
+
+func userMod() *command {
+	return &command{
+		name:  "mod",
+		short: "Update the name, email, roles or enabled state of a user, only the passed flags are changed.",
+		flags: func(fs *flagSet) runFunc {
+			dbConfig := dbFlags(fs)
+			user := fs.String("u", "", "id or email of the user.")
+			name := fs.String("name", "", "new name.")
+			email := fs.String("email", "", "new email.")
+			roles := fs.String("roles", "", "new comma separated roles: USER, ADMIN.")
+			enabled := fs.String("enabled", "", "true or false.")
+
+			return func(args []string, out *output) error {
+				if *user == "" {
+					return fmt.Errorf("%w: u is required", errUsage)
+				}
+
+				var uu commands.UpdateUser
+				var parseErr error
+				fs.Visit(func(f *flag.Flag) {
+					switch f.Name {
+					case "name":
+						uu.Name = name
+					case "email":
+						uu.Email = email
+					case "roles":
+						uu.Roles = strings.Split(*roles, ",")
+					case "enabled":
+						v, err := strconv.ParseBool(*enabled)
+						if err != nil {
+							parseErr = fmt.Errorf("parsing enabled: %w", err)
+							return
+						}
+						uu.Enabled = &v
+					}
+				})
+
+				if parseErr != nil {
+					return parseErr
+				}
+
+				usr, err := commands.UserMod(dbConfig(), *user, uu)
+				if err != nil {
+					return fmt.Errorf("user mod: %w", err)
+				}
+
+				return out.print(usr, func(w io.Writer) { printUser(w, usr) })
+			}
+		},
+	}
+}
+
+func userDel() *command {
+	return &command{
+		name:  "del",
+		short: "Delete a user.",
+		flags: func(fs *flagSet) runFunc {
+			dbConfig := dbFlags(fs)
+			user := fs.String("u", "", "id or email of the user.")
+
+			return func(args []string, out *output) error {
+				if *user == "" {
+					return fmt.Errorf("%w: u is required", errUsage)
+				}
+
+				usr, err := commands.UserDel(dbConfig(), *user)
+				if err != nil {
+					return fmt.Errorf("user del: %w", err)
+				}
+
+				return out.print(usr, func(w io.Writer) {
+					fmt.Fprintf(w, "deleted\t%s\t%s\n", usr.ID, usr.Email)
+				})
+			}
+		},
+	}
+}
+
+func userList() *command {
+	return &command{
+		name:  "list",
+		short: "List the users ordered by name.",
+		flags: func(fs *flagSet) runFunc {
+			dbConfig := dbFlags(fs)
+			pageNumber := fs.Int("page", 1, "page number.")
+			rows := fs.Int("rows", 20, "rows per page.")
+
+			return func(args []string, out *output) error {
+				doc, err := commands.UserList(dbConfig(), *pageNumber, *rows)
+				if err != nil {
+					return fmt.Errorf("user list: %w", err)
+				}
+
+				return out.print(doc, func(w io.Writer) { printUsers(w, doc) })
+			}
+		},
+	}
+}
+
+func userPasswd() *command {
+	return &command{
+		name:  "passwd",
+		short: "Change the password of a user, the password is read from stdin unless a password file is given.",
+		flags: func(fs *flagSet) runFunc {
+			dbConfig := dbFlags(fs)
+			user := fs.String("u", "", "id or email of the user.")
+			passFile := fs.String("password-file", "", "file holding the new password.")
+
+			return func(args []string, out *output) error {
+				if *user == "" {
+					return fmt.Errorf("%w: u is required", errUsage)
+				}
+
+				pass, err := commands.ReadPassword(*passFile, os.Stdin)
+				if err != nil {
+					return err
+				}
+
+				usr, err := commands.Passwd(dbConfig(), *user, pass)
+				if err != nil {
+					return fmt.Errorf("user passwd: %w", err)
+				}
+
+				return out.print(usr, func(w io.Writer) {
+					fmt.Fprintf(w, "password changed\t%s\t%s\n", usr.ID, usr.Email)
+				})
+			}
+		},
+	}
+}
+
+func printUser(w io.Writer, usr commands.User) {
+	fmt.Fprintf(w, "id\t%s\n", usr.ID)
+	fmt.Fprintf(w, "name\t%s\n", usr.Name)
+	fmt.Fprintf(w, "email\t%s\n", usr.Email)
+	fmt.Fprintf(w, "roles\t%s\n", strings.Join(usr.Roles, ","))
+	fmt.Fprintf(w, "enabled\t%t\n", usr.Enabled)
+}
+
+func printUsers(w io.Writer, doc page.Document[commands.User]) {
+	fmt.Fprintln(w, "ID\tNAME\tEMAIL\tROLES\tENABLED\tCREATED")
+	for _, usr := range doc.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n",
+			usr.ID,
+			usr.Name,
+			usr.Email,
+			strings.Join(usr.Roles, ","),
+			usr.Enabled,
+			usr.DateCreated.Format(time.DateTime),
+		)
+	}
+	fmt.Fprintf(w, "\npage %d, %d of %d users\n", doc.Page, len(doc.Items), doc.Total)
+}
+
+// =============================================================================
+// Seed
+
+func seedCmd() *command {
+	return &command{
+		name:  "seed",
+		short: "Fill the database with fixture data, the same seed always produces the same data.",
+		flags: func(fs *flagSet) runFunc {
+			dbConfig := dbFlags(fs)
+			seedValue := fs.Uint64("seed", seed.DefaultConfig.Seed, "random seed.")
+			users := fs.Int("users", seed.DefaultConfig.Users, "number of generated users.")
+			admins := fs.Float64("admins", seed.DefaultConfig.AdminRatio, "share of generated users with the ADMIN role.")
+			disabled := fs.Float64("disabled", seed.DefaultConfig.DisabledRatio, "share of generated users that are disabled.")
+			products := fs.Int("products", seed.DefaultConfig.Products, "number of generated products.")
+			orders := fs.Int("orders", seed.DefaultConfig.Orders, "number of generated orders.")
+			dev := fs.Bool("dev", false, "also create admin@example.com and user@example.com with a well-known password, never use it outside development.")
+
+			return func(args []string, out *output) error {
+				res, err := commands.Seed(dbConfig(), seed.Config{
+					Seed:          *seedValue,
+					Accounts:      *dev,
+					Users:         *users,
+					AdminRatio:    *admins,
+					DisabledRatio: *disabled,
+					Products:      *products,
+					Orders:        *orders,
+				})
+				if err != nil {
+					return fmt.Errorf("seed: %w", err)
+				}
+
+				return out.print(res, func(w io.Writer) {
+					fmt.Fprintf(w, "seed\t%d\n", res.Seed)
+					fmt.Fprintf(w, "users created\t%d\n", res.Users)
+					fmt.Fprintf(w, "products created\t%d\n", res.Products)
+					fmt.Fprintf(w, "orders created\t%d\n", res.Orders)
+					fmt.Fprintf(w, "skipped\t%d\n", res.Skipped)
+				})
+			}
+		},
+	}
+}
+
+// =============================================================================
+
+// dbFlags registers the database flags shared by every command that talks to the database, they
+// fall back to the same env vars the sales service reads.
+func dbFlags(fs *flagSet) func() sqldb.Config {
+	user := fs.String("user", "postgres", "user is the database user.")
+	pass := fs.String("pass", "password", "password for the database user.")
+	host := fs.String("host", "localhost:5432", "database host.")
+	db := fs.String("dbname", "postgres", "name of the database.")
+	disableTLS := fs.Bool("disabletls", true, "disable TLS to the database.")
+	fs.bindEnv("user", "DB_USER")
+	fs.bindEnv("pass", "DB_PASSWORD")
+	fs.bindEnv("host", "DB_HOST")
+	fs.bindEnv("dbname", "DB_NAME")
+	fs.bindEnv("disabletls", "DB_DISABLE_TLS")
+
+	return func() sqldb.Config {
+		return sqldb.Config{
 			Host:       *host,
 			Password:   *pass,
 			User:       *user,
 			Name:       *db,
-			DisableTLS: true,
-		}
-		if err := commands.Migrate(cfg); err != nil {
-			fmt.Println("Usage: migrate host=<db_host> user=<db_user> pass=<db_pass> dbname=<name_of_db>")
-			return fmt.Errorf("migrate: %w", err)
+			DisableTLS: *disableTLS,
 		}
-
-	default:
-		return fmt.Errorf("unknown command %q", os.Args[1])
 	}
-
-	return nil
 }
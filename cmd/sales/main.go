@@ -16,11 +16,14 @@ import (
 	"time"
 
 	"github.com/ardanlabs/conf/v3"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/hamidoujand/sales/api/handlers"
 	"github.com/hamidoujand/sales/internal/auth"
 	"github.com/hamidoujand/sales/internal/debug"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus/tokendb"
 	"github.com/hamidoujand/sales/internal/sqldb"
+	"github.com/hamidoujand/sales/internal/tracing"
+	"github.com/hamidoujand/sales/pkg/jwks"
 	"github.com/hamidoujand/sales/pkg/keystore"
 )
 
@@ -50,9 +53,27 @@ func run(logger *slog.Logger) error {
 		}
 
 		Auth struct {
-			KeysDir       string `conf:"default:keys"`
-			SigningMethod string `conf:"default:RS256"`
-			Issuer        string `conf:"default:auth-service"`
+			KeysDir            string        `conf:"default:keys"`
+			KeysReload         time.Duration `conf:"default:30s"`
+			KeysGrace          time.Duration `conf:"default:2h"` //must outlive TokenTTL so rotated tokens still verify.
+			KeysPassphrase     string        `conf:"mask"`
+			KeysPassphraseFile string
+			VaultAddr          string        //keys are read from a Vault KV v2 secret instead of KeysDir when set.
+			VaultToken         string        `conf:"mask"`
+			VaultMount         string        `conf:"default:secret"`
+			VaultPath          string        `conf:"default:sales/keys"`
+			Issuer             string        `conf:"default:auth-service"`
+			Issuers            []string      `conf:"default:auth-service;admin-cli"`
+			Audiences          []string      `conf:"default:sales-api"`
+			ClockSkew          time.Duration `conf:"default:30s"`
+			TokenTTL           time.Duration `conf:"default:1h"`
+			RefreshTTL         time.Duration `conf:"default:720h"`
+			JWKSURL            string
+			JWKSIssuers        []string      //issuers allowed to sign with the keys of JWKSURL.
+			JWKSTTL            time.Duration `conf:"default:5m"`
+			PolicyPath         string
+			PolicyRequired     bool          //refuse to start on the embedded policies when PolicyPath is broken.
+			PolicyReload       time.Duration `conf:"default:30s"`
 		}
 
 		DB struct {
@@ -64,6 +85,13 @@ func run(logger *slog.Logger) error {
 			MaxOpenConns int    `conf:"default:0"`
 			DisableTLS   bool   `conf:"default:true"`
 		}
+
+		Tracing struct {
+			Endpoint    string  //host:port of the OTLP/HTTP collector, tracing is off when empty.
+			URLPath     string  `conf:"default:/v1/traces"`
+			Insecure    bool    //sends spans over plain http, only meant for a local collector.
+			Probability float64 `conf:"default:0.05"`
+		}
 	}{}
 
 	help, err := conf.Parse("SALES", &cfg)
@@ -99,14 +127,25 @@ func run(logger *slog.Logger) error {
 	}()
 
 	//==========================================================================
-	// Auth init
-	ks := keystore.New()
-	activeKid, err := ks.LoadKeys(os.DirFS(cfg.Auth.KeysDir))
+	// Tracing
+
+	flushTraces, err := tracing.Init(logger, tracing.Config{
+		ServiceName: "sales",
+		Build:       build,
+		Endpoint:    cfg.Tracing.Endpoint,
+		URLPath:     cfg.Tracing.URLPath,
+		Insecure:    cfg.Tracing.Insecure,
+		Probability: cfg.Tracing.Probability,
+	})
 	if err != nil {
-		return fmt.Errorf("loading keys into key store: %w", err)
+		return fmt.Errorf("init tracing: %w", err)
 	}
-	authClient := auth.New(ks, jwt.GetSigningMethod(cfg.Auth.SigningMethod), cfg.Auth.Issuer, activeKid)
-	logger.Info("auth", "activeKID", activeKid)
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
+		defer cancel()
+		flushTraces(ctx)
+	}()
 
 	//==========================================================================
 	// Database
@@ -129,13 +168,99 @@ func run(logger *slog.Logger) error {
 
 	defer db.Close()
 
+	//==========================================================================
+	// Auth init
+	passphrase, err := keystore.ReadPassphrase(cfg.Auth.KeysPassphrase, cfg.Auth.KeysPassphraseFile)
+	if err != nil {
+		return fmt.Errorf("reading keys passphrase: %w", err)
+	}
+
+	var keysBackend keystore.Backend = keystore.NewFSBackend(os.DirFS(cfg.Auth.KeysDir))
+	if cfg.Auth.VaultAddr != "" {
+		keysBackend = keystore.NewVaultBackend(cfg.Auth.VaultAddr, cfg.Auth.VaultToken, cfg.Auth.VaultMount, cfg.Auth.VaultPath)
+		logger.Info("auth", "keys", "vault", "addr", cfg.Auth.VaultAddr, "path", cfg.Auth.VaultPath)
+	}
+
+	ks := keystore.NewWithConfig(keystore.Config{
+		GracePeriod: cfg.Auth.KeysGrace,
+		Passphrase:  passphrase,
+	})
+	activeKid, err := ks.Load(context.Background(), keysBackend)
+	if err != nil {
+		return fmt.Errorf("loading keys into key store: %w", err)
+	}
+	tokenBus := tokenbus.New(tokendb.NewStore(logger, db), cfg.Auth.RefreshTTL)
+
+	//tokens of an external identity provider are verified through its key set.
+	var externalKeys []auth.KeySource
+	if cfg.Auth.JWKSURL != "" {
+		if len(cfg.Auth.JWKSIssuers) == 0 {
+			return errors.New("jwks issuers are required when a jwks url is set")
+		}
+
+		externalKeys = append(externalKeys, auth.KeySource{
+			Keys:    jwks.NewLookup(cfg.Auth.JWKSURL, cfg.Auth.JWKSTTL, time.Second*10),
+			Issuers: cfg.Auth.JWKSIssuers,
+		})
+		logger.Info("auth", "jwksURL", cfg.Auth.JWKSURL, "jwksIssuers", cfg.Auth.JWKSIssuers)
+	}
+
+	authClient, err := auth.New(auth.Config{
+		KeyLookup:      ks,
+		Issuer:         cfg.Auth.Issuer,
+		Issuers:        cfg.Auth.Issuers,
+		Audiences:      cfg.Auth.Audiences,
+		ClockSkew:      cfg.Auth.ClockSkew,
+		ExternalKeys:   externalKeys,
+		Revocations:    tokenBus,
+		PolicyPath:     cfg.Auth.PolicyPath,
+		PolicyRequired: cfg.Auth.PolicyRequired,
+		Log:            logger,
+	})
+	if err != nil {
+		return fmt.Errorf("creating auth: %w", err)
+	}
+	logger.Info("auth", "activeKID", activeKid)
+
+	policyCtx, stopPolicyWatch := context.WithCancel(context.Background())
+	defer stopPolicyWatch()
+	go authClient.WatchPolicies(policyCtx, cfg.Auth.PolicyReload)
+
+	//keys are rotated by dropping a new key and updating active.txt, either wait for the watcher
+	//or send SIGHUP to pick it up right away.
+	keysCtx, stopKeysWatch := context.WithCancel(context.Background())
+	defer stopKeysWatch()
+	go ks.Watch(keysCtx, cfg.Auth.KeysReload, logger)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	go func() {
+		for range reload {
+			if err := ks.Reload(context.Background()); err != nil {
+				logger.Error("keystore reload", "signal", "SIGHUP", "err", err)
+				continue
+			}
+			logger.Info("keystore reload", "signal", "SIGHUP", "activeKID", ks.ActiveKID())
+		}
+	}()
+
 	//==========================================================================
 	// API server
 	shutdown := make(chan os.Signal, 1)
 	errCh := make(chan error, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
-	mux := handlers.APIMux(build, logger, db, authClient)
+	mux := handlers.APIMux(handlers.Config{
+		Build:    build,
+		Log:      logger,
+		DB:       db,
+		Auth:     authClient,
+		TokenBus: tokenBus,
+		TokenTTL: cfg.Auth.TokenTTL,
+		Keys:     ks,
+	})
 
 	server := &http.Server{
 		Addr:        cfg.Web.APIHost,
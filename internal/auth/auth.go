@@ -2,22 +2,35 @@ package auth
 
 import (
 	"context"
-	"crypto/rsa"
+	"crypto"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "embed"
-
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/tracing"
 	"github.com/open-policy-agent/opa/v1/rego"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
 	ErrUnauthenticated = errors.New("request does not have valid authentication credentials for the operation")
+	ErrRevoked         = errors.New("token has been revoked")
 )
 
+func init() {
+	errs.Register(ErrUnauthenticated, errs.Spec{Code: "UNAUTHENTICATED", Status: http.StatusUnauthorized, Message: "authentication required"})
+	errs.Register(ErrRevoked, errs.Spec{Code: "TOKEN_REVOKED", Status: http.StatusUnauthorized, Message: "token has been revoked"})
+}
+
 const (
 	RuleAnybody      = "rule_any"
 	RuleAdmin        = "rule_admin_only"
@@ -25,18 +38,49 @@ const (
 	RuleAdminOrOwner = "rule_admin_or_owner"
 )
 
-var (
-	//go:embed rego/authentication.rego
-	regoAuthentication string
+// rules is the set of authorization rules that are prepared when Auth is created.
+var rules = []string{RuleAnybody, RuleAdmin, RuleUser, RuleAdminOrOwner}
 
-	//go:embed rego/authorization.rego
-	regoAuthorization string
+const (
+	authenticationPackage = "token_validation"
+	authorizationPackage  = "role_validation"
 )
 
 // KeyLookup defines the required behavior in order to get private and public keys for JWT token operations.
+// Keys can be RSA, ECDSA (P-256, P-384) or Ed25519, the signing method is picked from the key type.
 type KeyLookup interface {
-	PrivateKey(kid string) (*rsa.PrivateKey, error)
-	PublicKey(kid string) (*rsa.PublicKey, error)
+	PrivateKey(kid string) (crypto.Signer, error)
+	PublicKey(kid string) (crypto.PublicKey, error)
+}
+
+// ActiveKeyLookup is implemented by key stores that rotate their signing key, the active kid is
+// asked on every signing when Config.ActiveKID is empty.
+type ActiveKeyLookup interface {
+	ActiveKID() string
+}
+
+// PublicKeyLookup defines the required behavior in order to get public keys of tokens issued by
+// someone else, like an external identity provider.
+type PublicKeyLookup interface {
+	PublicKey(kid string) (crypto.PublicKey, error)
+}
+
+// ContextPublicKeyLookup is implemented by public key lookups that reach out to the network, the
+// lookup is then bounded by the context of the token being verified.
+type ContextPublicKeyLookup interface {
+	PublicKeyContext(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// KeySource ties the public keys of someone else to the issuers allowed to sign with them, so a
+// token verified by an external key can not claim to be issued by this service.
+type KeySource struct {
+	Keys    PublicKeyLookup
+	Issuers []string
+}
+
+// RevocationList defines the required behavior in order to find out if a token is revoked before it expires.
+type RevocationList interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
 }
 
 type Claims struct {
@@ -44,36 +88,117 @@ type Claims struct {
 	Roles []string `json:"roles"`
 }
 
+// Config represents the required settings for creating an Auth.
+type Config struct {
+	KeyLookup      KeyLookup
+	Issuer         string
+	ActiveKID      string         //optional when KeyLookup implements ActiveKeyLookup.
+	Issuers        []string       //optional, accepted issuers of tokens signed by KeyLookup, defaults to Issuer.
+	Audiences      []string       //optional, accepted audiences, set on generated tokens.
+	ClockSkew      time.Duration  //optional, tolerance for exp, nbf and iat.
+	ExternalKeys   []KeySource    //optional, consulted in order when a kid is not found in KeyLookup.
+	Revocations    RevocationList //optional
+	PolicyPath     string         //optional, directory or bundle tarball of rego policies.
+	PolicyRequired bool           //optional, New fails instead of using the embedded policies when PolicyPath can not be loaded.
+	Log            *slog.Logger   //optional
+}
+
 type Auth struct {
-	store         KeyLookup
-	signingMethod jwt.SigningMethod
-	issuer        string
-	activeKID     string
+	store        KeyLookup
+	issuer       string
+	activeKID    string
+	issuers      []string
+	audiences    []string
+	clockSkew    time.Duration
+	externalKeys []KeySource
+	revocations  RevocationList
+	log          *slog.Logger
+
+	//rego queries are compiled once and are safe for concurrent evaluation.
+	policies          atomic.Pointer[policies]
+	policyPath        string
+	policyRequired    bool
+	reloadMu          sync.Mutex
+	failedFingerprint string
 }
 
-func New(keyLookup KeyLookup, signingMethod jwt.SigningMethod, issuer string, activeKid string) *Auth {
+// New creates an Auth and compiles the rego policies, so every request only evaluates them.
+func New(cfg Config) (*Auth, error) {
+	log := cfg.Log
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	issuers := cfg.Issuers
+	if len(issuers) == 0 {
+		issuers = []string{cfg.Issuer}
+	}
+
+	for i, src := range cfg.ExternalKeys {
+		if src.Keys == nil || len(src.Issuers) == 0 {
+			return nil, fmt.Errorf("external key source %d needs keys and issuers", i)
+		}
+	}
+
+	audiences := cfg.Audiences
+	if audiences == nil {
+		audiences = []string{}
+	}
+
 	a := Auth{
-		store:         keyLookup,
-		signingMethod: signingMethod,
-		issuer:        issuer,
-		activeKID:     activeKid,
+		store:          cfg.KeyLookup,
+		issuer:         cfg.Issuer,
+		activeKID:      cfg.ActiveKID,
+		issuers:        issuers,
+		audiences:      audiences,
+		clockSkew:      cfg.ClockSkew,
+		externalKeys:   cfg.ExternalKeys,
+		revocations:    cfg.Revocations,
+		log:            log,
+		policyPath:     cfg.PolicyPath,
+		policyRequired: cfg.PolicyRequired,
 	}
-	return &a
+
+	if err := a.initPolicies(context.Background()); err != nil {
+		return nil, fmt.Errorf("init policies: %w", err)
+	}
+
+	return &a, nil
 }
 
-// GenerateToken generates a jwt token based on the given claims.
+// GenerateToken generates a jwt token based on the given claims, a random jti and the configured
+// audiences are set when claims does not have them.
 func (a *Auth) GenerateToken(claims Claims) (string, error) {
 	claims.RegisteredClaims.Issuer = a.issuer
+	if claims.RegisteredClaims.ID == "" {
+		claims.RegisteredClaims.ID = uuid.NewString()
+	}
 
-	token := jwt.NewWithClaims(a.signingMethod, claims)
-	token.Header["kid"] = a.activeKID
+	if len(claims.RegisteredClaims.Audience) == 0 && len(a.audiences) > 0 {
+		claims.RegisteredClaims.Audience = a.audiences
+	}
 
 	//load the key
-	privateKey, err := a.store.PrivateKey(a.activeKID)
+	kid := a.activeKID
+	if kid == "" {
+		if active, ok := a.store.(ActiveKeyLookup); ok {
+			kid = active.ActiveKID()
+		}
+	}
+
+	privateKey, err := a.store.PrivateKey(kid)
 	if err != nil {
 		return "", fmt.Errorf("looking up private key: %w", err)
 	}
 
+	method, err := SigningMethod(privateKey.Public())
+	if err != nil {
+		return "", fmt.Errorf("signing method: %w", err)
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
 	tkn, err := token.SignedString(privateKey)
 	if err != nil {
 		return "", fmt.Errorf("signing token: %w", err)
@@ -82,16 +207,24 @@ func (a *Auth) GenerateToken(claims Claims) (string, error) {
 	return tkn, nil
 }
 
-func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, error) {
-	const regoPackageName = "token_validation"
+// Authenticate verifies the bearer token and returns its claims. Tokens that are not accepted wrap
+// ErrUnauthenticated or ErrRevoked, any other error is a failure of the service itself.
+func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (_ Claims, err error) {
+	ctx, span := tracing.AddSpan(ctx, "auth.authenticate")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
 
 	if !strings.HasPrefix(bearerToken, "Bearer ") {
-		return Claims{}, errors.New("expected Authorization header to be in this format: Bearer <TOKEN>")
+		return Claims{}, fmt.Errorf("%w: expected Authorization header to be in this format: Bearer <TOKEN>", ErrUnauthenticated)
 	}
 
 	tokenStr := strings.Split(bearerToken, " ")[1]
 
+	//issuers accepted for the token depend on where its key was found.
 	var claims Claims
+	var issuers []string
 	token, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
 		rawKid, exists := t.Header["kid"]
 		if !exists {
@@ -104,44 +237,55 @@ func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, er
 		}
 
 		//load the public key
-		public, err := a.store.PublicKey(kid)
+		public, accepted, err := a.publicKey(ctx, kid)
 		if err != nil {
 			return nil, fmt.Errorf("fetching public key: %w", err)
 		}
+		issuers = accepted
+
+		//the alg header must match the key, otherwise a token could pick a weaker verification.
+		method, err := SigningMethod(public)
+		if err != nil {
+			return nil, fmt.Errorf("signing method: %w", err)
+		}
+
+		if t.Method.Alg() != method.Alg() {
+			return nil, fmt.Errorf("token alg %q does not match key alg %q", t.Method.Alg(), method.Alg())
+		}
 
 		return public, nil
-	})
+	}, jwt.WithLeeway(a.clockSkew), jwt.WithIssuedAt())
 
 	if err != nil {
-		return Claims{}, fmt.Errorf("parsing token failed: %w", err)
+		return Claims{}, fmt.Errorf("%w: parsing token failed: %w", ErrUnauthenticated, err)
 	}
 
 	if !token.Valid {
-		return Claims{}, errors.New("invalid token")
+		return Claims{}, fmt.Errorf("%w: invalid token", ErrUnauthenticated)
 	}
 
 	//let the OPA to validate the claims
+	audience := []string(claims.Audience)
+	if audience == nil {
+		audience = []string{}
+	}
+
 	input := map[string]any{
 		"token": map[string]any{
-			"iss":   a.issuer,
-			"exp":   claims.ExpiresAt.Unix(),
+			"iss":   claims.Issuer,
+			"aud":   audience,
+			"exp":   unix(claims.ExpiresAt),
+			"nbf":   unix(claims.NotBefore),
+			"iat":   unix(claims.IssuedAt),
 			"roles": claims.Roles,
 		},
-		"now": time.Now().Unix(),
+		"issuers":   issuers,
+		"audiences": a.audiences,
+		"skew":      int64(a.clockSkew.Seconds()),
+		"now":       time.Now().Unix(),
 	}
 
-	const validateRule = "valid"
-	q := fmt.Sprintf("x = data.%s.%s", regoPackageName, validateRule)
-	query, err := rego.New(
-		rego.Query(q),
-		rego.Module("policy.rego", regoAuthentication), // in case of any error they will shown like they are from a file named "policy.rego"
-	).PrepareForEval(ctx)
-
-	if err != nil {
-		return Claims{}, fmt.Errorf("rego prepareForEval: %w", err)
-	}
-
-	results, err := query.Eval(ctx, rego.EvalInput(input))
+	results, err := a.policies.Load().authentication.Eval(ctx, rego.EvalInput(input))
 	if err != nil {
 		return Claims{}, fmt.Errorf("query eval: %w", err)
 	}
@@ -152,13 +296,34 @@ func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, er
 
 	result, ok := results[0].Bindings["x"].(bool)
 	if !result || !ok {
-		return Claims{}, errors.New("access denied by policy")
+		return Claims{}, fmt.Errorf("%w: access denied by policy", ErrUnauthenticated)
 	}
+
+	if a.revocations != nil && claims.ID != "" {
+		revoked, err := a.revocations.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return Claims{}, fmt.Errorf("checking revocation list: %w", err)
+		}
+
+		if revoked {
+			return Claims{}, ErrRevoked
+		}
+	}
+
 	return claims, nil
 }
 
-func (a *Auth) Authorize(ctx context.Context, claims Claims, userId string, rule string) error {
-	const regoPackageName = "role_validation"
+func (a *Auth) Authorize(ctx context.Context, claims Claims, userId string, rule string) (err error) {
+	ctx, span := tracing.AddSpan(ctx, "auth.authorize", attribute.String("rule", rule))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	query, ok := a.policies.Load().authorization[rule]
+	if !ok {
+		return fmt.Errorf("unknown rule: %q", rule)
+	}
 
 	input := map[string]any{
 		"roles":   claims.Roles,
@@ -166,16 +331,6 @@ func (a *Auth) Authorize(ctx context.Context, claims Claims, userId string, rule
 		"userId":  userId,
 	}
 
-	q := fmt.Sprintf("x = data.%s.%s", regoPackageName, rule)
-	query, err := rego.New(
-		rego.Query(q),
-		rego.Module("policy.rego", regoAuthorization),
-	).PrepareForEval(ctx)
-
-	if err != nil {
-		return fmt.Errorf("prepare for eval: %w", err)
-	}
-
 	results, err := query.Eval(ctx, rego.EvalInput(input))
 	if err != nil {
 		return fmt.Errorf("eval: %w", err)
@@ -192,3 +347,36 @@ func (a *Auth) Authorize(ctx context.Context, claims Claims, userId string, rule
 
 	return nil
 }
+
+// publicKey finds the key of the kid in the key store and then in the external sources, it
+// returns the issuers that may sign with the key.
+func (a *Auth) publicKey(ctx context.Context, kid string) (crypto.PublicKey, []string, error) {
+	public, err := lookupPublicKey(ctx, a.store, kid)
+	if err == nil {
+		return public, a.issuers, nil
+	}
+
+	for _, src := range a.externalKeys {
+		if external, extErr := lookupPublicKey(ctx, src.Keys, kid); extErr == nil {
+			return external, src.Issuers, nil
+		}
+	}
+
+	return nil, nil, err
+}
+
+// lookupPublicKey hands ctx to the lookups that accept one.
+func lookupPublicKey(ctx context.Context, keys PublicKeyLookup, kid string) (crypto.PublicKey, error) {
+	if cl, ok := keys.(ContextPublicKeyLookup); ok {
+		return cl.PublicKeyContext(ctx, kid)
+	}
+	return keys.PublicKey(kid)
+}
+
+// unix returns the seconds of the date, missing dates are 0.
+func unix(date *jwt.NumericDate) int64 {
+	if date == nil {
+		return 0
+	}
+	return date.Unix()
+}
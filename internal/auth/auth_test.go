@@ -2,8 +2,13 @@ package auth_test
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"testing"
 	"time"
 
@@ -17,7 +22,14 @@ const kid = "key-id"
 func TestAuth(t *testing.T) {
 	issuer := "auth-service"
 	s := newMockStore(t)
-	a := auth.New(s, jwt.SigningMethodRS256, issuer, kid)
+	a, err := auth.New(auth.Config{
+		KeyLookup: s,
+		Issuer:    issuer,
+		ActiveKID: kid,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
 
 	c := auth.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -46,7 +58,7 @@ func TestAuth(t *testing.T) {
 }
 
 type mockStore struct {
-	store map[string]*rsa.PrivateKey
+	store map[string]crypto.Signer
 }
 
 func newMockStore(t *testing.T) *mockStore {
@@ -56,27 +68,40 @@ func newMockStore(t *testing.T) *mockStore {
 	}
 
 	s := mockStore{
-		map[string]*rsa.PrivateKey{
+		map[string]crypto.Signer{
 			kid: private,
 		},
 	}
 	return &s
 }
 
-func (ms *mockStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
-	k := ms.store[kid]
+func (ms *mockStore) PrivateKey(kid string) (crypto.Signer, error) {
+	k, ok := ms.store[kid]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
 	return k, nil
 }
 
-func (ms *mockStore) PublicKey(kid string) (*rsa.PublicKey, error) {
-	k := ms.store[kid].PublicKey
-	return &k, nil
+func (ms *mockStore) PublicKey(kid string) (crypto.PublicKey, error) {
+	k, ok := ms.store[kid]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return k.Public(), nil
 }
 
 func TestAuthorization(t *testing.T) {
 	issuer := "auth-service"
 	s := newMockStore(t)
-	a := auth.New(s, jwt.SigningMethodRS256, issuer, kid)
+	a, err := auth.New(auth.Config{
+		KeyLookup: s,
+		Issuer:    issuer,
+		ActiveKID: kid,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
 
 	tests := map[string]struct {
 		claims     auth.Claims
@@ -121,6 +146,18 @@ func TestAuthorization(t *testing.T) {
 			shouldFail: true,
 		},
 
+		"unknown rule": {
+			claims: auth.Claims{
+				Roles: []string{"ADMIN"},
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer: issuer,
+				},
+			},
+			rule:       "rule_unknown",
+			userId:     uuid.NewString(),
+			shouldFail: true,
+		},
+
 		"admin accessing userbus only rule": {
 			claims: auth.Claims{
 				Roles: []string{"USER"},
@@ -151,3 +188,443 @@ func TestAuthorization(t *testing.T) {
 		})
 	}
 }
+
+type revocationList map[string]bool
+
+func (rl revocationList) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return rl[jti], nil
+}
+
+func TestRevocation(t *testing.T) {
+	issuer := "auth-service"
+	revoked := revocationList{}
+	a, err := auth.New(auth.Config{
+		KeyLookup:   newMockStore(t),
+		Issuer:      issuer,
+		ActiveKID:   kid,
+		Revocations: revoked,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	c := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user_id",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 2)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Roles: []string{"USER"},
+	}
+
+	token, err := a.GenerateToken(c)
+	if err != nil {
+		t.Fatalf("failed to generate token: %s", err)
+	}
+
+	parsedClaims, err := a.Authenticate(context.Background(), "Bearer "+token)
+	if err != nil {
+		t.Fatalf("failed to authenticate token: %s", err)
+	}
+
+	if parsedClaims.ID == "" {
+		t.Fatal("expected generated token to have a jti")
+	}
+
+	revoked[parsedClaims.ID] = true
+
+	if _, err := a.Authenticate(context.Background(), "Bearer "+token); !errors.Is(err, auth.ErrRevoked) {
+		t.Errorf("err=%v, got=%v", auth.ErrRevoked, err)
+	}
+}
+
+func TestAuthenticateClaims(t *testing.T) {
+	s := newMockStore(t)
+	newAuth := func(issuer string) *auth.Auth {
+		a, err := auth.New(auth.Config{
+			KeyLookup: s,
+			Issuer:    issuer,
+			ActiveKID: kid,
+			Issuers:   []string{"auth-service", "admin-cli"},
+			Audiences: []string{"sales-api"},
+			ClockSkew: time.Second * 30,
+		})
+		if err != nil {
+			t.Fatalf("failed to create auth: %s", err)
+		}
+		return a
+	}
+
+	service := newAuth("auth-service")
+	now := time.Now()
+
+	tests := map[string]struct {
+		issuer     string
+		audience   []string
+		notBefore  time.Time
+		issuedAt   time.Time
+		shouldFail bool
+	}{
+		"service_token": {
+			issuer:   "auth-service",
+			issuedAt: now,
+		},
+		"cli_token": {
+			issuer:   "admin-cli",
+			issuedAt: now,
+		},
+		"unknown_issuer": {
+			issuer:     "someone-else",
+			issuedAt:   now,
+			shouldFail: true,
+		},
+		"wrong_audience": {
+			issuer:     "auth-service",
+			audience:   []string{"other-api"},
+			issuedAt:   now,
+			shouldFail: true,
+		},
+		"issued_within_skew": {
+			issuer:   "auth-service",
+			issuedAt: now.Add(time.Second * 10),
+		},
+		"issued_in_the_future": {
+			issuer:     "auth-service",
+			issuedAt:   now.Add(time.Minute),
+			shouldFail: true,
+		},
+		"not_yet_valid": {
+			issuer:     "auth-service",
+			issuedAt:   now,
+			notBefore:  now.Add(time.Minute),
+			shouldFail: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := auth.Claims{
+				Roles: []string{"USER"},
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   uuid.NewString(),
+					Audience:  test.audience,
+					ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * 5)),
+					IssuedAt:  jwt.NewNumericDate(test.issuedAt),
+				},
+			}
+
+			if !test.notBefore.IsZero() {
+				c.NotBefore = jwt.NewNumericDate(test.notBefore)
+			}
+
+			token, err := newAuth(test.issuer).GenerateToken(c)
+			if err != nil {
+				t.Fatalf("failed to generate token: %s", err)
+			}
+
+			_, err = service.Authenticate(context.Background(), "Bearer "+token)
+			if test.shouldFail && err == nil {
+				t.Fatal("expected authentication to fail")
+			}
+
+			if !test.shouldFail && err != nil {
+				t.Fatalf("failed to authenticate: %s", err)
+			}
+		})
+	}
+}
+
+func TestExternalKeys(t *testing.T) {
+	external := newMockStore(t)
+
+	service, err := auth.New(auth.Config{
+		KeyLookup: &mockStore{store: map[string]crypto.Signer{}},
+		Issuer:    "auth-service",
+		ActiveKID: kid,
+		ExternalKeys: []auth.KeySource{
+			{Keys: external, Issuers: []string{"identity-provider"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	tests := map[string]struct {
+		issuer     string
+		shouldFail bool
+	}{
+		"external_issuer":         {issuer: "identity-provider"},
+		"external_claims_local":   {issuer: "auth-service", shouldFail: true},
+		"external_claims_unknown": {issuer: "someone-else", shouldFail: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			idp, err := auth.New(auth.Config{
+				KeyLookup: external,
+				Issuer:    test.issuer,
+				ActiveKID: kid,
+			})
+			if err != nil {
+				t.Fatalf("failed to create auth: %s", err)
+			}
+
+			token, err := idp.GenerateToken(auth.Claims{
+				Roles: []string{"USER"},
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   uuid.NewString(),
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+					IssuedAt:  jwt.NewNumericDate(time.Now()),
+				},
+			})
+			if err != nil {
+				t.Fatalf("failed to generate token: %s", err)
+			}
+
+			_, err = service.Authenticate(context.Background(), "Bearer "+token)
+			if test.shouldFail && err == nil {
+				t.Fatalf("expected token of %q signed by an external key to be rejected", test.issuer)
+			}
+
+			if !test.shouldFail && err != nil {
+				t.Fatalf("failed to authenticate token of external issuer: %s", err)
+			}
+		})
+	}
+
+	if _, err := auth.New(auth.Config{
+		KeyLookup:    external,
+		Issuer:       "auth-service",
+		ActiveKID:    kid,
+		ExternalKeys: []auth.KeySource{{Keys: external}},
+	}); err == nil {
+		t.Error("expected an external key source without issuers to be rejected")
+	}
+}
+
+// contextStore only hands out keys within a context that is still alive.
+type contextStore struct {
+	*mockStore
+}
+
+func (cs contextStore) PublicKeyContext(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cs.mockStore.PublicKey(kid)
+}
+
+func TestExternalKeysContext(t *testing.T) {
+	external := newMockStore(t)
+
+	service, err := auth.New(auth.Config{
+		KeyLookup: &mockStore{store: map[string]crypto.Signer{}},
+		Issuer:    "auth-service",
+		ActiveKID: kid,
+		ExternalKeys: []auth.KeySource{
+			{Keys: contextStore{external}, Issuers: []string{"identity-provider"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	idp, err := auth.New(auth.Config{
+		KeyLookup: external,
+		Issuer:    "identity-provider",
+		ActiveKID: kid,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	token, err := idp.GenerateToken(auth.Claims{
+		Roles: []string{"USER"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to generate token: %s", err)
+	}
+
+	if _, err := service.Authenticate(context.Background(), "Bearer "+token); err != nil {
+		t.Fatalf("failed to authenticate token of external issuer: %s", err)
+	}
+
+	//the lookup gets the context of the request, so a cancelled request does not wait for keys.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := service.Authenticate(ctx, "Bearer "+token); err == nil {
+		t.Fatal("expected the lookup to see the cancelled context")
+	}
+}
+
+func TestKeyTypes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %s", err)
+	}
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %s", err)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %s", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %s", err)
+	}
+
+	tests := map[string]struct {
+		key crypto.Signer
+		alg string
+	}{
+		"rs256": {key: rsaKey, alg: "RS256"},
+		"es256": {key: p256, alg: "ES256"},
+		"es384": {key: p384, alg: "ES384"},
+		"eddsa": {key: edKey, alg: "EdDSA"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, err := auth.New(auth.Config{
+				KeyLookup: &mockStore{store: map[string]crypto.Signer{kid: test.key}},
+				Issuer:    "auth-service",
+				ActiveKID: kid,
+			})
+			if err != nil {
+				t.Fatalf("failed to create auth: %s", err)
+			}
+
+			token, err := a.GenerateToken(auth.Claims{
+				Roles: []string{"USER"},
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   uuid.NewString(),
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+					IssuedAt:  jwt.NewNumericDate(time.Now()),
+				},
+			})
+			if err != nil {
+				t.Fatalf("failed to generate token: %s", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+			if err != nil {
+				t.Fatalf("failed to parse token: %s", err)
+			}
+
+			if parsed.Method.Alg() != test.alg {
+				t.Errorf("alg=%s, got %s", test.alg, parsed.Method.Alg())
+			}
+
+			if _, err := a.Authenticate(context.Background(), "Bearer "+token); err != nil {
+				t.Fatalf("failed to authenticate: %s", err)
+			}
+		})
+	}
+}
+
+func TestAlgMismatch(t *testing.T) {
+	s := newMockStore(t)
+	a, err := auth.New(auth.Config{
+		KeyLookup: s,
+		Issuer:    "auth-service",
+		ActiveKID: kid,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	//a token signed with a rsa key but claiming a different rsa alg must be rejected.
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, auth.Claims{
+		Roles: []string{"USER"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uuid.NewString(),
+			Issuer:    "auth-service",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	})
+	token.Header["kid"] = kid
+
+	private, _ := s.PrivateKey(kid)
+	signed, err := token.SignedString(private)
+	if err != nil {
+		t.Fatalf("failed to sign token: %s", err)
+	}
+
+	if _, err := a.Authenticate(context.Background(), "Bearer "+signed); err == nil {
+		t.Fatal("expected token with mismatched alg to be rejected")
+	}
+}
+
+type rotatingStore struct {
+	*mockStore
+	active string
+}
+
+func (rs *rotatingStore) ActiveKID() string {
+	return rs.active
+}
+
+func TestActiveKeyLookup(t *testing.T) {
+	s := &rotatingStore{mockStore: newMockStore(t), active: kid}
+	a, err := auth.New(auth.Config{
+		KeyLookup: s,
+		Issuer:    "auth-service",
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	claims := auth.Claims{
+		Roles: []string{"USER"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	old, err := a.GenerateToken(claims)
+	if err != nil {
+		t.Fatalf("failed to generate token: %s", err)
+	}
+
+	//rotate the signing key.
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate private key: %s", err)
+	}
+	s.store["rotated"] = private
+	s.active = "rotated"
+
+	rotated, err := a.GenerateToken(claims)
+	if err != nil {
+		t.Fatalf("failed to generate token: %s", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(rotated, &auth.Claims{})
+	if err != nil {
+		t.Fatalf("failed to parse token: %s", err)
+	}
+
+	if parsed.Header["kid"] != "rotated" {
+		t.Errorf("kid=%s, got %v", "rotated", parsed.Header["kid"])
+	}
+
+	for _, tkn := range []string{old, rotated} {
+		if _, err := a.Authenticate(context.Background(), "Bearer "+tkn); err != nil {
+			t.Fatalf("failed to authenticate: %s", err)
+		}
+	}
+}
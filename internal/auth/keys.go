@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningMethod returns the jwt signing method that matches the type of the public key.
+//
+//	*rsa.PublicKey         RS256
+//	*ecdsa.PublicKey P-256 ES256
+//	*ecdsa.PublicKey P-384 ES384
+//	ed25519.PublicKey      EdDSA
+func SigningMethod(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		default:
+			return nil, fmt.Errorf("unsupported ecdsa curve: %s", k.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", key)
+	}
+}
@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/loader"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// embedded policies are used when no policy path is configured or the configured one can not be loaded.
+//
+//go:embed rego/*.rego
+var regoFiles embed.FS
+
+const embeddedSource = "embedded"
+
+// policy metrics exposed through expvar.
+var (
+	policyLoads    = expvar.NewInt("policy_loads")
+	policyFailures = expvar.NewInt("policy_failures")
+	policySource   = expvar.NewString("policy_source")
+)
+
+// policies represents a compiled set of rego policies, it is swapped as a whole so requests
+// never evaluate a mix of old and new rules.
+type policies struct {
+	source         string
+	fingerprint    string
+	authentication rego.PreparedEvalQuery
+	authorization  map[string]rego.PreparedEvalQuery
+}
+
+// ReloadPolicies loads the policies from the configured path and swaps them in, the current
+// policies stay in place when the new ones are invalid.
+func (a *Auth) ReloadPolicies(ctx context.Context) error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	if a.policyPath == "" {
+		return nil
+	}
+
+	fp, err := fingerprint(a.policyPath)
+	if err != nil {
+		policyFailures.Add(1)
+		return fmt.Errorf("fingerprint: %w", err)
+	}
+
+	return a.reload(ctx, fp)
+}
+
+// WatchPolicies polls the configured policy path on every interval and reloads the policies
+// when their content changes. It blocks until ctx is cancelled, a non-positive interval turns
+// the watcher off.
+func (a *Auth) WatchPolicies(ctx context.Context, interval time.Duration) {
+	if a.policyPath == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.checkPolicies(ctx)
+		}
+	}
+}
+
+func (a *Auth) checkPolicies(ctx context.Context) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	fp, err := fingerprint(a.policyPath)
+	if err != nil {
+		policyFailures.Add(1)
+		a.log.Error("policy watch", "path", a.policyPath, "err", err)
+		return
+	}
+
+	//nothing changed since the last successful or failed attempt.
+	if fp == a.policies.Load().fingerprint || fp == a.failedFingerprint {
+		return
+	}
+
+	if err := a.reload(ctx, fp); err != nil {
+		a.failedFingerprint = fp
+		a.log.Error("policy reload", "path", a.policyPath, "err", err)
+	}
+}
+
+// reload must be called while holding reloadMu.
+func (a *Auth) reload(ctx context.Context, fp string) error {
+	modules, err := loadModules(a.policyPath)
+	if err != nil {
+		policyFailures.Add(1)
+		return fmt.Errorf("loading policies: %w", err)
+	}
+
+	p, err := compile(ctx, a.policyPath, modules)
+	if err != nil {
+		policyFailures.Add(1)
+		return fmt.Errorf("compiling policies: %w", err)
+	}
+	p.fingerprint = fp
+
+	a.policies.Store(p)
+	policyLoads.Add(1)
+	policySource.Set(p.source)
+	a.log.Info("policy reload", "source", p.source, "fingerprint", fp)
+
+	return nil
+}
+
+// initPolicies loads the initial policies, falling back to the embedded ones when the configured
+// path can not be used and policies are not required.
+func (a *Auth) initPolicies(ctx context.Context) error {
+	if a.policyPath != "" {
+		fp, err := fingerprint(a.policyPath)
+		if err == nil {
+			if err = a.reload(ctx, fp); err != nil {
+				//the watcher only retries once the files change.
+				a.failedFingerprint = fp
+			}
+		} else {
+			policyFailures.Add(1)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		if a.policyRequired {
+			return fmt.Errorf("loading policies from %s: %w", a.policyPath, err)
+		}
+
+		a.log.Error("policy load", "path", a.policyPath, "err", err, "fallback", embeddedSource)
+	}
+
+	modules, err := embeddedModules()
+	if err != nil {
+		return fmt.Errorf("loading embedded policies: %w", err)
+	}
+
+	p, err := compile(ctx, embeddedSource, modules)
+	if err != nil {
+		return fmt.Errorf("compiling embedded policies: %w", err)
+	}
+
+	a.policies.Store(p)
+	policyLoads.Add(1)
+	policySource.Set(p.source)
+	a.log.Info("policy load", "source", p.source)
+
+	return nil
+}
+
+//==============================================================================
+
+// compile validates the modules and prepares a query for authentication and every authorization rule.
+func compile(ctx context.Context, source string, modules map[string]string) (*policies, error) {
+	if err := validate(modules); err != nil {
+		return nil, err
+	}
+
+	const validateRule = "valid"
+	authentication, err := prepare(ctx, modules, authenticationPackage, validateRule)
+	if err != nil {
+		return nil, fmt.Errorf("preparing authentication policy: %w", err)
+	}
+
+	authorization := make(map[string]rego.PreparedEvalQuery, len(rules))
+	for _, rule := range rules {
+		query, err := prepare(ctx, modules, authorizationPackage, rule)
+		if err != nil {
+			return nil, fmt.Errorf("preparing authorization rule %q: %w", rule, err)
+		}
+		authorization[rule] = query
+	}
+
+	return &policies{
+		source:         source,
+		authentication: authentication,
+		authorization:  authorization,
+	}, nil
+}
+
+func prepare(ctx context.Context, modules map[string]string, pkg string, rule string) (rego.PreparedEvalQuery, error) {
+	opts := []func(*rego.Rego){
+		rego.Query(fmt.Sprintf("x = data.%s.%s", pkg, rule)),
+	}
+
+	for name, module := range modules {
+		opts = append(opts, rego.Module(name, module))
+	}
+
+	query, err := rego.New(opts...).PrepareForEval(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, fmt.Errorf("rego prepareForEval: %w", err)
+	}
+
+	return query, nil
+}
+
+// validate makes sure the modules parse and define every rule Auth evaluates, an undefined rule
+// would otherwise silently deny every request.
+func validate(modules map[string]string) error {
+	defined := make(map[string]bool)
+
+	for name, module := range modules {
+		parsed, err := ast.ParseModule(name, module)
+		if err != nil {
+			return fmt.Errorf("parsing module %s: %w", name, err)
+		}
+
+		pkg := parsed.Package.Path.String()
+		for _, r := range parsed.Rules {
+			defined[pkg+"."+r.Head.Name.String()] = true
+		}
+	}
+
+	required := []string{"data." + authenticationPackage + ".valid"}
+	for _, rule := range rules {
+		required = append(required, "data."+authorizationPackage+"."+rule)
+	}
+
+	for _, r := range required {
+		if !defined[r] {
+			return fmt.Errorf("missing rule %s", r)
+		}
+	}
+
+	return nil
+}
+
+func embeddedModules() (map[string]string, error) {
+	modules := make(map[string]string)
+
+	err := fs.WalkDir(regoFiles, "rego", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		bs, err := regoFiles.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		modules[path] = string(bs)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("walkDir: %w", err)
+	}
+
+	return modules, nil
+}
+
+// loadModules loads the rego modules from a directory or an OPA bundle tarball.
+func loadModules(path string) (map[string]string, error) {
+	b, err := loader.NewFileLoader().AsBundle(path)
+	if err != nil {
+		return nil, fmt.Errorf("asBundle: %w", err)
+	}
+
+	if len(b.Modules) == 0 {
+		return nil, errors.New("no rego modules found")
+	}
+
+	modules := make(map[string]string, len(b.Modules))
+	for _, m := range b.Modules {
+		modules[m.Path] = string(m.Raw)
+	}
+
+	return modules, nil
+}
+
+// fingerprint hashes the name, size and modification time of every file under the path.
+func fingerprint(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("stat: %w", err)
+	}
+
+	var entries []string
+	if !info.IsDir() {
+		entries = append(entries, fmt.Sprintf("%s:%d:%d", path, info.Size(), info.ModTime().UnixNano()))
+	} else {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				return nil
+			}
+
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, fmt.Sprintf("%s:%d:%d", p, fi.Size(), fi.ModTime().UnixNano()))
+			return nil
+		})
+
+		if err != nil {
+			return "", fmt.Errorf("walkDir: %w", err)
+		}
+	}
+
+	sort.Strings(entries)
+
+	h := sha256.New()
+	for _, e := range entries {
+		h.Write([]byte(e))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,218 @@
+package auth_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"expvar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/auth"
+)
+
+func TestPolicyReload(t *testing.T) {
+	authentication := readPolicy(t, "authentication.rego")
+	authorization := readPolicy(t, "authorization.rego")
+
+	//same policies, but admins lose access to admin only routes.
+	restricted := strings.Replace(authorization, "matched_roles := {role_admin} & claim_roles", "matched_roles := set()", 1)
+
+	dir := t.TempDir()
+	writePolicy(t, dir, "authentication.rego", authentication)
+	writePolicy(t, dir, "authorization.rego", restricted)
+
+	a, err := auth.New(auth.Config{
+		KeyLookup:  newMockStore(t),
+		Issuer:     "auth-service",
+		ActiveKID:  kid,
+		PolicyPath: dir,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	admin := auth.Claims{Roles: []string{"ADMIN"}}
+	ctx := context.Background()
+
+	if err := a.Authorize(ctx, admin, uuid.NewString(), auth.RuleAdmin); err == nil {
+		t.Fatal("expected policies from the directory to deny admins")
+	}
+
+	writePolicy(t, dir, "authorization.rego", authorization)
+	if err := a.ReloadPolicies(ctx); err != nil {
+		t.Fatalf("failed to reload policies: %s", err)
+	}
+
+	if err := a.Authorize(ctx, admin, uuid.NewString(), auth.RuleAdmin); err != nil {
+		t.Fatalf("expected reloaded policies to allow admins: %s", err)
+	}
+
+	//invalid policies must be rejected and the current ones kept.
+	writePolicy(t, dir, "authorization.rego", "package role_validation\n\nrule_any := true\n")
+	if err := a.ReloadPolicies(ctx); err == nil {
+		t.Fatal("expected reload of policies with missing rules to fail")
+	}
+
+	if err := a.Authorize(ctx, admin, uuid.NewString(), auth.RuleAdmin); err != nil {
+		t.Fatalf("expected previous policies to be kept: %s", err)
+	}
+}
+
+func TestPolicyBundle(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for _, name := range []string{"authentication.rego", "authorization.rego"} {
+		content := readPolicy(t, name)
+		hdr := tar.Header{Name: "/" + name, Mode: 0644, Size: int64(len(content))}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatalf("writing tar header: %s", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("writing tar content: %s", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar writer: %s", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("closing gzip writer: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("writing bundle: %s", err)
+	}
+
+	a, err := auth.New(auth.Config{
+		KeyLookup:  newMockStore(t),
+		Issuer:     "auth-service",
+		ActiveKID:  kid,
+		PolicyPath: path,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	user := auth.Claims{Roles: []string{"USER"}}
+	if err := a.Authorize(context.Background(), user, uuid.NewString(), auth.RuleUser); err != nil {
+		t.Fatalf("expected bundle policies to allow users: %s", err)
+	}
+
+	if err := a.Authorize(context.Background(), user, uuid.NewString(), auth.RuleAdmin); err == nil {
+		t.Fatal("expected bundle policies to deny users on admin rule")
+	}
+}
+
+func TestPolicyFallback(t *testing.T) {
+	a, err := auth.New(auth.Config{
+		KeyLookup:  newMockStore(t),
+		Issuer:     "auth-service",
+		ActiveKID:  kid,
+		PolicyPath: filepath.Join(t.TempDir(), "missing"),
+	})
+	if err != nil {
+		t.Fatalf("expected auth to fall back to embedded policies: %s", err)
+	}
+
+	admin := auth.Claims{Roles: []string{"ADMIN"}}
+	if err := a.Authorize(context.Background(), admin, uuid.NewString(), auth.RuleAdmin); err != nil {
+		t.Fatalf("expected embedded policies to allow admins: %s", err)
+	}
+}
+
+func TestPolicyRequired(t *testing.T) {
+	_, err := auth.New(auth.Config{
+		KeyLookup:      newMockStore(t),
+		Issuer:         "auth-service",
+		ActiveKID:      kid,
+		PolicyPath:     filepath.Join(t.TempDir(), "missing"),
+		PolicyRequired: true,
+	})
+	if err == nil {
+		t.Fatal("expected auth to refuse the embedded policies when policies are required")
+	}
+}
+
+func TestWatchSkipsFailedStartupPolicies(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "authentication.rego", readPolicy(t, "authentication.rego"))
+	writePolicy(t, dir, "authorization.rego", "package role_validation\n\nrule_any := true\n")
+
+	a, err := auth.New(auth.Config{
+		KeyLookup:  newMockStore(t),
+		Issuer:     "auth-service",
+		ActiveKID:  kid,
+		PolicyPath: dir,
+	})
+	if err != nil {
+		t.Fatalf("expected auth to fall back to embedded policies: %s", err)
+	}
+
+	failures := expvar.Get("policy_failures").(*expvar.Int)
+	before := failures.Value()
+
+	//the files did not change since startup, so the watcher must not retry them.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	a.WatchPolicies(ctx, time.Millisecond*5)
+
+	if after := failures.Value(); after != before {
+		t.Errorf("expected no retries of the failed policies, got %d failures", after-before)
+	}
+}
+
+func TestWatchPoliciesDisabled(t *testing.T) {
+	dir := t.TempDir()
+	writePolicy(t, dir, "authentication.rego", readPolicy(t, "authentication.rego"))
+	writePolicy(t, dir, "authorization.rego", readPolicy(t, "authorization.rego"))
+
+	a, err := auth.New(auth.Config{
+		KeyLookup:  newMockStore(t),
+		Issuer:     "auth-service",
+		ActiveKID:  kid,
+		PolicyPath: dir,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	//a non-positive interval must return right away instead of panicking in the ticker.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.WatchPolicies(context.Background(), 0)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the watcher to return when the interval is zero")
+	}
+}
+
+func readPolicy(t *testing.T, name string) string {
+	t.Helper()
+
+	bs, err := os.ReadFile(filepath.Join("rego", name))
+	if err != nil {
+		t.Fatalf("reading policy %s: %s", name, err)
+	}
+	return string(bs)
+}
+
+func writePolicy(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("writing policy %s: %s", name, err)
+	}
+}
@@ -5,15 +5,29 @@ default valid := false
 valid if {
 	valid_time
 	valid_issuer
+	valid_audience
 	valid_roles_format
 }
 
+# nbf and iat are optional and sent as 0 when missing.
 valid_time if {
-	input.token.exp > input.now
+	input.token.exp + input.skew > input.now
+	input.token.nbf - input.skew <= input.now
+	input.token.iat - input.skew <= input.now
 }
 
 valid_issuer if {
-	input.token.iss == "auth-service"
+	input.token.iss in input.issuers
+}
+
+# no configured audiences means the audience is not checked.
+valid_audience if {
+	count(input.audiences) == 0
+}
+
+valid_audience if {
+	some aud in input.token.aud
+	aud in input.audiences
 }
 
 valid_roles_format if {
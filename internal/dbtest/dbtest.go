@@ -4,17 +4,22 @@ package dbtest
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"math/rand/v2"
 
 	"testing"
 
+	"github.com/hamidoujand/sales/internal/seed"
 	"github.com/hamidoujand/sales/internal/sqldb"
 	"github.com/hamidoujand/sales/pkg/docker"
 	"github.com/jmoiron/sqlx"
 )
 
 type Database struct {
-	DB *sqlx.DB
+	DB     *sqlx.DB
+	Config sqldb.Config //connects to the same database, for code that opens its own connection.
+	Log    *slog.Logger //stores log their queries at debug level, they are dropped in tests.
 }
 
 func NewDatabase(ctx context.Context, t *testing.T, containerName string) *Database {
@@ -66,13 +71,15 @@ func NewDatabase(ctx context.Context, t *testing.T, containerName string) *Datab
 	}
 
 	//connect to it
-	db, err := sqldb.Open(sqldb.Config{
+	cfg := sqldb.Config{
 		Host:       c.HostPort,
 		User:       "postgres",
 		Password:   "password",
 		Name:       dbname,
 		DisableTLS: true,
-	})
+	}
+
+	db, err := sqldb.Open(cfg)
 
 	if err != nil {
 		t.Fatalf("connecting to db %s: %s", dbname, err)
@@ -123,6 +130,20 @@ func NewDatabase(ctx context.Context, t *testing.T, containerName string) *Datab
 	})
 
 	return &Database{
-		DB: db,
+		DB:     db,
+		Config: cfg,
+		Log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
 	}
 }
+
+// Seed fills the test database with the same fixture data the admin seed command uses.
+func (d *Database) Seed(ctx context.Context, t *testing.T, cfg seed.Config) seed.Result {
+	t.Helper()
+
+	res, err := seed.Run(ctx, d.Log, d.DB, cfg)
+	if err != nil {
+		t.Fatalf("seeding database: %s", err)
+	}
+
+	return res
+}
@@ -0,0 +1,122 @@
+package dbtest
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/auth"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+)
+
+// KID is the key id of the signing key of a KeyStore.
+const KID = "key-id"
+
+// Password is the password of the users made by CreateUser.
+const Password = "password"
+
+// KeyStore is an in memory auth.KeyLookup holding a single RSA key under KID.
+type KeyStore struct {
+	store map[string]crypto.Signer
+}
+
+// NewKeyStore generates the signing key of a KeyStore.
+func NewKeyStore(t *testing.T) *KeyStore {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate private key: %s", err)
+	}
+
+	return &KeyStore{
+		store: map[string]crypto.Signer{
+			KID: private,
+		},
+	}
+}
+
+func (ks *KeyStore) PrivateKey(kid string) (crypto.Signer, error) {
+	key, ok := ks.store[kid]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return key, nil
+}
+
+func (ks *KeyStore) PublicKey(kid string) (crypto.PublicKey, error) {
+	key, ok := ks.store[kid]
+	if !ok {
+		return nil, errors.New("key not found")
+	}
+	return key.Public(), nil
+}
+
+// CreateUser creates an enabled user with the given roles and Password.
+func CreateUser(ctx context.Context, t *testing.T, bus *userbus.UserBus, email string, roles ...userbus.Role) userbus.User {
+	t.Helper()
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		t.Fatalf("parsing email: %s", err)
+	}
+
+	usr, err := bus.Create(ctx, userbus.NewUser{
+		Name:     "test",
+		Email:    *addr,
+		Roles:    roles,
+		Password: Password,
+	})
+	if err != nil {
+		t.Fatalf("creating user: %s", err)
+	}
+
+	return usr
+}
+
+// CreateProduct creates a product of the user with the given sku.
+func CreateProduct(ctx context.Context, t *testing.T, bus *productbus.ProductBus, userID uuid.UUID, sku string) productbus.Product {
+	t.Helper()
+
+	prd, err := bus.Create(ctx, productbus.NewProduct{
+		UserID:   userID,
+		Name:     "Gopher " + sku,
+		SKU:      sku,
+		Cost:     1999,
+		Quantity: 10,
+	})
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	return prd
+}
+
+// GenerateToken generates an access token of the user that is valid for a minute.
+func GenerateToken(t *testing.T, a *auth.Auth, usr userbus.User) string {
+	t.Helper()
+
+	c := auth.Claims{
+		Roles: userbus.EncodeRoles(usr.Roles),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   usr.ID.String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := a.GenerateToken(c)
+	if err != nil {
+		t.Fatalf("generating token: %s", err)
+	}
+
+	return token
+}
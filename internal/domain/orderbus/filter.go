@@ -0,0 +1,16 @@
+package orderbus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueryFilter represents all the fields that can be used for filtering.
+type QueryFilter struct {
+	ID             *uuid.UUID
+	UserID         *uuid.UUID
+	Status         *Status
+	StartCreatedAt *time.Time
+	EndCreatedAt   *time.Time
+}
@@ -0,0 +1,36 @@
+package orderbus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Order represents a sale to a user, Total is the sum of the items in minor units.
+type Order struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Status      Status
+	Items       []Item
+	Total       int64
+	DateCreated time.Time
+	DateUpdated time.Time
+}
+
+// Item represents a line of an order, Cost is the unit cost of the product when the order was
+// created. ProductID is uuid.Nil once the product is deleted from the catalog.
+type Item struct {
+	ProductID uuid.UUID
+	Quantity  int
+	Cost      int64
+}
+
+type NewOrder struct {
+	UserID uuid.UUID
+	Items  []NewItem
+}
+
+type NewItem struct {
+	ProductID uuid.UUID
+	Quantity  int
+}
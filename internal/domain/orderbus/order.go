@@ -0,0 +1,15 @@
+package orderbus
+
+import "github.com/hamidoujand/sales/internal/order"
+
+// DefaultOrderBy represents the default way we sort orders, newest first.
+var DefaultOrderBy = order.NewBy(OrderByDateCreated, order.DESC)
+
+// set of fields that orders can be ordered by.
+const (
+	OrderByID          = "order_id"
+	OrderByUserID      = "user_id"
+	OrderByStatus      = "status"
+	OrderByTotal       = "total"
+	OrderByDateCreated = "date_created"
+)
@@ -0,0 +1,167 @@
+// Package orderbus provides the business logic for selling products through orders.
+package orderbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrEmptyOrder        = errors.New("order has no items")
+	ErrUnknownProduct    = errors.New("ordered product does not exist")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrInvalidQuantity   = errors.New("quantity must be positive")
+)
+
+func init() {
+	errs.Register(ErrOrderNotFound, errs.Spec{Code: "ORDER_NOT_FOUND", Status: http.StatusNotFound, Message: "order not found"})
+	errs.Register(ErrEmptyOrder, errs.Spec{Code: "EMPTY_ORDER", Status: http.StatusBadRequest, Message: "order has no items"})
+	errs.Register(ErrUnknownProduct, errs.Spec{Code: "UNKNOWN_PRODUCT", Status: http.StatusBadRequest, Message: "ordered product does not exist"})
+	errs.Register(ErrInsufficientStock, errs.Spec{Code: "INSUFFICIENT_STOCK", Status: http.StatusConflict, Message: "insufficient stock"})
+	errs.Register(ErrInvalidTransition, errs.Spec{Code: "INVALID_STATUS_TRANSITION", Status: http.StatusConflict, Message: "order can not move to the requested status"})
+	errs.Register(ErrInvalidQuantity, errs.Spec{Code: "INVALID_QUANTITY", Status: http.StatusBadRequest, Message: "quantity must be positive"})
+}
+
+// Storer represents the required behavior from the storage engine.
+type Storer interface {
+	NewWithTx(tx sqldb.CommitRollbacker) (Storer, error)
+	// Create stores the order and takes its items out of stock atomically, the unit cost of
+	// every item is set from its product. ErrUnknownProduct and ErrInsufficientStock are
+	// returned without storing anything.
+	Create(ctx context.Context, ord Order) (Order, error)
+	// Update stores the status of an order that is still in the from status, ErrInvalidTransition
+	// is returned when another request changed it first.
+	Update(ctx context.Context, ord Order, from Status) error
+	// Cancel is Update for cancelled orders, the items are put back in stock atomically.
+	Cancel(ctx context.Context, ord Order, from Status) error
+	QueryByID(ctx context.Context, orderID uuid.UUID) (Order, error)
+	Query(ctx context.Context, filter QueryFilter, orderBy order.By, page page.Page) ([]Order, error)
+	Count(ctx context.Context, filter QueryFilter) (int, error)
+}
+
+type OrderBus struct {
+	store Storer
+}
+
+func New(store Storer) *OrderBus {
+	return &OrderBus{
+		store: store,
+	}
+}
+
+// NewWithTx returns a copy of the bus that runs within the transaction.
+func (o *OrderBus) NewWithTx(tx sqldb.CommitRollbacker) (*OrderBus, error) {
+	store, err := o.store.NewWithTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrderBus{
+		store: store,
+	}, nil
+}
+
+// Create places a pending order, items of the same product are merged into one line.
+func (o *OrderBus) Create(ctx context.Context, no NewOrder) (Order, error) {
+	if len(no.Items) == 0 {
+		return Order{}, ErrEmptyOrder
+	}
+
+	items := make([]Item, 0, len(no.Items))
+	lines := make(map[uuid.UUID]int)
+	for _, ni := range no.Items {
+		if ni.Quantity <= 0 {
+			return Order{}, fmt.Errorf("product[%s]: %w", ni.ProductID, ErrInvalidQuantity)
+		}
+
+		if i, ok := lines[ni.ProductID]; ok {
+			items[i].Quantity += ni.Quantity
+			continue
+		}
+
+		lines[ni.ProductID] = len(items)
+		items = append(items, Item{ProductID: ni.ProductID, Quantity: ni.Quantity})
+	}
+
+	now := time.Now()
+	ord := Order{
+		ID:          uuid.New(),
+		UserID:      no.UserID,
+		Status:      StatusPending,
+		Items:       items,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	ord, err := o.store.Create(ctx, ord)
+	if err != nil {
+		return Order{}, fmt.Errorf("creating order: %w", err)
+	}
+
+	return ord, nil
+}
+
+// UpdateStatus moves the order into the next status, cancelled orders return their items to
+// stock.
+func (o *OrderBus) UpdateStatus(ctx context.Context, ord Order, next Status) (Order, error) {
+	if !ord.Status.CanTransitionTo(next) {
+		return Order{}, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, ord.Status, next)
+	}
+
+	from := ord.Status
+	ord.Status = next
+	ord.DateUpdated = time.Now()
+
+	if next.Equal(StatusCancelled) {
+		if err := o.store.Cancel(ctx, ord, from); err != nil {
+			return Order{}, fmt.Errorf("cancelling order: %w", err)
+		}
+		return ord, nil
+	}
+
+	if err := o.store.Update(ctx, ord, from); err != nil {
+		return Order{}, fmt.Errorf("updating order: %w", err)
+	}
+
+	return ord, nil
+}
+
+func (o *OrderBus) QueryByID(ctx context.Context, orderID uuid.UUID) (Order, error) {
+	ord, err := o.store.QueryByID(ctx, orderID)
+	if err != nil {
+		//check for not-found
+		if errors.Is(err, sqldb.ErrNotFound) {
+			return Order{}, ErrOrderNotFound
+		}
+		return Order{}, fmt.Errorf("query by ID: %w", err)
+	}
+
+	return ord, nil
+}
+
+func (o *OrderBus) Query(ctx context.Context, filter QueryFilter, order order.By, page page.Page) ([]Order, error) {
+	orders, err := o.store.Query(ctx, filter, order, page)
+	if err != nil {
+		return nil, fmt.Errorf("querying orders: %w", err)
+	}
+	return orders, nil
+}
+
+func (o *OrderBus) Count(ctx context.Context, filter QueryFilter) (int, error) {
+	count, err := o.store.Count(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("counting orders: %w", err)
+	}
+	return count, nil
+}
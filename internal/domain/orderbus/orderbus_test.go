@@ -0,0 +1,529 @@
+package orderbus_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/domain/orderbus/orderdb"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/domain/productbus/productdb"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/seed"
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+func TestStatusTransitions(t *testing.T) {
+	tests := map[string]struct {
+		from    orderbus.Status
+		to      orderbus.Status
+		allowed bool
+	}{
+		"pending_to_paid":        {from: orderbus.StatusPending, to: orderbus.StatusPaid, allowed: true},
+		"pending_to_cancelled":   {from: orderbus.StatusPending, to: orderbus.StatusCancelled, allowed: true},
+		"pending_to_shipped":     {from: orderbus.StatusPending, to: orderbus.StatusShipped, allowed: false},
+		"paid_to_shipped":        {from: orderbus.StatusPaid, to: orderbus.StatusShipped, allowed: true},
+		"paid_to_cancelled":      {from: orderbus.StatusPaid, to: orderbus.StatusCancelled, allowed: true},
+		"paid_to_pending":        {from: orderbus.StatusPaid, to: orderbus.StatusPending, allowed: false},
+		"shipped_to_cancelled":   {from: orderbus.StatusShipped, to: orderbus.StatusCancelled, allowed: false},
+		"cancelled_to_paid":      {from: orderbus.StatusCancelled, to: orderbus.StatusPaid, allowed: false},
+		"pending_to_pending":     {from: orderbus.StatusPending, to: orderbus.StatusPending, allowed: false},
+		"cancelled_to_cancelled": {from: orderbus.StatusCancelled, to: orderbus.StatusCancelled, allowed: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.from.CanTransitionTo(test.to); got != test.allowed {
+				t.Errorf("allowed=%t, got %t", test.allowed, got)
+			}
+		})
+	}
+
+	if _, err := orderbus.ParseStatus("REFUNDED"); err == nil {
+		t.Error("expected unknown status to fail")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "create_order")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	bus := orderbus.New(orderdb.NewStore(database.Log, database.DB))
+
+	plush := createProduct(ctx, t, productBus, usr.ID, "GPH-001", 1999, 5)
+	mug := createProduct(ctx, t, productBus, usr.ID, "GPH-002", 1250, 1)
+
+	ord, err := bus.Create(ctx, orderbus.NewOrder{
+		UserID: usr.ID,
+		Items: []orderbus.NewItem{
+			{ProductID: plush.ID, Quantity: 2},
+			{ProductID: mug.ID, Quantity: 1},
+			{ProductID: plush.ID, Quantity: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("creating order failed: %s", err)
+	}
+
+	if !ord.Status.Equal(orderbus.StatusPending) {
+		t.Errorf("status=%s, got=%s", orderbus.StatusPending, ord.Status)
+	}
+
+	//lines of the same product are merged.
+	if len(ord.Items) != 2 || ord.Items[0].Quantity != 3 {
+		t.Fatalf("expected 2 items with 3 plushes, got %+v", ord.Items)
+	}
+
+	if total := int64(3*1999 + 1250); ord.Total != total {
+		t.Errorf("total=%d, got=%d", total, ord.Total)
+	}
+
+	fetched, err := bus.QueryByID(ctx, ord.ID)
+	if err != nil {
+		t.Fatalf("querying order by id failed: %s", err)
+	}
+
+	if fetched.Total != ord.Total || len(fetched.Items) != 2 || fetched.Items[1].Cost != mug.Cost {
+		t.Errorf("expected the stored order to match, got %+v", fetched)
+	}
+
+	assertStock(ctx, t, productBus, plush.ID, 2)
+	assertStock(ctx, t, productBus, mug.ID, 0)
+
+	//an order that can not be filled leaves every product untouched.
+	_, err = bus.Create(ctx, orderbus.NewOrder{
+		UserID: usr.ID,
+		Items: []orderbus.NewItem{
+			{ProductID: plush.ID, Quantity: 1},
+			{ProductID: mug.ID, Quantity: 1},
+		},
+	})
+	if !errors.Is(err, orderbus.ErrInsufficientStock) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrInsufficientStock, err)
+	}
+	assertStock(ctx, t, productBus, plush.ID, 2)
+
+	//lines of the same product are checked against the stock together.
+	_, err = bus.Create(ctx, orderbus.NewOrder{
+		UserID: usr.ID,
+		Items: []orderbus.NewItem{
+			{ProductID: plush.ID, Quantity: 2},
+			{ProductID: plush.ID, Quantity: 1},
+		},
+	})
+	if !errors.Is(err, orderbus.ErrInsufficientStock) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrInsufficientStock, err)
+	}
+	assertStock(ctx, t, productBus, plush.ID, 2)
+
+	_, err = bus.Create(ctx, orderbus.NewOrder{
+		UserID: usr.ID,
+		Items:  []orderbus.NewItem{{ProductID: uuid.New(), Quantity: 1}},
+	})
+	if !errors.Is(err, orderbus.ErrUnknownProduct) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrUnknownProduct, err)
+	}
+
+	if _, err := bus.Create(ctx, orderbus.NewOrder{UserID: usr.ID}); !errors.Is(err, orderbus.ErrEmptyOrder) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrEmptyOrder, err)
+	}
+
+	_, err = bus.Create(ctx, orderbus.NewOrder{
+		UserID: usr.ID,
+		Items:  []orderbus.NewItem{{ProductID: plush.ID, Quantity: 0}},
+	})
+	if !errors.Is(err, orderbus.ErrInvalidQuantity) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrInvalidQuantity, err)
+	}
+
+	//customers with orders keep them, they can not be deleted.
+	userBus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	if err := userBus.Delete(ctx, usr); !errors.Is(err, userbus.ErrUserHasOrders) {
+		t.Errorf("err=%v, got=%v", userbus.ErrUserHasOrders, err)
+	}
+}
+
+func TestConcurrentCreate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "concurrent_orders")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	bus := orderbus.New(orderdb.NewStore(database.Log, database.DB))
+
+	const stock = 5
+	plush := createProduct(ctx, t, productBus, usr.ID, "GPH-001", 1999, stock)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var placed, rejected int
+
+	for range stock * 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := bus.Create(ctx, orderbus.NewOrder{
+				UserID: usr.ID,
+				Items:  []orderbus.NewItem{{ProductID: plush.ID, Quantity: 1}},
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				placed++
+			case errors.Is(err, orderbus.ErrInsufficientStock):
+				rejected++
+			default:
+				t.Errorf("creating order failed: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	//the stock is never oversold.
+	if placed != stock || rejected != stock {
+		t.Errorf("expected %d orders to be placed and %d rejected, got %d and %d", stock, stock, placed, rejected)
+	}
+
+	assertStock(ctx, t, productBus, plush.ID, 0)
+}
+
+func TestUpdateStatus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "update_order_status")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	bus := orderbus.New(orderdb.NewStore(database.Log, database.DB))
+
+	plush := createProduct(ctx, t, productBus, usr.ID, "GPH-001", 1999, 5)
+	ord := createOrder(ctx, t, bus, usr.ID, plush.ID, 2)
+
+	paid, err := bus.UpdateStatus(ctx, ord, orderbus.StatusPaid)
+	if err != nil {
+		t.Fatalf("paying order failed: %s", err)
+	}
+
+	if _, err := bus.UpdateStatus(ctx, paid, orderbus.StatusPending); !errors.Is(err, orderbus.ErrInvalidTransition) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrInvalidTransition, err)
+	}
+
+	//cancelling puts the items back in stock.
+	assertStock(ctx, t, productBus, plush.ID, 3)
+	if _, err := bus.UpdateStatus(ctx, paid, orderbus.StatusCancelled); err != nil {
+		t.Fatalf("cancelling order failed: %s", err)
+	}
+	assertStock(ctx, t, productBus, plush.ID, 5)
+
+	//a stale copy of the order must not restock the items twice.
+	if _, err := bus.UpdateStatus(ctx, paid, orderbus.StatusCancelled); !errors.Is(err, orderbus.ErrInvalidTransition) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrInvalidTransition, err)
+	}
+	assertStock(ctx, t, productBus, plush.ID, 5)
+
+	fetched, err := bus.QueryByID(ctx, ord.ID)
+	if err != nil {
+		t.Fatalf("querying order by id failed: %s", err)
+	}
+
+	if !fetched.Status.Equal(orderbus.StatusCancelled) {
+		t.Errorf("status=%s, got=%s", orderbus.StatusCancelled, fetched.Status)
+	}
+
+	if _, err := bus.UpdateStatus(ctx, fetched, orderbus.StatusPaid); !errors.Is(err, orderbus.ErrInvalidTransition) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrInvalidTransition, err)
+	}
+
+	if _, err := bus.QueryByID(ctx, uuid.New()); !errors.Is(err, orderbus.ErrOrderNotFound) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrOrderNotFound, err)
+	}
+}
+
+func TestConcurrentCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "concurrent_cancel")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	bus := orderbus.New(orderdb.NewStore(database.Log, database.DB))
+
+	plush := createProduct(ctx, t, productBus, usr.ID, "GPH-001", 1999, 5)
+	ord := createOrder(ctx, t, bus, usr.ID, plush.ID, 2)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var cancelled, rejected int
+
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := bus.UpdateStatus(ctx, ord, orderbus.StatusCancelled)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				cancelled++
+			case errors.Is(err, orderbus.ErrInvalidTransition):
+				rejected++
+			default:
+				t.Errorf("cancelling order failed: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	//only one of the cancellations wins, so the items are restocked once.
+	if cancelled != 1 || rejected != 1 {
+		t.Errorf("expected 1 cancellation to win and 1 to be rejected, got %d and %d", cancelled, rejected)
+	}
+
+	assertStock(ctx, t, productBus, plush.ID, 5)
+}
+
+func TestConcurrentProductUpdate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "concurrent_product_update")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	bus := orderbus.New(orderdb.NewStore(database.Log, database.DB))
+
+	const stock = 10
+	plush := createProduct(ctx, t, productBus, usr.ID, "GPH-001", 1999, stock)
+
+	var wg sync.WaitGroup
+	for i := range stock {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+
+			_, err := bus.Create(ctx, orderbus.NewOrder{
+				UserID: usr.ID,
+				Items:  []orderbus.NewItem{{ProductID: plush.ID, Quantity: 1}},
+			})
+			if err != nil {
+				t.Errorf("creating order failed: %s", err)
+			}
+		}()
+
+		//every edit starts from the product as it was read before the orders.
+		go func() {
+			defer wg.Done()
+
+			name := fmt.Sprintf("Gopher Plush %d", i)
+			if _, err := productBus.Update(ctx, plush, productbus.UpdateProduct{Name: &name}); err != nil {
+				t.Errorf("updating product failed: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	//editing the product never puts back the stock taken by orders.
+	assertStock(ctx, t, productBus, plush.ID, 0)
+
+	updated, err := productBus.Update(ctx, plush, productbus.UpdateProduct{})
+	if err != nil {
+		t.Fatalf("updating product failed: %s", err)
+	}
+
+	if updated.Quantity != 0 {
+		t.Errorf("expected the stored quantity to be returned, quantity=%d, got=%d", 0, updated.Quantity)
+	}
+}
+
+func TestExecUnderTx(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "orders_under_tx")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	bus := orderbus.New(orderdb.NewStore(database.Log, database.DB))
+	beginner := sqldb.NewBeginner(database.DB)
+
+	//the product and its order are created together, a failure afterwards undoes both.
+	var prd productbus.Product
+	var ord orderbus.Order
+	errAbort := errors.New("abort")
+	err := sqldb.ExecUnderTx(ctx, beginner, func(tx sqldb.CommitRollbacker) error {
+		txProductBus, err := productBus.NewWithTx(tx)
+		if err != nil {
+			return err
+		}
+
+		txBus, err := bus.NewWithTx(tx)
+		if err != nil {
+			return err
+		}
+
+		prd = createProduct(ctx, t, txProductBus, usr.ID, "GPH-001", 1999, 5)
+		ord = createOrder(ctx, t, txBus, usr.ID, prd.ID, 2)
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("err=%v, got=%v", errAbort, err)
+	}
+
+	if _, err := productBus.QueryByID(ctx, prd.ID); !errors.Is(err, productbus.ErrProductNotFound) {
+		t.Errorf("err=%v, got=%v", productbus.ErrProductNotFound, err)
+	}
+
+	if _, err := bus.QueryByID(ctx, ord.ID); !errors.Is(err, orderbus.ErrOrderNotFound) {
+		t.Errorf("err=%v, got=%v", orderbus.ErrOrderNotFound, err)
+	}
+
+	//a successful run commits both.
+	err = sqldb.ExecUnderTx(ctx, beginner, func(tx sqldb.CommitRollbacker) error {
+		txProductBus, err := productBus.NewWithTx(tx)
+		if err != nil {
+			return err
+		}
+
+		txBus, err := bus.NewWithTx(tx)
+		if err != nil {
+			return err
+		}
+
+		prd = createProduct(ctx, t, txProductBus, usr.ID, "GPH-001", 1999, 5)
+		ord = createOrder(ctx, t, txBus, usr.ID, prd.ID, 2)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("execUnderTx failed: %s", err)
+	}
+
+	assertStock(ctx, t, productBus, prd.ID, 3)
+	if _, err := bus.QueryByID(ctx, ord.ID); err != nil {
+		t.Errorf("querying committed order failed: %s", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "query_orders")
+
+	users := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 2}).Users
+	john, jane := users[0], users[1]
+	productBus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	bus := orderbus.New(orderdb.NewStore(database.Log, database.DB))
+
+	plush := createProduct(ctx, t, productBus, john.ID, "GPH-001", 1999, 10)
+	createOrder(ctx, t, bus, john.ID, plush.ID, 1)
+	createOrder(ctx, t, bus, john.ID, plush.ID, 2)
+	paid, err := bus.UpdateStatus(ctx, createOrder(ctx, t, bus, jane.ID, plush.ID, 3), orderbus.StatusPaid)
+	if err != nil {
+		t.Fatalf("paying order failed: %s", err)
+	}
+
+	pg, err := page.Parse("1", "10")
+	if err != nil {
+		t.Fatalf("parsing page: %s", err)
+	}
+
+	byTotal := order.NewBy(orderbus.OrderByTotal, order.DESC)
+
+	orders, err := bus.Query(ctx, orderbus.QueryFilter{UserID: &john.ID}, byTotal, pg)
+	if err != nil {
+		t.Fatalf("querying orders failed: %s", err)
+	}
+
+	if len(orders) != 2 || orders[0].Total != 2*1999 {
+		t.Fatalf("expected john's orders with the biggest first, got %d orders", len(orders))
+	}
+
+	if len(orders[0].Items) != 1 || orders[0].Items[0].ProductID != plush.ID {
+		t.Errorf("expected the items to be loaded, got %+v", orders[0].Items)
+	}
+
+	status := orderbus.StatusPaid
+	total, err := bus.Count(ctx, orderbus.QueryFilter{Status: &status})
+	if err != nil {
+		t.Fatalf("counting orders failed: %s", err)
+	}
+
+	if total != 1 {
+		t.Errorf("total=%d, got=%d", 1, total)
+	}
+
+	//deleted products keep their lines, without the product.
+	if err := productBus.Delete(ctx, plush); err != nil {
+		t.Fatalf("deleting product failed: %s", err)
+	}
+
+	fetched, err := bus.QueryByID(ctx, paid.ID)
+	if err != nil {
+		t.Fatalf("querying order by id failed: %s", err)
+	}
+
+	if len(fetched.Items) != 1 || fetched.Items[0].ProductID != uuid.Nil || fetched.Total != paid.Total {
+		t.Errorf("expected the line of the deleted product to stay, got %+v", fetched.Items)
+	}
+}
+
+func createProduct(ctx context.Context, t *testing.T, bus *productbus.ProductBus, userID uuid.UUID, sku string, cost int64, quantity int) productbus.Product {
+	t.Helper()
+
+	prd, err := bus.Create(ctx, productbus.NewProduct{
+		UserID:   userID,
+		Name:     fmt.Sprintf("Gopher %s", sku),
+		SKU:      sku,
+		Cost:     cost,
+		Quantity: quantity,
+	})
+	if err != nil {
+		t.Fatalf("creating product failed: %s", err)
+	}
+
+	return prd
+}
+
+func createOrder(ctx context.Context, t *testing.T, bus *orderbus.OrderBus, userID uuid.UUID, productID uuid.UUID, quantity int) orderbus.Order {
+	t.Helper()
+
+	ord, err := bus.Create(ctx, orderbus.NewOrder{
+		UserID: userID,
+		Items:  []orderbus.NewItem{{ProductID: productID, Quantity: quantity}},
+	})
+	if err != nil {
+		t.Fatalf("creating order failed: %s", err)
+	}
+
+	return ord
+}
+
+func assertStock(ctx context.Context, t *testing.T, bus *productbus.ProductBus, productID uuid.UUID, quantity int) {
+	t.Helper()
+
+	prd, err := bus.QueryByID(ctx, productID)
+	if err != nil {
+		t.Fatalf("querying product failed: %s", err)
+	}
+
+	if prd.Quantity != quantity {
+		t.Errorf("quantity=%d, got=%d", quantity, prd.Quantity)
+	}
+}
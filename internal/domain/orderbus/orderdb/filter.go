@@ -0,0 +1,44 @@
+package orderdb
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+)
+
+// applyFilter appends the WHERE clause into the buffer, values are passed as named
+// parameters into data so they never end up inside of the query itself.
+func applyFilter(filter orderbus.QueryFilter, data map[string]any, buf *bytes.Buffer) {
+	var wc []string
+
+	if filter.ID != nil {
+		data["id"] = *filter.ID
+		wc = append(wc, "id = :id")
+	}
+
+	if filter.UserID != nil {
+		data["user_id"] = *filter.UserID
+		wc = append(wc, "user_id = :user_id")
+	}
+
+	if filter.Status != nil {
+		data["status"] = filter.Status.String()
+		wc = append(wc, "status = :status")
+	}
+
+	if filter.StartCreatedAt != nil {
+		data["start_date_created"] = filter.StartCreatedAt.UTC()
+		wc = append(wc, "date_created >= :start_date_created")
+	}
+
+	if filter.EndCreatedAt != nil {
+		data["end_date_created"] = filter.EndCreatedAt.UTC()
+		wc = append(wc, "date_created <= :end_date_created")
+	}
+
+	if len(wc) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+}
@@ -0,0 +1,89 @@
+package orderdb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+)
+
+type postgresOrder struct {
+	ID          uuid.UUID `db:"id"`
+	UserID      uuid.UUID `db:"user_id"`
+	Status      string    `db:"status"`
+	Total       int64     `db:"total"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+type postgresItem struct {
+	OrderID   uuid.UUID     `db:"order_id"`
+	Position  int           `db:"position"`
+	ProductID uuid.NullUUID `db:"product_id"`
+	Quantity  int           `db:"quantity"`
+	Cost      int64         `db:"cost"`
+}
+
+func toPostgresOrder(ord orderbus.Order) postgresOrder {
+	return postgresOrder{
+		ID:          ord.ID,
+		UserID:      ord.UserID,
+		Status:      ord.Status.String(),
+		Total:       ord.Total,
+		DateCreated: ord.DateCreated.UTC(),
+		DateUpdated: ord.DateUpdated.UTC(),
+	}
+}
+
+func toPostgresItems(ord orderbus.Order) []postgresItem {
+	items := make([]postgresItem, len(ord.Items))
+	for i, item := range ord.Items {
+		items[i] = postgresItem{
+			OrderID:   ord.ID,
+			Position:  i,
+			ProductID: uuid.NullUUID{UUID: item.ProductID, Valid: true},
+			Quantity:  item.Quantity,
+			Cost:      item.Cost,
+		}
+	}
+	return items
+}
+
+func toBusOrder(pgOrd postgresOrder, pgItems []postgresItem) (orderbus.Order, error) {
+	status, err := orderbus.ParseStatus(pgOrd.Status)
+	if err != nil {
+		return orderbus.Order{}, fmt.Errorf("parsing status: %w", err)
+	}
+
+	items := make([]orderbus.Item, len(pgItems))
+	for i, pgItem := range pgItems {
+		items[i] = orderbus.Item{
+			ProductID: pgItem.ProductID.UUID,
+			Quantity:  pgItem.Quantity,
+			Cost:      pgItem.Cost,
+		}
+	}
+
+	return orderbus.Order{
+		ID:          pgOrd.ID,
+		UserID:      pgOrd.UserID,
+		Status:      status,
+		Items:       items,
+		Total:       pgOrd.Total,
+		DateCreated: pgOrd.DateCreated,
+		DateUpdated: pgOrd.DateUpdated,
+	}, nil
+}
+
+func toBusOrders(pgOrds []postgresOrder, pgItems map[uuid.UUID][]postgresItem) ([]orderbus.Order, error) {
+	orders := make([]orderbus.Order, len(pgOrds))
+	for i, pgOrd := range pgOrds {
+		ord, err := toBusOrder(pgOrd, pgItems[pgOrd.ID])
+		if err != nil {
+			return nil, err
+		}
+		orders[i] = ord
+	}
+	return orders, nil
+}
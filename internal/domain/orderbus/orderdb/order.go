@@ -0,0 +1,38 @@
+package orderdb
+
+import (
+	"fmt"
+
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/order"
+)
+
+// orderByFields maps the business order fields into database columns.
+var orderByFields = map[string]string{
+	orderbus.OrderByID:          "id",
+	orderbus.OrderByUserID:      "user_id",
+	orderbus.OrderByStatus:      "status",
+	orderbus.OrderByTotal:       "total",
+	orderbus.OrderByDateCreated: "date_created",
+}
+
+// orderByDirections maps the business order directions into sql keywords.
+var orderByDirections = map[string]string{
+	order.ASC:  "ASC",
+	order.DESC: "DESC",
+}
+
+func orderByClause(orderBy order.By) (string, error) {
+	by, ok := orderByFields[orderBy.Field]
+	if !ok {
+		return "", fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	//the direction is written into the query as well, so it is checked like the field.
+	direction, ok := orderByDirections[orderBy.Direction]
+	if !ok {
+		return "", fmt.Errorf("direction %q does not exist", orderBy.Direction)
+	}
+
+	return " ORDER BY " + by + " " + direction, nil
+}
@@ -0,0 +1,299 @@
+package orderdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/sqldb"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Store struct {
+	log *slog.Logger
+	db  sqlx.ExtContext
+}
+
+func NewStore(log *slog.Logger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// NewWithTx returns a copy of the store that runs its queries within the transaction.
+func (s *Store) NewWithTx(tx sqldb.CommitRollbacker) (orderbus.Storer, error) {
+	ec, err := sqldb.GetExtContext(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		log: s.log,
+		db:  ec,
+	}, nil
+}
+
+// Create locks the ordered products, checks and decrements their stock and stores the order
+// with its items in one transaction, the transaction of a store made by NewWithTx is joined.
+func (s *Store) Create(ctx context.Context, ord orderbus.Order) (orderbus.Order, error) {
+	err := sqldb.InTx(ctx, s.db, func(tx sqlx.ExtContext) error {
+		stock, err := s.lockProducts(ctx, tx, ord.Items)
+		if err != nil {
+			return err
+		}
+
+		const decrement = `UPDATE products SET quantity = quantity - $1, date_updated = $2 WHERE id = $3;`
+
+		ord.Total = 0
+		for i, item := range ord.Items {
+			prd, ok := stock[item.ProductID]
+			if !ok {
+				return fmt.Errorf("%w: %s", orderbus.ErrUnknownProduct, item.ProductID)
+			}
+
+			if prd.Quantity < item.Quantity {
+				return fmt.Errorf("%w: product %s has %d left", orderbus.ErrInsufficientStock, item.ProductID, prd.Quantity)
+			}
+
+			if _, err := sqldb.ExecContext(ctx, s.log, tx, decrement, item.Quantity, ord.DateCreated.UTC(), item.ProductID); err != nil {
+				return fmt.Errorf("decrementing stock of %s: %w", item.ProductID, err)
+			}
+
+			//a product can be ordered on more than one line, later lines see what is left.
+			prd.Quantity -= item.Quantity
+			stock[item.ProductID] = prd
+
+			ord.Items[i].Cost = prd.Cost
+			ord.Total += prd.Cost * int64(item.Quantity)
+		}
+
+		const insertOrder = `
+		INSERT INTO orders(id,user_id,status,total,date_created,date_updated)
+		VALUES (:id,:user_id,:status,:total,:date_created,:date_updated);
+		`
+		if err := sqldb.NamedExecContext(ctx, s.log, tx, insertOrder, toPostgresOrder(ord)); err != nil {
+			return fmt.Errorf("inserting order: %w", err)
+		}
+
+		const insertItem = `
+		INSERT INTO order_items(order_id,position,product_id,quantity,cost)
+		VALUES (:order_id,:position,:product_id,:quantity,:cost);
+		`
+		for _, item := range toPostgresItems(ord) {
+			if err := sqldb.NamedExecContext(ctx, s.log, tx, insertItem, item); err != nil {
+				return fmt.Errorf("inserting item: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return orderbus.Order{}, err
+	}
+
+	return ord, nil
+}
+
+// Update stores the status of the order as long as it is still in the from status,
+// orderbus.ErrInvalidTransition is returned when the order was changed in the meantime.
+func (s *Store) Update(ctx context.Context, ord orderbus.Order, from orderbus.Status) error {
+	return s.updateStatus(ctx, s.db, ord, from)
+}
+
+// Cancel stores the status of the order and puts its items back in stock in one transaction,
+// items of deleted products are skipped. Like Update the order must still be in the from status
+// so the items are never restocked twice.
+func (s *Store) Cancel(ctx context.Context, ord orderbus.Order, from orderbus.Status) error {
+	return sqldb.InTx(ctx, s.db, func(tx sqlx.ExtContext) error {
+		if err := s.updateStatus(ctx, tx, ord, from); err != nil {
+			return err
+		}
+
+		const restock = `
+		UPDATE products p SET
+			quantity = p.quantity + i.quantity,
+			date_updated = $2
+		FROM order_items i
+		WHERE i.order_id = $1 AND i.product_id = p.id;
+		`
+		if _, err := sqldb.ExecContext(ctx, s.log, tx, restock, ord.ID, ord.DateUpdated.UTC()); err != nil {
+			return fmt.Errorf("restocking items: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// QueryByID returns the order with the given id, sqldb.ErrNotFound is returned in case of not found.
+func (s *Store) QueryByID(ctx context.Context, orderID uuid.UUID) (orderbus.Order, error) {
+	const q = `
+	SELECT id,user_id,status,total,date_created,date_updated
+	FROM orders WHERE id = :id;
+	`
+	data := map[string]any{
+		"id": orderID,
+	}
+
+	var pgOrd postgresOrder
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, q, data, &pgOrd); err != nil {
+		return orderbus.Order{}, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	items, err := s.queryItems(ctx, []uuid.UUID{orderID})
+	if err != nil {
+		return orderbus.Order{}, err
+	}
+
+	return toBusOrder(pgOrd, items[orderID])
+}
+
+func (s *Store) Query(ctx context.Context, filter orderbus.QueryFilter, orderBy order.By, page page.Page) ([]orderbus.Order, error) {
+	data := map[string]any{
+		"offset":        page.Offset(),
+		"rows_per_page": page.RowsPerPage(),
+	}
+
+	const q = `
+	SELECT id,user_id,status,total,date_created,date_updated
+	FROM orders`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	orderClause, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, fmt.Errorf("orderByClause: %w", err)
+	}
+
+	buf.WriteString(orderClause)
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY;")
+
+	var pgOrds []postgresOrder
+	if err := sqldb.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &pgOrds); err != nil {
+		return nil, fmt.Errorf("namedQuerySlice: %w", err)
+	}
+
+	ids := make([]uuid.UUID, len(pgOrds))
+	for i, pgOrd := range pgOrds {
+		ids[i] = pgOrd.ID
+	}
+
+	items, err := s.queryItems(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return toBusOrders(pgOrds, items)
+}
+
+func (s *Store) Count(ctx context.Context, filter orderbus.QueryFilter) (int, error) {
+	data := map[string]any{}
+
+	const q = `SELECT COUNT(1) AS count FROM orders`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+		return 0, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	return count.Count, nil
+}
+
+// queryItems loads the items of the orders in one query, keyed by order id.
+func (s *Store) queryItems(ctx context.Context, orderIDs []uuid.UUID) (map[uuid.UUID][]postgresItem, error) {
+	items := make(map[uuid.UUID][]postgresItem, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return items, nil
+	}
+
+	const q = `
+	SELECT order_id,position,product_id,quantity,cost
+	FROM order_items WHERE order_id = ANY($1::uuid[])
+	ORDER BY order_id, position;
+	`
+	var pgItems []postgresItem
+	if err := sqldb.QuerySlice(ctx, s.log, s.db, q, &pgItems, uuidArray(orderIDs)); err != nil {
+		return nil, fmt.Errorf("querySlice: %w", err)
+	}
+
+	for _, item := range pgItems {
+		items[item.OrderID] = append(items[item.OrderID], item)
+	}
+
+	return items, nil
+}
+
+//==============================================================================
+
+func (s *Store) updateStatus(ctx context.Context, db sqlx.ExtContext, ord orderbus.Order, from orderbus.Status) error {
+	const q = `UPDATE orders SET status = $1, date_updated = $2 WHERE id = $3 AND status = $4;`
+
+	res, err := sqldb.ExecContext(ctx, s.log, db, q, ord.Status.String(), ord.DateUpdated.UTC(), ord.ID, from.String())
+	if err != nil {
+		return fmt.Errorf("execContext: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: order is not %s anymore", orderbus.ErrInvalidTransition, from)
+	}
+
+	return nil
+}
+
+type lockedProduct struct {
+	ID       uuid.UUID `db:"id"`
+	Cost     int64     `db:"cost"`
+	Quantity int       `db:"quantity"`
+}
+
+// lockProducts locks the rows of the ordered products until the transaction ends, rows are
+// locked in id order so concurrent orders of the same products can not deadlock.
+func (s *Store) lockProducts(ctx context.Context, tx sqlx.ExtContext, items []orderbus.Item) (map[uuid.UUID]lockedProduct, error) {
+	ids := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		ids[i] = item.ProductID
+	}
+
+	const q = `
+	SELECT id,cost,quantity
+	FROM products WHERE id = ANY($1::uuid[])
+	ORDER BY id
+	FOR UPDATE;
+	`
+	var products []lockedProduct
+	if err := sqldb.QuerySlice(ctx, s.log, tx, q, &products, uuidArray(ids)); err != nil {
+		return nil, fmt.Errorf("locking products: %w", err)
+	}
+
+	stock := make(map[uuid.UUID]lockedProduct, len(products))
+	for _, prd := range products {
+		stock[prd.ID] = prd
+	}
+
+	return stock, nil
+}
+
+func uuidArray(ids []uuid.UUID) pq.StringArray {
+	arr := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		arr[i] = id.String()
+	}
+	return arr
+}
@@ -0,0 +1,62 @@
+package orderbus
+
+import "fmt"
+
+var (
+	StatusPending   = newStatus("PENDING")
+	StatusPaid      = newStatus("PAID")
+	StatusShipped   = newStatus("SHIPPED")
+	StatusCancelled = newStatus("CANCELLED")
+)
+
+// set of known statuses to this app.
+var statuses = make(map[string]Status)
+
+// transitions holds the statuses an order can move into from each status, shipped and cancelled
+// orders are final.
+var transitions = map[Status][]Status{
+	StatusPending: {StatusPaid, StatusCancelled},
+	StatusPaid:    {StatusShipped, StatusCancelled},
+}
+
+// Status represents the state of an order, since only a set of values and transitions are
+// allowed we created a type.
+type Status struct {
+	value string
+}
+
+func newStatus(value string) Status {
+	s := Status{value: value}
+	statuses[s.value] = s
+	return s
+}
+
+func (s Status) String() string {
+	return s.value
+}
+
+func (s Status) Equal(status Status) bool {
+	return s.value == status.value
+}
+
+func (s Status) MarshalText() (text []byte, err error) {
+	return []byte(s.value), nil
+}
+
+// CanTransitionTo reports whether an order in this status can move into next.
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed.Equal(next) {
+			return true
+		}
+	}
+	return false
+}
+
+func ParseStatus(value string) (Status, error) {
+	s, ok := statuses[value]
+	if !ok {
+		return Status{}, fmt.Errorf("invalid status: %q", value)
+	}
+	return s, nil
+}
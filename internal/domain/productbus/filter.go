@@ -0,0 +1,19 @@
+package productbus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueryFilter represents all the fields that can be used for filtering.
+type QueryFilter struct {
+	ID             *uuid.UUID
+	UserID         *uuid.UUID
+	Name           *string
+	SKU            *string
+	MinCost        *int64
+	MaxCost        *int64
+	StartCreatedAt *time.Time
+	EndCreatedAt   *time.Time
+}
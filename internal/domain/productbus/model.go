@@ -0,0 +1,35 @@
+package productbus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Product represents an item that can be sold, Cost is stored in minor units (ie: cents) so no
+// precision is lost on the way to and from the database.
+type Product struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Name        string
+	SKU         string
+	Cost        int64
+	Quantity    int
+	DateCreated time.Time
+	DateUpdated time.Time
+}
+
+type NewProduct struct {
+	UserID   uuid.UUID
+	Name     string
+	SKU      string
+	Cost     int64
+	Quantity int
+}
+
+type UpdateProduct struct {
+	Name     *string
+	SKU      *string
+	Cost     *int64
+	Quantity *int
+}
@@ -0,0 +1,16 @@
+package productbus
+
+import "github.com/hamidoujand/sales/internal/order"
+
+// DefaultOrderBy represents the default way we sort products.
+var DefaultOrderBy = order.NewBy(OrderByID, order.ASC)
+
+// set of fields that products can be ordered by.
+const (
+	OrderByID       = "product_id"
+	OrderByUserID   = "user_id"
+	OrderByName     = "name"
+	OrderBySKU      = "sku"
+	OrderByCost     = "cost"
+	OrderByQuantity = "quantity"
+)
@@ -0,0 +1,150 @@
+// Package productbus provides the business logic for managing the product catalog.
+package productbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrDuplicatedSKU   = errors.New("sku is not unique")
+)
+
+func init() {
+	errs.Register(ErrProductNotFound, errs.Spec{Code: "PRODUCT_NOT_FOUND", Status: http.StatusNotFound, Message: "product not found"})
+	errs.Register(ErrDuplicatedSKU, errs.Spec{Code: "DUPLICATED_SKU", Status: http.StatusConflict, Message: "sku is already in use"})
+}
+
+// Storer represents the required behavior from the storage engine.
+type Storer interface {
+	NewWithTx(tx sqldb.CommitRollbacker) (Storer, error)
+	Create(ctx context.Context, prd Product) error
+	// Update stores every field of the product but its quantity and returns the stored product.
+	Update(ctx context.Context, prd Product) (Product, error)
+	UpdateQuantity(ctx context.Context, prd Product) error
+	Delete(ctx context.Context, prd Product) error
+	QueryByID(ctx context.Context, productID uuid.UUID) (Product, error)
+	Query(ctx context.Context, filter QueryFilter, orderBy order.By, page page.Page) ([]Product, error)
+	Count(ctx context.Context, filter QueryFilter) (int, error)
+}
+
+type ProductBus struct {
+	store Storer
+}
+
+func New(store Storer) *ProductBus {
+	return &ProductBus{
+		store: store,
+	}
+}
+
+// NewWithTx returns a copy of the bus that runs within the transaction.
+func (p *ProductBus) NewWithTx(tx sqldb.CommitRollbacker) (*ProductBus, error) {
+	store, err := p.store.NewWithTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProductBus{
+		store: store,
+	}, nil
+}
+
+func (p *ProductBus) Create(ctx context.Context, np NewProduct) (Product, error) {
+	now := time.Now()
+	prd := Product{
+		ID:          uuid.New(),
+		UserID:      np.UserID,
+		Name:        np.Name,
+		SKU:         np.SKU,
+		Cost:        np.Cost,
+		Quantity:    np.Quantity,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := p.store.Create(ctx, prd); err != nil {
+		return Product{}, fmt.Errorf("creating product: %w", err)
+	}
+	return prd, nil
+}
+
+// Update applies the updates to the product. The quantity is only written when it is part of the
+// updates, so stock taken by orders in the meantime is kept.
+func (p *ProductBus) Update(ctx context.Context, prd Product, updates UpdateProduct) (Product, error) {
+	if updates.Name != nil {
+		prd.Name = *updates.Name
+	}
+
+	if updates.SKU != nil {
+		prd.SKU = *updates.SKU
+	}
+
+	if updates.Cost != nil {
+		prd.Cost = *updates.Cost
+	}
+
+	prd.DateUpdated = time.Now()
+
+	//the quantity read with prd may be stale by now, it is only written when it is the update.
+	updated, err := p.store.Update(ctx, prd)
+	if err != nil {
+		return Product{}, fmt.Errorf("updating product: %w", err)
+	}
+
+	if updates.Quantity != nil {
+		updated.Quantity = *updates.Quantity
+		if err := p.store.UpdateQuantity(ctx, updated); err != nil {
+			return Product{}, fmt.Errorf("updating quantity: %w", err)
+		}
+	}
+
+	return updated, nil
+}
+
+func (p *ProductBus) Delete(ctx context.Context, prd Product) error {
+	if err := p.store.Delete(ctx, prd); err != nil {
+		return fmt.Errorf("deleting product: %w", err)
+	}
+
+	return nil
+}
+
+func (p *ProductBus) QueryByID(ctx context.Context, productID uuid.UUID) (Product, error) {
+	prd, err := p.store.QueryByID(ctx, productID)
+	if err != nil {
+		//check for not-found
+		if errors.Is(err, sqldb.ErrNotFound) {
+			return Product{}, ErrProductNotFound
+		}
+		return Product{}, fmt.Errorf("query by ID: %w", err)
+	}
+
+	return prd, nil
+}
+
+func (p *ProductBus) Query(ctx context.Context, filter QueryFilter, order order.By, page page.Page) ([]Product, error) {
+	products, err := p.store.Query(ctx, filter, order, page)
+	if err != nil {
+		return nil, fmt.Errorf("querying products: %w", err)
+	}
+	return products, nil
+}
+
+func (p *ProductBus) Count(ctx context.Context, filter QueryFilter) (int, error) {
+	count, err := p.store.Count(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("counting products: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,197 @@
+package productbus_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/domain/productbus/productdb"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/seed"
+)
+
+func TestCreate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "create_product")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	bus := productbus.New(productdb.NewStore(database.Log, database.DB))
+
+	np := productbus.NewProduct{
+		UserID:   usr.ID,
+		Name:     "Gopher Plush",
+		SKU:      "GPH-001",
+		Cost:     1999,
+		Quantity: 10,
+	}
+
+	prd, err := bus.Create(ctx, np)
+	if err != nil {
+		t.Fatalf("creating product failed: %s", err)
+	}
+
+	fetched, err := bus.QueryByID(ctx, prd.ID)
+	if err != nil {
+		t.Fatalf("querying product by id failed: %s", err)
+	}
+
+	if fetched.UserID != usr.ID {
+		t.Errorf("userID=%s, got=%s", usr.ID, fetched.UserID)
+	}
+
+	if fetched.Cost != np.Cost {
+		t.Errorf("cost=%d, got=%d", np.Cost, fetched.Cost)
+	}
+
+	if fetched.Quantity != np.Quantity {
+		t.Errorf("quantity=%d, got=%d", np.Quantity, fetched.Quantity)
+	}
+
+	//duplicated sku
+	if _, err := bus.Create(ctx, np); !errors.Is(err, productbus.ErrDuplicatedSKU) {
+		t.Errorf("err=%v, got=%v", productbus.ErrDuplicatedSKU, err)
+	}
+
+	//negative quantities are rejected by the database as well.
+	np.SKU = "GPH-002"
+	np.Quantity = -1
+	if _, err := bus.Create(ctx, np); err == nil {
+		t.Error("expected negative quantity to fail")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "update_product")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	bus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	prd := createProduct(ctx, t, bus, usr.ID, "Gopher Plush", "GPH-001", 1999)
+
+	name := "Gopher Mug"
+	cost := int64(1250)
+	quantity := 3
+
+	updated, err := bus.Update(ctx, prd, productbus.UpdateProduct{Name: &name, Cost: &cost, Quantity: &quantity})
+	if err != nil {
+		t.Fatalf("updating product failed: %s", err)
+	}
+
+	fetched, err := bus.QueryByID(ctx, prd.ID)
+	if err != nil {
+		t.Fatalf("querying product by id failed: %s", err)
+	}
+
+	if fetched.Name != updated.Name || fetched.Cost != cost || fetched.Quantity != quantity {
+		t.Errorf("expected %s/%d/%d, got %s/%d/%d", name, cost, quantity, fetched.Name, fetched.Cost, fetched.Quantity)
+	}
+
+	if fetched.SKU != prd.SKU {
+		t.Errorf("sku=%s, got=%s", prd.SKU, fetched.SKU)
+	}
+
+	//duplicated sku
+	other := createProduct(ctx, t, bus, usr.ID, "Gopher Cap", "GPH-002", 999)
+	if _, err := bus.Update(ctx, other, productbus.UpdateProduct{SKU: &prd.SKU}); !errors.Is(err, productbus.ErrDuplicatedSKU) {
+		t.Errorf("err=%v, got=%v", productbus.ErrDuplicatedSKU, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "delete_product")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	bus := productbus.New(productdb.NewStore(database.Log, database.DB))
+	prd := createProduct(ctx, t, bus, usr.ID, "Gopher Plush", "GPH-001", 1999)
+
+	if err := bus.Delete(ctx, prd); err != nil {
+		t.Fatalf("deleting product failed: %s", err)
+	}
+
+	if _, err := bus.QueryByID(ctx, prd.ID); !errors.Is(err, productbus.ErrProductNotFound) {
+		t.Errorf("err=%v, got=%v", productbus.ErrProductNotFound, err)
+	}
+
+	if _, err := bus.QueryByID(ctx, uuid.New()); !errors.Is(err, productbus.ErrProductNotFound) {
+		t.Errorf("err=%v, got=%v", productbus.ErrProductNotFound, err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "query_products")
+
+	users := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 2}).Users
+	john, jane := users[0], users[1]
+	bus := productbus.New(productdb.NewStore(database.Log, database.DB))
+
+	createProduct(ctx, t, bus, john.ID, "Gopher Plush", "GPH-001", 1999)
+	createProduct(ctx, t, bus, john.ID, "Gopher Mug", "GPH-002", 1250)
+	cheapest := createProduct(ctx, t, bus, jane.ID, "Gopher Cap", "GPH-003", 999)
+
+	pg, err := page.Parse("1", "2")
+	if err != nil {
+		t.Fatalf("parsing page: %s", err)
+	}
+
+	byCost := order.NewBy(productbus.OrderByCost, order.ASC)
+
+	products, err := bus.Query(ctx, productbus.QueryFilter{}, byCost, pg)
+	if err != nil {
+		t.Fatalf("querying products failed: %s", err)
+	}
+
+	if len(products) != 2 || products[0].ID != cheapest.ID {
+		t.Fatalf("expected the cheapest product first, got %d products", len(products))
+	}
+
+	total, err := bus.Count(ctx, productbus.QueryFilter{UserID: &john.ID})
+	if err != nil {
+		t.Fatalf("counting products failed: %s", err)
+	}
+
+	if total != 2 {
+		t.Errorf("total=%d, got=%d", 2, total)
+	}
+
+	minCost, maxCost := int64(1000), int64(1500)
+	products, err = bus.Query(ctx, productbus.QueryFilter{MinCost: &minCost, MaxCost: &maxCost}, byCost, pg)
+	if err != nil {
+		t.Fatalf("querying products by cost failed: %s", err)
+	}
+
+	if len(products) != 1 || products[0].SKU != "GPH-002" {
+		t.Errorf("expected only GPH-002 to match the cost filter, got %d products", len(products))
+	}
+
+	if _, err := bus.Query(ctx, productbus.QueryFilter{}, order.NewBy("unknown", order.ASC), pg); err == nil {
+		t.Error("expected unknown order field to fail")
+	}
+}
+
+func createProduct(ctx context.Context, t *testing.T, bus *productbus.ProductBus, userID uuid.UUID, name string, sku string, cost int64) productbus.Product {
+	t.Helper()
+
+	prd, err := bus.Create(ctx, productbus.NewProduct{
+		UserID:   userID,
+		Name:     name,
+		SKU:      sku,
+		Cost:     cost,
+		Quantity: 10,
+	})
+	if err != nil {
+		t.Fatalf("creating product failed: %s", err)
+	}
+
+	return prd
+}
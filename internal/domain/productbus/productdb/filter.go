@@ -0,0 +1,60 @@
+package productdb
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+// applyFilter appends the WHERE clause into the buffer, values are passed as named
+// parameters into data so they never end up inside of the query itself.
+func applyFilter(filter productbus.QueryFilter, data map[string]any, buf *bytes.Buffer) {
+	var wc []string
+
+	if filter.ID != nil {
+		data["id"] = *filter.ID
+		wc = append(wc, "id = :id")
+	}
+
+	if filter.UserID != nil {
+		data["user_id"] = *filter.UserID
+		wc = append(wc, "user_id = :user_id")
+	}
+
+	if filter.Name != nil {
+		data["name"] = sqldb.Contains(*filter.Name)
+		wc = append(wc, `name ILIKE :name ESCAPE '\'`)
+	}
+
+	if filter.SKU != nil {
+		data["sku"] = *filter.SKU
+		wc = append(wc, "sku = :sku")
+	}
+
+	if filter.MinCost != nil {
+		data["min_cost"] = *filter.MinCost
+		wc = append(wc, "cost >= :min_cost")
+	}
+
+	if filter.MaxCost != nil {
+		data["max_cost"] = *filter.MaxCost
+		wc = append(wc, "cost <= :max_cost")
+	}
+
+	if filter.StartCreatedAt != nil {
+		data["start_date_created"] = filter.StartCreatedAt.UTC()
+		wc = append(wc, "date_created >= :start_date_created")
+	}
+
+	if filter.EndCreatedAt != nil {
+		data["end_date_created"] = filter.EndCreatedAt.UTC()
+		wc = append(wc, "date_created <= :end_date_created")
+	}
+
+	if len(wc) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+}
@@ -0,0 +1,53 @@
+package productdb
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+)
+
+type postgresProduct struct {
+	ID          uuid.UUID `db:"id"`
+	UserID      uuid.UUID `db:"user_id"`
+	Name        string    `db:"name"`
+	SKU         string    `db:"sku"`
+	Cost        int64     `db:"cost"`
+	Quantity    int       `db:"quantity"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+func toPostgresProduct(prd productbus.Product) postgresProduct {
+	return postgresProduct{
+		ID:          prd.ID,
+		UserID:      prd.UserID,
+		Name:        prd.Name,
+		SKU:         prd.SKU,
+		Cost:        prd.Cost,
+		Quantity:    prd.Quantity,
+		DateCreated: prd.DateCreated.UTC(),
+		DateUpdated: prd.DateUpdated.UTC(),
+	}
+}
+
+func toBusProduct(pgPrd postgresProduct) productbus.Product {
+	return productbus.Product{
+		ID:          pgPrd.ID,
+		UserID:      pgPrd.UserID,
+		Name:        pgPrd.Name,
+		SKU:         pgPrd.SKU,
+		Cost:        pgPrd.Cost,
+		Quantity:    pgPrd.Quantity,
+		DateCreated: pgPrd.DateCreated,
+		DateUpdated: pgPrd.DateUpdated,
+	}
+}
+
+func toBusProducts(pgPrds []postgresProduct) []productbus.Product {
+	products := make([]productbus.Product, len(pgPrds))
+	for i, pgPrd := range pgPrds {
+		products[i] = toBusProduct(pgPrd)
+	}
+	return products
+}
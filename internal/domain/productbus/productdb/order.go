@@ -0,0 +1,39 @@
+package productdb
+
+import (
+	"fmt"
+
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/order"
+)
+
+// orderByFields maps the business order fields into database columns.
+var orderByFields = map[string]string{
+	productbus.OrderByID:       "id",
+	productbus.OrderByUserID:   "user_id",
+	productbus.OrderByName:     "name",
+	productbus.OrderBySKU:      "sku",
+	productbus.OrderByCost:     "cost",
+	productbus.OrderByQuantity: "quantity",
+}
+
+// orderByDirections maps the business order directions into sql keywords.
+var orderByDirections = map[string]string{
+	order.ASC:  "ASC",
+	order.DESC: "DESC",
+}
+
+func orderByClause(orderBy order.By) (string, error) {
+	by, ok := orderByFields[orderBy.Field]
+	if !ok {
+		return "", fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	//the direction is written into the query as well, so it is checked like the field.
+	direction, ok := orderByDirections[orderBy.Direction]
+	if !ok {
+		return "", fmt.Errorf("direction %q does not exist", orderBy.Direction)
+	}
+
+	return " ORDER BY " + by + " " + direction, nil
+}
@@ -0,0 +1,165 @@
+package productdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+type Store struct {
+	log *slog.Logger
+	db  sqlx.ExtContext
+}
+
+func NewStore(log *slog.Logger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// NewWithTx returns a copy of the store that runs its queries within the transaction.
+func (s *Store) NewWithTx(tx sqldb.CommitRollbacker) (productbus.Storer, error) {
+	ec, err := sqldb.GetExtContext(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		log: s.log,
+		db:  ec,
+	}, nil
+}
+
+func (s *Store) Create(ctx context.Context, prd productbus.Product) error {
+	const q = `
+	INSERT INTO products(id,user_id,name,sku,cost,quantity,date_created,date_updated)
+	VALUES (:id,:user_id,:name,:sku,:cost,:quantity,:date_created,:date_updated);
+	`
+	if err := sqldb.NamedExecContext(ctx, s.log, s.db, q, toPostgresProduct(prd)); err != nil {
+		if errors.Is(err, sqldb.ErrDuplicatedEntry) {
+			return productbus.ErrDuplicatedSKU
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+	return nil
+}
+
+// Update stores every field but the quantity, the stock is only changed through UpdateQuantity so
+// the decrements of concurrent orders are not written over. The stored product is returned.
+func (s *Store) Update(ctx context.Context, prd productbus.Product) (productbus.Product, error) {
+	const q = `
+	UPDATE products SET
+		name = :name,
+		sku = :sku,
+		cost = :cost,
+		date_updated = :date_updated
+	WHERE id = :id
+	RETURNING id,user_id,name,sku,cost,quantity,date_created,date_updated;
+	`
+	var pgPrd postgresProduct
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, q, toPostgresProduct(prd), &pgPrd); err != nil {
+		if errors.Is(err, sqldb.ErrDuplicatedEntry) {
+			return productbus.Product{}, productbus.ErrDuplicatedSKU
+		}
+		return productbus.Product{}, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+	return toBusProduct(pgPrd), nil
+}
+
+// UpdateQuantity sets the stock of the product.
+func (s *Store) UpdateQuantity(ctx context.Context, prd productbus.Product) error {
+	const q = `
+	UPDATE products SET
+		quantity = :quantity,
+		date_updated = :date_updated
+	WHERE id = :id;
+	`
+	if err := sqldb.NamedExecContext(ctx, s.log, s.db, q, toPostgresProduct(prd)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, prd productbus.Product) error {
+	const q = `DELETE FROM products WHERE id = :id;`
+
+	if err := sqldb.NamedExecContext(ctx, s.log, s.db, q, toPostgresProduct(prd)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+	return nil
+}
+
+// QueryByID returns the product with the given id, sqldb.ErrNotFound is returned in case of not found.
+func (s *Store) QueryByID(ctx context.Context, productID uuid.UUID) (productbus.Product, error) {
+	const q = `
+	SELECT id,user_id,name,sku,cost,quantity,date_created,date_updated
+	FROM products WHERE id = :id;
+	`
+	data := map[string]any{
+		"id": productID,
+	}
+
+	var pgPrd postgresProduct
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, q, data, &pgPrd); err != nil {
+		return productbus.Product{}, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	return toBusProduct(pgPrd), nil
+}
+
+func (s *Store) Query(ctx context.Context, filter productbus.QueryFilter, orderBy order.By, page page.Page) ([]productbus.Product, error) {
+	data := map[string]any{
+		"offset":        page.Offset(),
+		"rows_per_page": page.RowsPerPage(),
+	}
+
+	const q = `
+	SELECT id,user_id,name,sku,cost,quantity,date_created,date_updated
+	FROM products`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	orderClause, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, fmt.Errorf("orderByClause: %w", err)
+	}
+
+	buf.WriteString(orderClause)
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY;")
+
+	var pgPrds []postgresProduct
+	if err := sqldb.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &pgPrds); err != nil {
+		return nil, fmt.Errorf("namedQuerySlice: %w", err)
+	}
+
+	return toBusProducts(pgPrds), nil
+}
+
+func (s *Store) Count(ctx context.Context, filter productbus.QueryFilter) (int, error) {
+	data := map[string]any{}
+
+	const q = `SELECT COUNT(1) AS count FROM products`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+		return 0, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	return count.Count, nil
+}
@@ -0,0 +1,38 @@
+package tokenbus
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken represents a stored refresh token, only the hash of the opaque token is kept.
+type RefreshToken struct {
+	ID              uuid.UUID
+	FamilyID        uuid.UUID
+	UserID          uuid.UUID
+	TokenHash       []byte
+	AccessJTI       string
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	RevokedAt       *time.Time
+	RevokeReason    RevokeReason //set alongside RevokedAt.
+	DateCreated     time.Time
+}
+
+// RevokeReason tells why a refresh token can not be used anymore.
+type RevokeReason string
+
+const (
+	ReasonUsed    RevokeReason = "used"    //the token was exchanged for a new pair.
+	ReasonRevoked RevokeReason = "revoked" //the family was revoked by a logout or a detected reuse.
+)
+
+// NewRefreshToken represents the data required to issue a refresh token. A zero FamilyID starts
+// a new family, otherwise the token is a rotation of an existing family.
+type NewRefreshToken struct {
+	UserID          uuid.UUID
+	FamilyID        uuid.UUID
+	AccessJTI       string
+	AccessExpiresAt time.Time
+}
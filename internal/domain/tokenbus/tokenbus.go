@@ -0,0 +1,173 @@
+// Package tokenbus provides the business logic for refresh tokens and access token revocation.
+package tokenbus
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid refresh token")
+	ErrTokenExpired = errors.New("refresh token expired")
+	ErrTokenReused  = errors.New("refresh token reused")
+)
+
+func init() {
+	errs.Register(ErrInvalidToken, errs.Spec{Code: "INVALID_REFRESH_TOKEN", Status: http.StatusUnauthorized, Message: "invalid refresh token"})
+	errs.Register(ErrTokenExpired, errs.Spec{Code: "REFRESH_TOKEN_EXPIRED", Status: http.StatusUnauthorized, Message: "refresh token expired"})
+	errs.Register(ErrTokenReused, errs.Spec{Code: "REFRESH_TOKEN_REUSED", Status: http.StatusUnauthorized, Message: "refresh token reused"})
+}
+
+// Storer represents the required behavior from the storage engine.
+type Storer interface {
+	NewWithTx(tx sqldb.CommitRollbacker) (Storer, error)
+	Create(ctx context.Context, rt RefreshToken) error
+	QueryByHash(ctx context.Context, hash []byte) (RefreshToken, error)
+	Revoke(ctx context.Context, rt RefreshToken, now time.Time) error
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, now time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+type TokenBus struct {
+	store      Storer
+	refreshTTL time.Duration
+
+	//reuse revokes the family outside of any transaction, a rolled back request must not undo it.
+	reuse Storer
+}
+
+func New(store Storer, refreshTTL time.Duration) *TokenBus {
+	return &TokenBus{
+		store:      store,
+		refreshTTL: refreshTTL,
+		reuse:      store,
+	}
+}
+
+// NewWithTx returns a copy of the bus that runs within the transaction.
+func (b *TokenBus) NewWithTx(tx sqldb.CommitRollbacker) (*TokenBus, error) {
+	store, err := b.store.NewWithTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenBus{
+		store:      store,
+		refreshTTL: b.refreshTTL,
+		reuse:      b.reuse,
+	}, nil
+}
+
+// Create issues a new opaque refresh token, the returned string is the only place the token
+// exists in plain form.
+func (b *TokenBus) Create(ctx context.Context, nrt NewRefreshToken) (string, RefreshToken, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", RefreshToken{}, fmt.Errorf("generating token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(bs)
+
+	familyID := nrt.FamilyID
+	if familyID == uuid.Nil {
+		familyID = uuid.New()
+	}
+
+	now := time.Now()
+	rt := RefreshToken{
+		ID:              uuid.New(),
+		FamilyID:        familyID,
+		UserID:          nrt.UserID,
+		TokenHash:       hash(token),
+		AccessJTI:       nrt.AccessJTI,
+		AccessExpiresAt: nrt.AccessExpiresAt,
+		ExpiresAt:       now.Add(b.refreshTTL),
+		DateCreated:     now,
+	}
+
+	if err := b.store.Create(ctx, rt); err != nil {
+		return "", RefreshToken{}, fmt.Errorf("creating refresh token: %w", err)
+	}
+
+	return token, rt, nil
+}
+
+// Use consumes the refresh token so it can not be used again. Using a token that is already
+// consumed is treated as theft and revokes the whole family, that revocation is kept even when the
+// transaction of the bus is rolled back.
+func (b *TokenBus) Use(ctx context.Context, token string) (RefreshToken, error) {
+	rt, err := b.store.QueryByHash(ctx, hash(token))
+	if err != nil {
+		if errors.Is(err, sqldb.ErrNotFound) {
+			return RefreshToken{}, ErrInvalidToken
+		}
+		return RefreshToken{}, fmt.Errorf("query by hash: %w", err)
+	}
+
+	now := time.Now()
+
+	if rt.RevokedAt != nil {
+		if err := b.reuse.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+			return RefreshToken{}, fmt.Errorf("revoking family[%s]: %w", rt.FamilyID, err)
+		}
+		return RefreshToken{}, ErrTokenReused
+	}
+
+	if now.After(rt.ExpiresAt) {
+		return RefreshToken{}, ErrTokenExpired
+	}
+
+	if err := b.store.Revoke(ctx, rt, now); err != nil {
+		//someone else used this token in the meantime.
+		if errors.Is(err, ErrTokenReused) {
+			if err := b.reuse.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+				return RefreshToken{}, fmt.Errorf("revoking family[%s]: %w", rt.FamilyID, err)
+			}
+			return RefreshToken{}, ErrTokenReused
+		}
+		return RefreshToken{}, fmt.Errorf("revoking token[%s]: %w", rt.ID, err)
+	}
+
+	return rt, nil
+}
+
+// RevokeFamily revokes every refresh token of the family that the given token belongs to, alongside
+// the access tokens issued with them.
+func (b *TokenBus) RevokeFamily(ctx context.Context, token string) error {
+	rt, err := b.store.QueryByHash(ctx, hash(token))
+	if err != nil {
+		if errors.Is(err, sqldb.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("query by hash: %w", err)
+	}
+
+	if err := b.store.RevokeFamily(ctx, rt.FamilyID, time.Now()); err != nil {
+		return fmt.Errorf("revoking family[%s]: %w", rt.FamilyID, err)
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether the access token with the given jti has been revoked.
+func (b *TokenBus) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	revoked, err := b.store.IsRevoked(ctx, jti)
+	if err != nil {
+		return false, fmt.Errorf("is revoked: %w", err)
+	}
+	return revoked, nil
+}
+
+func hash(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,193 @@
+package tokenbus_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus/tokendb"
+	"github.com/hamidoujand/sales/internal/seed"
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+func TestRotation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "refresh_token_rotation")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	bus := tokenbus.New(tokendb.NewStore(database.Log, database.DB), time.Hour)
+
+	firstJTI := uuid.NewString()
+	first, created, err := bus.Create(ctx, tokenbus.NewRefreshToken{
+		UserID:          usr.ID,
+		AccessJTI:       firstJTI,
+		AccessExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("creating refresh token failed: %s", err)
+	}
+
+	//an access token that expired long ago has no reason to stay in the revocation list.
+	const expired = `INSERT INTO revoked_tokens(jti,expires_at) VALUES ('expired-jti', $1);`
+	if _, err := database.DB.ExecContext(ctx, expired, time.Now().Add(-time.Hour).UTC()); err != nil {
+		t.Fatalf("inserting expired revocation: %s", err)
+	}
+
+	used, err := bus.Use(ctx, first)
+	if err != nil {
+		t.Fatalf("using refresh token failed: %s", err)
+	}
+
+	if used.UserID != usr.ID {
+		t.Errorf("userID=%s, got=%s", usr.ID, used.UserID)
+	}
+
+	//rotate into the same family
+	secondJTI := uuid.NewString()
+	second, rotated, err := bus.Create(ctx, tokenbus.NewRefreshToken{
+		UserID:          usr.ID,
+		FamilyID:        used.FamilyID,
+		AccessJTI:       secondJTI,
+		AccessExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("rotating refresh token failed: %s", err)
+	}
+
+	if rotated.FamilyID != created.FamilyID {
+		t.Errorf("familyID=%s, got=%s", created.FamilyID, rotated.FamilyID)
+	}
+
+	//reusing the first token must revoke the whole family
+	if _, err := bus.Use(ctx, first); !errors.Is(err, tokenbus.ErrTokenReused) {
+		t.Fatalf("err=%v, got=%v", tokenbus.ErrTokenReused, err)
+	}
+
+	if _, err := bus.Use(ctx, second); !errors.Is(err, tokenbus.ErrTokenReused) {
+		t.Errorf("expected the rotated token to be revoked with its family, got %v", err)
+	}
+
+	for _, jti := range []string{firstJTI, secondJTI} {
+		revoked, err := bus.IsRevoked(ctx, jti)
+		if err != nil {
+			t.Fatalf("checking revocation failed: %s", err)
+		}
+
+		if !revoked {
+			t.Errorf("expected access token %s to be revoked", jti)
+		}
+	}
+
+	reasons := map[string]tokenbus.RevokeReason{
+		firstJTI:  tokenbus.ReasonUsed,
+		secondJTI: tokenbus.ReasonRevoked,
+	}
+	for jti, expected := range reasons {
+		var reason string
+		const q = `SELECT revoke_reason FROM refresh_tokens WHERE access_jti = $1;`
+		if err := database.DB.GetContext(ctx, &reason, q, jti); err != nil {
+			t.Fatalf("querying revoke reason: %s", err)
+		}
+
+		if reason != string(expected) {
+			t.Errorf("reason=%s, got=%s", expected, reason)
+		}
+	}
+
+	if revoked, err := bus.IsRevoked(ctx, "expired-jti"); err != nil || revoked {
+		t.Errorf("expected expired revocations to be pruned, got revoked=%t err=%v", revoked, err)
+	}
+
+	if _, err := bus.Use(ctx, "unknown-token"); !errors.Is(err, tokenbus.ErrInvalidToken) {
+		t.Errorf("err=%v, got=%v", tokenbus.ErrInvalidToken, err)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "refresh_token_expired")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	bus := tokenbus.New(tokendb.NewStore(database.Log, database.DB), -time.Minute)
+
+	token, _, err := bus.Create(ctx, tokenbus.NewRefreshToken{
+		UserID:          usr.ID,
+		AccessJTI:       uuid.NewString(),
+		AccessExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("creating refresh token failed: %s", err)
+	}
+
+	if _, err := bus.Use(ctx, token); !errors.Is(err, tokenbus.ErrTokenExpired) {
+		t.Errorf("err=%v, got=%v", tokenbus.ErrTokenExpired, err)
+	}
+}
+
+func TestReuseUnderTx(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "refresh_token_reuse_under_tx")
+
+	usr := database.Seed(ctx, t, seed.Config{Seed: 1, Users: 1}).Users[0]
+	bus := tokenbus.New(tokendb.NewStore(database.Log, database.DB), time.Hour)
+	beginner := sqldb.NewBeginner(database.DB)
+
+	first, _, err := bus.Create(ctx, tokenbus.NewRefreshToken{
+		UserID:          usr.ID,
+		AccessJTI:       uuid.NewString(),
+		AccessExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("creating refresh token failed: %s", err)
+	}
+
+	//the token is used and rotated together, as a refresh request does.
+	var second string
+	err = sqldb.ExecUnderTx(ctx, beginner, func(tx sqldb.CommitRollbacker) error {
+		txBus, err := bus.NewWithTx(tx)
+		if err != nil {
+			return err
+		}
+
+		used, err := txBus.Use(ctx, first)
+		if err != nil {
+			return err
+		}
+
+		second, _, err = txBus.Create(ctx, tokenbus.NewRefreshToken{
+			UserID:          usr.ID,
+			FamilyID:        used.FamilyID,
+			AccessJTI:       uuid.NewString(),
+			AccessExpiresAt: time.Now().Add(time.Minute),
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("rotating refresh token failed: %s", err)
+	}
+
+	//the request that detects the reuse fails and rolls back, the family must stay revoked.
+	err = sqldb.ExecUnderTx(ctx, beginner, func(tx sqldb.CommitRollbacker) error {
+		txBus, err := bus.NewWithTx(tx)
+		if err != nil {
+			return err
+		}
+
+		_, err = txBus.Use(ctx, first)
+		return err
+	})
+	if !errors.Is(err, tokenbus.ErrTokenReused) {
+		t.Fatalf("err=%v, got=%v", tokenbus.ErrTokenReused, err)
+	}
+
+	if _, err := bus.Use(ctx, second); !errors.Is(err, tokenbus.ErrTokenReused) {
+		t.Errorf("expected the rotated token to be revoked with its family, got %v", err)
+	}
+}
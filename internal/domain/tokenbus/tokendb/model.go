@@ -0,0 +1,67 @@
+package tokendb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus"
+)
+
+type postgresRefreshToken struct {
+	ID              uuid.UUID      `db:"id"`
+	FamilyID        uuid.UUID      `db:"family_id"`
+	UserID          uuid.UUID      `db:"user_id"`
+	TokenHash       []byte         `db:"token_hash"`
+	AccessJTI       string         `db:"access_jti"`
+	AccessExpiresAt time.Time      `db:"access_expires_at"`
+	ExpiresAt       time.Time      `db:"expires_at"`
+	RevokedAt       sql.NullTime   `db:"revoked_at"`
+	RevokeReason    sql.NullString `db:"revoke_reason"`
+	DateCreated     time.Time      `db:"date_created"`
+}
+
+func toPostgresRefreshToken(rt tokenbus.RefreshToken) postgresRefreshToken {
+	var revokedAt sql.NullTime
+	if rt.RevokedAt != nil {
+		revokedAt = sql.NullTime{Time: rt.RevokedAt.UTC(), Valid: true}
+	}
+
+	var reason sql.NullString
+	if rt.RevokeReason != "" {
+		reason = sql.NullString{String: string(rt.RevokeReason), Valid: true}
+	}
+
+	return postgresRefreshToken{
+		ID:              rt.ID,
+		FamilyID:        rt.FamilyID,
+		UserID:          rt.UserID,
+		TokenHash:       rt.TokenHash,
+		AccessJTI:       rt.AccessJTI,
+		AccessExpiresAt: rt.AccessExpiresAt.UTC(),
+		ExpiresAt:       rt.ExpiresAt.UTC(),
+		RevokedAt:       revokedAt,
+		RevokeReason:    reason,
+		DateCreated:     rt.DateCreated.UTC(),
+	}
+}
+
+func toBusRefreshToken(pgRT postgresRefreshToken) tokenbus.RefreshToken {
+	var revokedAt *time.Time
+	if pgRT.RevokedAt.Valid {
+		revokedAt = &pgRT.RevokedAt.Time
+	}
+
+	return tokenbus.RefreshToken{
+		ID:              pgRT.ID,
+		FamilyID:        pgRT.FamilyID,
+		UserID:          pgRT.UserID,
+		TokenHash:       pgRT.TokenHash,
+		AccessJTI:       pgRT.AccessJTI,
+		AccessExpiresAt: pgRT.AccessExpiresAt,
+		ExpiresAt:       pgRT.ExpiresAt,
+		RevokedAt:       revokedAt,
+		RevokeReason:    tokenbus.RevokeReason(pgRT.RevokeReason.String),
+		DateCreated:     pgRT.DateCreated,
+	}
+}
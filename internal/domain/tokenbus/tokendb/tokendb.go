@@ -0,0 +1,134 @@
+package tokendb
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/domain/tokenbus"
+	"github.com/hamidoujand/sales/internal/sqldb"
+	"github.com/jmoiron/sqlx"
+)
+
+type Store struct {
+	log *slog.Logger
+	db  sqlx.ExtContext
+}
+
+func NewStore(log *slog.Logger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// NewWithTx returns a copy of the store that runs its queries within the transaction.
+func (s *Store) NewWithTx(tx sqldb.CommitRollbacker) (tokenbus.Storer, error) {
+	ec, err := sqldb.GetExtContext(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		log: s.log,
+		db:  ec,
+	}, nil
+}
+
+func (s *Store) Create(ctx context.Context, rt tokenbus.RefreshToken) error {
+	const q = `
+	INSERT INTO refresh_tokens(id,family_id,user_id,token_hash,access_jti,access_expires_at,expires_at,revoked_at,revoke_reason,date_created)
+	VALUES (:id,:family_id,:user_id,:token_hash,:access_jti,:access_expires_at,:expires_at,:revoked_at,:revoke_reason,:date_created);
+	`
+	if err := sqldb.NamedExecContext(ctx, s.log, s.db, q, toPostgresRefreshToken(rt)); err != nil {
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+	return nil
+}
+
+// QueryByHash returns the refresh token with the given hash, sqldb.ErrNotFound is returned in case of not found.
+func (s *Store) QueryByHash(ctx context.Context, hash []byte) (tokenbus.RefreshToken, error) {
+	const q = `
+	SELECT id,family_id,user_id,token_hash,access_jti,access_expires_at,expires_at,revoked_at,revoke_reason,date_created
+	FROM refresh_tokens WHERE token_hash = :token_hash;
+	`
+	data := map[string]any{
+		"token_hash": hash,
+	}
+
+	var pgRT postgresRefreshToken
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, q, data, &pgRT); err != nil {
+		return tokenbus.RefreshToken{}, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	return toBusRefreshToken(pgRT), nil
+}
+
+// Revoke marks the token as used, tokenbus.ErrTokenReused is returned when the token was already revoked.
+func (s *Store) Revoke(ctx context.Context, rt tokenbus.RefreshToken, now time.Time) error {
+	const q = `UPDATE refresh_tokens SET revoked_at = $1, revoke_reason = $2 WHERE id = $3 AND revoked_at IS NULL;`
+
+	res, err := sqldb.ExecContext(ctx, s.log, s.db, q, now.UTC(), tokenbus.ReasonUsed, rt.ID)
+	if err != nil {
+		return fmt.Errorf("execContext: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rowsAffected: %w", err)
+	}
+
+	if affected == 0 {
+		return tokenbus.ErrTokenReused
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes all refresh tokens of a family and puts their access tokens into the revocation list.
+// Entries of the list whose access token expired are pruned on the way, they can not be presented anymore.
+func (s *Store) RevokeFamily(ctx context.Context, familyID uuid.UUID, now time.Time) error {
+	const revokeAccess = `
+	INSERT INTO revoked_tokens(jti,expires_at)
+	SELECT access_jti,access_expires_at FROM refresh_tokens
+	WHERE family_id = $1 AND access_expires_at > $2
+	ON CONFLICT (jti) DO NOTHING;
+	`
+	const revokeRefresh = `UPDATE refresh_tokens SET revoked_at = $1, revoke_reason = $2 WHERE family_id = $3 AND revoked_at IS NULL;`
+	const pruneAccess = `DELETE FROM revoked_tokens WHERE expires_at <= $1;`
+
+	return sqldb.InTx(ctx, s.db, func(tx sqlx.ExtContext) error {
+		if _, err := sqldb.ExecContext(ctx, s.log, tx, revokeAccess, familyID, now.UTC()); err != nil {
+			return fmt.Errorf("revoking access tokens: %w", err)
+		}
+
+		if _, err := sqldb.ExecContext(ctx, s.log, tx, revokeRefresh, now.UTC(), tokenbus.ReasonRevoked, familyID); err != nil {
+			return fmt.Errorf("revoking refresh tokens: %w", err)
+		}
+
+		if _, err := sqldb.ExecContext(ctx, s.log, tx, pruneAccess, now.UTC()); err != nil {
+			return fmt.Errorf("pruning revoked access tokens: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	const q = `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = :jti) AS revoked;`
+
+	data := map[string]any{
+		"jti": jti,
+	}
+
+	var result struct {
+		Revoked bool `db:"revoked"`
+	}
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, q, data, &result); err != nil {
+		return false, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	return result.Revoked, nil
+}
@@ -0,0 +1,15 @@
+package userbus
+
+import "github.com/hamidoujand/sales/internal/order"
+
+// DefaultOrderBy represents the default way we sort users.
+var DefaultOrderBy = order.NewBy(OrderByID, order.ASC)
+
+// set of fields that users can be ordered by.
+const (
+	OrderByID      = "user_id"
+	OrderByName    = "name"
+	OrderByEmail   = "email"
+	OrderByRoles   = "roles"
+	OrderByEnabled = "enabled"
+)
@@ -2,44 +2,81 @@ package userbus
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/mail"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/errs"
 	"github.com/hamidoujand/sales/internal/order"
 	"github.com/hamidoujand/sales/internal/page"
+	"github.com/hamidoujand/sales/internal/sqldb"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	ErrUserNotFound    = errors.New("user not found")
-	ErrDuplicatedEmail = errors.New("email is not unique")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrDuplicatedEmail       = errors.New("email is not unique")
+	ErrAuthenticationFailure = errors.New("authentication failed")
+	ErrUserDisabled          = errors.New("user is disabled")
+	ErrPasswordTooShort      = errors.New("password is too short")
+	ErrUserHasOrders         = errors.New("user has orders")
 )
 
-// storer represents the required behavior from the storage engine.
-type storer interface {
+func init() {
+	errs.Register(ErrUserNotFound, errs.Spec{Code: "USER_NOT_FOUND", Status: http.StatusNotFound, Message: "user not found"})
+	errs.Register(ErrDuplicatedEmail, errs.Spec{Code: "DUPLICATED_EMAIL", Status: http.StatusConflict, Message: "email is already in use"})
+	errs.Register(ErrAuthenticationFailure, errs.Spec{Code: "AUTHENTICATION_FAILED", Status: http.StatusUnauthorized, Message: "authentication failed"})
+	errs.Register(ErrUserDisabled, errs.Spec{Code: "USER_DISABLED", Status: http.StatusUnauthorized, Message: "user is disabled"})
+	errs.Register(ErrPasswordTooShort, errs.Spec{Code: "PASSWORD_TOO_SHORT", Status: http.StatusBadRequest, Message: fmt.Sprintf("password must be at least %d characters", MinPasswordLen)})
+	errs.Register(ErrUserHasOrders, errs.Spec{Code: "USER_HAS_ORDERS", Status: http.StatusConflict, Message: "user has orders, disable it instead"})
+}
+
+// MinPasswordLen is the minimum length of a password, every way of setting one goes through the bus.
+const MinPasswordLen = 8
+
+// Storer represents the required behavior from the storage engine.
+type Storer interface {
+	NewWithTx(tx sqldb.CommitRollbacker) (Storer, error)
 	Create(ctx context.Context, usr User) error
 	Update(ctx context.Context, usr User) error
 	Delete(ctx context.Context, usr User) error
 	QueryByID(ctx context.Context, userID uuid.UUID) (User, error)
 	QueryByEmail(ctx context.Context, email mail.Address) (User, error)
 	Query(ctx context.Context, filter QueryFilter, orderBy order.By, page page.Page) ([]User, error)
+	Count(ctx context.Context, filter QueryFilter) (int, error)
 }
 
 type UserBus struct {
-	store storer
+	store Storer
 }
 
-func New(store storer) *UserBus {
+func New(store Storer) *UserBus {
 	return &UserBus{
 		store: store,
 	}
 }
 
+// NewWithTx returns a copy of the bus that runs within the transaction.
+func (u *UserBus) NewWithTx(tx sqldb.CommitRollbacker) (*UserBus, error) {
+	store, err := u.store.NewWithTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserBus{
+		store: store,
+	}, nil
+}
+
 func (u *UserBus) Create(ctx context.Context, nu NewUser) (User, error) {
+	if err := CheckPassword(nu.Password); err != nil {
+		return User{}, err
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, fmt.Errorf("hashing password: %w", err)
@@ -65,6 +102,10 @@ func (u *UserBus) Create(ctx context.Context, nu NewUser) (User, error) {
 
 func (u *UserBus) Update(ctx context.Context, usr User, updates UpdateUser) (User, error) {
 	if updates.Password != nil {
+		if err := CheckPassword(*updates.Password); err != nil {
+			return User{}, err
+		}
+
 		hash, err := bcrypt.GenerateFromPassword([]byte(*updates.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return User{}, fmt.Errorf("hashing password: %w", err)
@@ -109,7 +150,7 @@ func (u *UserBus) QueryByID(ctx context.Context, userID uuid.UUID) (User, error)
 	usr, err := u.store.QueryByID(ctx, userID)
 	if err != nil {
 		//check for not-found
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, sqldb.ErrNotFound) {
 			return User{}, ErrUserNotFound
 		}
 		return User{}, fmt.Errorf("query by ID: %w", err)
@@ -118,6 +159,19 @@ func (u *UserBus) QueryByID(ctx context.Context, userID uuid.UUID) (User, error)
 	return usr, nil
 }
 
+func (u *UserBus) QueryByEmail(ctx context.Context, email mail.Address) (User, error) {
+	usr, err := u.store.QueryByEmail(ctx, email)
+	if err != nil {
+		//check for not-found
+		if errors.Is(err, sqldb.ErrNotFound) {
+			return User{}, ErrUserNotFound
+		}
+		return User{}, fmt.Errorf("query by email: %w", err)
+	}
+
+	return usr, nil
+}
+
 func (u *UserBus) Query(ctx context.Context, filter QueryFilter, order order.By, page page.Page) ([]User, error) {
 	users, err := u.store.Query(ctx, filter, order, page)
 	if err != nil {
@@ -125,3 +179,54 @@ func (u *UserBus) Query(ctx context.Context, filter QueryFilter, order order.By,
 	}
 	return users, nil
 }
+
+func (u *UserBus) Count(ctx context.Context, filter QueryFilter) (int, error) {
+	count, err := u.store.Count(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("counting users: %w", err)
+	}
+	return count, nil
+}
+
+// CheckPassword reports whether the password is allowed, callers can use it to validate input
+// before it reaches the bus.
+func CheckPassword(password string) error {
+	if len(password) < MinPasswordLen {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordTooShort, MinPasswordLen)
+	}
+	return nil
+}
+
+// dummyHash is compared against when the email is unknown, it has the cost of the stored hashes.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("generating dummy hash: %s", err))
+	}
+	return hash
+})
+
+// Authenticate finds the user by email and verifies the given password against the stored hash.
+// ErrAuthenticationFailure is returned for both unknown emails and wrong passwords so callers can
+// not find out which emails are registered.
+func (u *UserBus) Authenticate(ctx context.Context, email mail.Address, password string) (User, error) {
+	usr, err := u.QueryByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			//compare anyway so unknown emails take as long as wrong passwords.
+			bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+			return User{}, ErrAuthenticationFailure
+		}
+		return User{}, fmt.Errorf("query by email: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
+		return User{}, ErrAuthenticationFailure
+	}
+
+	if !usr.Enabled {
+		return User{}, ErrUserDisabled
+	}
+
+	return usr, nil
+}
@@ -3,13 +3,17 @@ package userbus_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"net/mail"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hamidoujand/sales/internal/dbtest"
 	"github.com/hamidoujand/sales/internal/domain/userbus"
 	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
+	"github.com/hamidoujand/sales/internal/order"
+	"github.com/hamidoujand/sales/internal/page"
 )
 
 func TestCreate(t *testing.T) {
@@ -17,7 +21,7 @@ func TestCreate(t *testing.T) {
 	defer cancel()
 	database := dbtest.NewDatabase(ctx, t, "create_user")
 
-	store := userdb.NewStore(database.DB)
+	store := userdb.NewStore(database.Log, database.DB)
 	bus := userbus.New(store)
 
 	email, err := mail.ParseAddress("john@gmail.com")
@@ -54,3 +58,274 @@ func TestCreate(t *testing.T) {
 	}
 
 }
+
+func TestUpdate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "update_user")
+
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	usr := createUser(ctx, t, bus, "John", "john@gmail.com")
+
+	name := "Jane"
+	email, err := mail.ParseAddress("jane@gmail.com")
+	if err != nil {
+		t.Fatalf("parsing email: %s", err)
+	}
+	enabled := false
+
+	uu := userbus.UpdateUser{
+		Name:    &name,
+		Email:   email,
+		Roles:   []userbus.Role{userbus.RoleAdmin},
+		Enabled: &enabled,
+	}
+
+	updated, err := bus.Update(ctx, usr, uu)
+	if err != nil {
+		t.Fatalf("updating user failed: %s", err)
+	}
+
+	fetched, err := bus.QueryByID(ctx, usr.ID)
+	if err != nil {
+		t.Fatalf("querying user by id failed: %s", err)
+	}
+
+	if fetched.Name != updated.Name {
+		t.Errorf("name=%s, got=%s", updated.Name, fetched.Name)
+	}
+
+	if fetched.Email.Address != email.Address {
+		t.Errorf("email=%s, got=%s", email.Address, fetched.Email.Address)
+	}
+
+	if len(fetched.Roles) != 1 || !fetched.Roles[0].Equal(userbus.RoleAdmin) {
+		t.Errorf("roles=%v, got=%v", uu.Roles, fetched.Roles)
+	}
+
+	if fetched.Enabled != enabled {
+		t.Errorf("enabled=%t, got=%t", enabled, fetched.Enabled)
+	}
+
+	//duplicated email
+	other := createUser(ctx, t, bus, "Bob", "bob@gmail.com")
+	if _, err := bus.Update(ctx, other, userbus.UpdateUser{Email: email}); !errors.Is(err, userbus.ErrDuplicatedEmail) {
+		t.Errorf("err=%v, got=%v", userbus.ErrDuplicatedEmail, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "delete_user")
+
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	usr := createUser(ctx, t, bus, "John", "john@gmail.com")
+
+	if err := bus.Delete(ctx, usr); err != nil {
+		t.Fatalf("deleting user failed: %s", err)
+	}
+
+	if _, err := bus.QueryByID(ctx, usr.ID); !errors.Is(err, userbus.ErrUserNotFound) {
+		t.Errorf("err=%v, got=%v", userbus.ErrUserNotFound, err)
+	}
+}
+
+func TestQueryByID(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "query_user_by_id")
+
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	usr := createUser(ctx, t, bus, "John", "john@gmail.com")
+
+	fetched, err := bus.QueryByID(ctx, usr.ID)
+	if err != nil {
+		t.Fatalf("querying user by id failed: %s", err)
+	}
+
+	if fetched.ID != usr.ID {
+		t.Errorf("id=%s, got=%s", usr.ID, fetched.ID)
+	}
+
+	if fetched.Email.Address != usr.Email.Address {
+		t.Errorf("email=%s, got=%s", usr.Email.Address, fetched.Email.Address)
+	}
+
+	if !bytes.Equal(fetched.PasswordHash, usr.PasswordHash) {
+		t.Errorf("expected password hash to be stored as is")
+	}
+
+	if _, err := bus.QueryByID(ctx, uuid.New()); !errors.Is(err, userbus.ErrUserNotFound) {
+		t.Errorf("err=%v, got=%v", userbus.ErrUserNotFound, err)
+	}
+}
+
+func TestQueryByEmail(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "query_user_by_email")
+
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	usr := createUser(ctx, t, bus, "John", "john@gmail.com")
+
+	fetched, err := bus.QueryByEmail(ctx, usr.Email)
+	if err != nil {
+		t.Fatalf("querying user by email failed: %s", err)
+	}
+
+	if fetched.ID != usr.ID {
+		t.Errorf("id=%s, got=%s", usr.ID, fetched.ID)
+	}
+
+	unknown, err := mail.ParseAddress("unknown@gmail.com")
+	if err != nil {
+		t.Fatalf("parsing email: %s", err)
+	}
+
+	if _, err := bus.QueryByEmail(ctx, *unknown); !errors.Is(err, userbus.ErrUserNotFound) {
+		t.Errorf("err=%v, got=%v", userbus.ErrUserNotFound, err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "query_users")
+
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	john := createUser(ctx, t, bus, "John", "john@gmail.com")
+	createUser(ctx, t, bus, "Jane", "jane@gmail.com")
+	createUser(ctx, t, bus, "Bob", "bob@gmail.com")
+
+	pg, err := page.Parse("1", "2")
+	if err != nil {
+		t.Fatalf("parsing page: %s", err)
+	}
+
+	byName := order.NewBy(userbus.OrderByName, order.ASC)
+
+	users, err := bus.Query(ctx, userbus.QueryFilter{}, byName, pg)
+	if err != nil {
+		t.Fatalf("querying users failed: %s", err)
+	}
+
+	if len(users) != 2 {
+		t.Fatalf("len=%d, got=%d", 2, len(users))
+	}
+
+	if users[0].Name != "Bob" || users[1].Name != "Jane" {
+		t.Errorf("expected users to be ordered by name, got %s, %s", users[0].Name, users[1].Name)
+	}
+
+	total, err := bus.Count(ctx, userbus.QueryFilter{})
+	if err != nil {
+		t.Fatalf("counting users failed: %s", err)
+	}
+
+	if total != 3 {
+		t.Errorf("total=%d, got=%d", 3, total)
+	}
+
+	name := "oh"
+	users, err = bus.Query(ctx, userbus.QueryFilter{Name: &name}, byName, pg)
+	if err != nil {
+		t.Fatalf("querying users by name failed: %s", err)
+	}
+
+	if len(users) != 1 || users[0].ID != john.ID {
+		t.Errorf("expected only %s to match the name filter, got %d users", john.Name, len(users))
+	}
+
+	//injection attempt should be treated as a plain value.
+	injection := "' OR 1=1; --"
+	users, err = bus.Query(ctx, userbus.QueryFilter{Name: &injection}, byName, pg)
+	if err != nil {
+		t.Fatalf("querying users with injection payload failed: %s", err)
+	}
+
+	if len(users) != 0 {
+		t.Errorf("len=%d, got=%d", 0, len(users))
+	}
+
+	start := time.Now().Add(time.Hour)
+	users, err = bus.Query(ctx, userbus.QueryFilter{StartCreatedAt: &start}, byName, pg)
+	if err != nil {
+		t.Fatalf("querying users by start date failed: %s", err)
+	}
+
+	if len(users) != 0 {
+		t.Errorf("len=%d, got=%d", 0, len(users))
+	}
+
+	if _, err := bus.Query(ctx, userbus.QueryFilter{}, order.NewBy("unknown", order.ASC), pg); err == nil {
+		t.Error("expected unknown order field to fail")
+	}
+
+	//order.By can be built directly, so the direction must not reach the query unchecked.
+	injected := order.By{Field: userbus.OrderByName, Direction: "ASC; DROP TABLE users"}
+	if _, err := bus.Query(ctx, userbus.QueryFilter{}, injected, pg); err == nil {
+		t.Error("expected unknown order direction to fail")
+	}
+}
+
+func createUser(ctx context.Context, t *testing.T, bus *userbus.UserBus, name string, email string) userbus.User {
+	t.Helper()
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		t.Fatalf("parsing email: %s", err)
+	}
+
+	usr, err := bus.Create(ctx, userbus.NewUser{
+		Name:     name,
+		Email:    *addr,
+		Roles:    []userbus.Role{userbus.RoleUser},
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatalf("creating user failed: %s", err)
+	}
+
+	return usr
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "authenticate_user")
+
+	bus := userbus.New(userdb.NewStore(database.Log, database.DB))
+	usr := createUser(ctx, t, bus, "John", "john@gmail.com")
+
+	authenticated, err := bus.Authenticate(ctx, usr.Email, "password")
+	if err != nil {
+		t.Fatalf("authenticating user failed: %s", err)
+	}
+
+	if authenticated.ID != usr.ID {
+		t.Errorf("id=%s, got=%s", usr.ID, authenticated.ID)
+	}
+
+	if _, err := bus.Authenticate(ctx, usr.Email, "wrong-password"); !errors.Is(err, userbus.ErrAuthenticationFailure) {
+		t.Errorf("err=%v, got=%v", userbus.ErrAuthenticationFailure, err)
+	}
+
+	unknown, err := mail.ParseAddress("unknown@gmail.com")
+	if err != nil {
+		t.Fatalf("parsing email: %s", err)
+	}
+
+	if _, err := bus.Authenticate(ctx, *unknown, "password"); !errors.Is(err, userbus.ErrAuthenticationFailure) {
+		t.Errorf("err=%v, got=%v", userbus.ErrAuthenticationFailure, err)
+	}
+
+	enabled := false
+	if _, err := bus.Update(ctx, usr, userbus.UpdateUser{Enabled: &enabled}); err != nil {
+		t.Fatalf("disabling user failed: %s", err)
+	}
+
+	if _, err := bus.Authenticate(ctx, usr.Email, "password"); !errors.Is(err, userbus.ErrUserDisabled) {
+		t.Errorf("err=%v, got=%v", userbus.ErrUserDisabled, err)
+	}
+}
@@ -0,0 +1,45 @@
+package userdb
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+// applyFilter appends the WHERE clause into the buffer, values are passed as named
+// parameters into data so they never end up inside of the query itself.
+func applyFilter(filter userbus.QueryFilter, data map[string]any, buf *bytes.Buffer) {
+	var wc []string
+
+	if filter.ID != nil {
+		data["id"] = *filter.ID
+		wc = append(wc, "id = :id")
+	}
+
+	if filter.Name != nil {
+		data["name"] = sqldb.Contains(*filter.Name)
+		wc = append(wc, `name ILIKE :name ESCAPE '\'`)
+	}
+
+	if filter.Email != nil {
+		data["email"] = filter.Email.Address
+		wc = append(wc, "email = :email")
+	}
+
+	if filter.StartCreatedAt != nil {
+		data["start_date_created"] = filter.StartCreatedAt.UTC()
+		wc = append(wc, "date_created >= :start_date_created")
+	}
+
+	if filter.EndCreatedAt != nil {
+		data["end_date_created"] = filter.EndCreatedAt.UTC()
+		wc = append(wc, "date_created <= :end_date_created")
+	}
+
+	if len(wc) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+}
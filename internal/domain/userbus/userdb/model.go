@@ -1,21 +1,24 @@
 package userdb
 
 import (
+	"fmt"
+	"net/mail"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/lib/pq"
 )
 
 type postgresUser struct {
-	ID           uuid.UUID `db:"id"`
-	Name         string    `db:"name"`
-	Email        string    `db:"email"`
-	Roles        []string  `db:"roles"`
-	PasswordHash []byte    `db:"password_hash"`
-	Enabled      bool      `db:"enabled"`
-	DateCreated  time.Time `db:"date_created"`
-	DateUpdated  time.Time `db:"date_updated"`
+	ID           uuid.UUID      `db:"id"`
+	Name         string         `db:"name"`
+	Email        string         `db:"email"`
+	Roles        pq.StringArray `db:"roles"`
+	PasswordHash []byte         `db:"password_hash"`
+	Enabled      bool           `db:"enabled"`
+	DateCreated  time.Time      `db:"date_created"`
+	DateUpdated  time.Time      `db:"date_updated"`
 }
 
 func toPostgresUser(usr userbus.User) postgresUser {
@@ -26,7 +29,42 @@ func toPostgresUser(usr userbus.User) postgresUser {
 		Roles:        userbus.EncodeRoles(usr.Roles),
 		PasswordHash: usr.PasswordHash,
 		Enabled:      usr.Enabled,
-		DateCreated:  usr.DateCreated,
-		DateUpdated:  usr.DateUpdated,
+		DateCreated:  usr.DateCreated.UTC(),
+		DateUpdated:  usr.DateUpdated.UTC(),
 	}
 }
+
+func toBusUser(pgUsr postgresUser) (userbus.User, error) {
+	email, err := mail.ParseAddress(pgUsr.Email)
+	if err != nil {
+		return userbus.User{}, fmt.Errorf("parsing email: %w", err)
+	}
+
+	roles, err := userbus.ParseSliceOfRoles(pgUsr.Roles)
+	if err != nil {
+		return userbus.User{}, fmt.Errorf("parsing roles: %w", err)
+	}
+
+	return userbus.User{
+		ID:           pgUsr.ID,
+		Name:         pgUsr.Name,
+		Email:        *email,
+		Roles:        roles,
+		PasswordHash: pgUsr.PasswordHash,
+		Enabled:      pgUsr.Enabled,
+		DateCreated:  pgUsr.DateCreated,
+		DateUpdated:  pgUsr.DateUpdated,
+	}, nil
+}
+
+func toBusUsers(pgUsrs []postgresUser) ([]userbus.User, error) {
+	users := make([]userbus.User, len(pgUsrs))
+	for i, pgUsr := range pgUsrs {
+		usr, err := toBusUser(pgUsr)
+		if err != nil {
+			return nil, err
+		}
+		users[i] = usr
+	}
+	return users, nil
+}
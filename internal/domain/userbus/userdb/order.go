@@ -0,0 +1,38 @@
+package userdb
+
+import (
+	"fmt"
+
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/order"
+)
+
+// orderByFields maps the business order fields into database columns.
+var orderByFields = map[string]string{
+	userbus.OrderByID:      "id",
+	userbus.OrderByName:    "name",
+	userbus.OrderByEmail:   "email",
+	userbus.OrderByRoles:   "roles",
+	userbus.OrderByEnabled: "enabled",
+}
+
+// orderByDirections maps the business order directions into sql keywords.
+var orderByDirections = map[string]string{
+	order.ASC:  "ASC",
+	order.DESC: "DESC",
+}
+
+func orderByClause(orderBy order.By) (string, error) {
+	by, ok := orderByFields[orderBy.Field]
+	if !ok {
+		return "", fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	//the direction is written into the query as well, so it is checked like the field.
+	direction, ok := orderByDirections[orderBy.Direction]
+	if !ok {
+		return "", fmt.Errorf("direction %q does not exist", orderBy.Direction)
+	}
+
+	return " ORDER BY " + by + " " + direction, nil
+}
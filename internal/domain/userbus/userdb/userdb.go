@@ -1,9 +1,11 @@
 package userdb
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/mail"
 
 	"github.com/google/uuid"
@@ -15,36 +17,28 @@ import (
 )
 
 type Store struct {
-	db *sqlx.DB
+	log *slog.Logger
+	db  sqlx.ExtContext
 }
 
-// Delete implements userbus.storer.
-func (s *Store) Delete(ctx context.Context, usr userbus.User) error {
-	panic("unimplemented")
-}
-
-// Query implements userbus.storer.
-func (s *Store) Query(ctx context.Context, filter userbus.QueryFilter, orderBy order.By, page page.Page) ([]userbus.User, error) {
-	panic("unimplemented")
-}
-
-// QueryByEmail implements userbus.storer.
-func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (userbus.User, error) {
-	panic("unimplemented")
-}
-
-// QueryByID implements userbus.storer.
-func (s *Store) QueryByID(ctx context.Context, userID uuid.UUID) (userbus.User, error) {
-	panic("unimplemented")
+func NewStore(log *slog.Logger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
 }
 
-// Update implements userbus.storer.
-func (s *Store) Update(ctx context.Context, usr userbus.User) error {
-	panic("unimplemented")
-}
+// NewWithTx returns a copy of the store that runs its queries within the transaction.
+func (s *Store) NewWithTx(tx sqldb.CommitRollbacker) (userbus.Storer, error) {
+	ec, err := sqldb.GetExtContext(tx)
+	if err != nil {
+		return nil, err
+	}
 
-func NewStore(db *sqlx.DB) *Store {
-	return &Store{db: db}
+	return &Store{
+		log: s.log,
+		db:  ec,
+	}, nil
 }
 
 func (s *Store) Create(ctx context.Context, usr userbus.User) error {
@@ -52,7 +46,7 @@ func (s *Store) Create(ctx context.Context, usr userbus.User) error {
 	INSERT INTO users(id,name,email,password_hash,roles,enabled,date_created,date_updated)
 	VALUES (:id,:name,:email,:password_hash,:roles,:enabled,:date_created,:date_updated);
 	`
-	if err := sqldb.NamedExecContext(ctx, s.db, q, toPostgresUser(usr)); err != nil {
+	if err := sqldb.NamedExecContext(ctx, s.log, s.db, q, toPostgresUser(usr)); err != nil {
 		if errors.Is(err, sqldb.ErrDuplicatedEntry) {
 			return userbus.ErrDuplicatedEmail
 		} else {
@@ -61,3 +55,119 @@ func (s *Store) Create(ctx context.Context, usr userbus.User) error {
 	}
 	return nil
 }
+
+func (s *Store) Update(ctx context.Context, usr userbus.User) error {
+	const q = `
+	UPDATE users SET
+		name = :name,
+		email = :email,
+		password_hash = :password_hash,
+		roles = :roles,
+		enabled = :enabled,
+		date_updated = :date_updated
+	WHERE id = :id;
+	`
+	if err := sqldb.NamedExecContext(ctx, s.log, s.db, q, toPostgresUser(usr)); err != nil {
+		if errors.Is(err, sqldb.ErrDuplicatedEntry) {
+			return userbus.ErrDuplicatedEmail
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, usr userbus.User) error {
+	const q = `DELETE FROM users WHERE id = :id;`
+
+	if err := sqldb.NamedExecContext(ctx, s.log, s.db, q, toPostgresUser(usr)); err != nil {
+		//orders keep their user, so the history of a customer can not be deleted with it.
+		if errors.Is(err, sqldb.ErrForeignKeyViolation) {
+			return userbus.ErrUserHasOrders
+		}
+		return fmt.Errorf("namedExecContext: %w", err)
+	}
+	return nil
+}
+
+// QueryByID returns the user with the given id, sqldb.ErrNotFound is returned in case of not found.
+func (s *Store) QueryByID(ctx context.Context, userID uuid.UUID) (userbus.User, error) {
+	const q = `
+	SELECT id,name,email,password_hash,roles,enabled,date_created,date_updated
+	FROM users WHERE id = :id;
+	`
+	data := map[string]any{
+		"id": userID,
+	}
+
+	var pgUsr postgresUser
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, q, data, &pgUsr); err != nil {
+		return userbus.User{}, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	return toBusUser(pgUsr)
+}
+
+// QueryByEmail returns the user with the given email, sqldb.ErrNotFound is returned in case of not found.
+func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (userbus.User, error) {
+	const q = `
+	SELECT id,name,email,password_hash,roles,enabled,date_created,date_updated
+	FROM users WHERE email = :email;
+	`
+	data := map[string]any{
+		"email": email.Address,
+	}
+
+	var pgUsr postgresUser
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, q, data, &pgUsr); err != nil {
+		return userbus.User{}, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	return toBusUser(pgUsr)
+}
+
+func (s *Store) Query(ctx context.Context, filter userbus.QueryFilter, orderBy order.By, page page.Page) ([]userbus.User, error) {
+	data := map[string]any{
+		"offset":        page.Offset(),
+		"rows_per_page": page.RowsPerPage(),
+	}
+
+	const q = `
+	SELECT id,name,email,password_hash,roles,enabled,date_created,date_updated
+	FROM users`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	orderClause, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, fmt.Errorf("orderByClause: %w", err)
+	}
+
+	buf.WriteString(orderClause)
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY;")
+
+	var pgUsrs []postgresUser
+	if err := sqldb.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &pgUsrs); err != nil {
+		return nil, fmt.Errorf("namedQuerySlice: %w", err)
+	}
+
+	return toBusUsers(pgUsrs)
+}
+
+func (s *Store) Count(ctx context.Context, filter userbus.QueryFilter) (int, error) {
+	data := map[string]any{}
+
+	const q = `SELECT COUNT(1) AS count FROM users`
+
+	buf := bytes.NewBufferString(q)
+	applyFilter(filter, data, buf)
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := sqldb.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+		return 0, fmt.Errorf("namedQueryStruct: %w", err)
+	}
+
+	return count.Count, nil
+}
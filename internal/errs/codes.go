@@ -0,0 +1,110 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Application error codes that are not bound to a domain error. Codes are part of the API
+// contract, clients branch on them so a released code never changes its meaning.
+const (
+	CodeValidation = "VALIDATION_FAILED"
+	CodeInternal   = "INTERNAL"
+)
+
+// Spec describes how a registered sentinel error is answered.
+type Spec struct {
+	Code    string //stable application error code.
+	Status  int    //HTTP status of the response.
+	Message string //what clients see, the error itself may wrap details that must not leak.
+}
+
+// registry maps domain sentinel errors to their specs.
+var registry = struct {
+	mu      sync.RWMutex
+	entries []entry
+}{}
+
+type entry struct {
+	sentinel error
+	spec     Spec
+}
+
+// Register declares how the sentinel error is answered, every error that wraps the sentinel
+// resolves to the spec. Business packages register their own sentinels from init, next to where
+// they are declared. Registering the same pair twice is a no-op, but a code can only belong to one
+// sentinel and a sentinel to one spec.
+func Register(sentinel error, spec Spec) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, e := range registry.entries {
+		switch {
+		case e.sentinel == sentinel && e.spec == spec:
+			return
+		case e.spec.Code == spec.Code:
+			panic(fmt.Sprintf("errs: code %s is already registered for %q", spec.Code, e.sentinel))
+		case e.sentinel == sentinel:
+			panic(fmt.Sprintf("errs: %q is already registered with code %s", sentinel, e.spec.Code))
+		}
+	}
+
+	registry.entries = append(registry.entries, entry{sentinel: sentinel, spec: spec})
+}
+
+// Lookup returns the spec of the first registered sentinel err wraps.
+func Lookup(err error) (Spec, bool) {
+	if err == nil {
+		return Spec{}, false
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	for _, e := range registry.entries {
+		if errors.Is(err, e.sentinel) {
+			return e.spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// Resolve turns err into an Error when it wraps a registered sentinel, the response then carries
+// the status, code and message of the spec while err stays reachable through Unwrap.
+func Resolve(err error) (*Error, bool) {
+	spec, ok := Lookup(err)
+	if !ok {
+		return nil, false
+	}
+
+	return &Error{
+		Code:    spec.Status,
+		AppCode: spec.Code,
+		Message: spec.Message,
+		err:     err,
+	}, true
+}
+
+// specOf returns the spec registered with the code.
+func specOf(code string) (Spec, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	for _, e := range registry.entries {
+		if e.spec.Code == code {
+			return e.spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// TypeURI returns the problem type of the code, a URN that stays the same across deployments.
+// Errors without a code use about:blank as RFC 9457 suggests.
+func TypeURI(code string) string {
+	if code == "" {
+		return "about:blank"
+	}
+	return "urn:sales:problem:" + strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
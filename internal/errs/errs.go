@@ -1,16 +1,22 @@
 package errs
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"runtime"
+	"slices"
+	"strings"
 )
 
 type Error struct {
 	Code     int               `json:"code"`
+	AppCode  string            `json:"appCode,omitempty"` //stable application error code, see Register.
 	Message  string            `json:"message"`
 	FuncName string            `json:"-"`
 	Filename string            `json:"-"`
 	Fields   map[string]string `json:"fields,omitempty"`
+	err      error
 }
 
 func New(code int, err error) *Error {
@@ -23,6 +29,7 @@ func New(code int, err error) *Error {
 		Message:  err.Error(),
 		FuncName: funcName,
 		Filename: file,
+		err:      err,
 	}
 }
 
@@ -37,6 +44,7 @@ func Newf(code int, format string, args ...any) *Error {
 		Message:  msg.Error(),
 		FuncName: funcName,
 		Filename: file,
+		err:      msg,
 	}
 }
 
@@ -47,6 +55,7 @@ func NewValidation(code int, fields map[string]string, msg string) *Error {
 
 	return &Error{
 		Code:     code,
+		AppCode:  CodeValidation,
 		Message:  msg,
 		Filename: file,
 		FuncName: funcName,
@@ -57,3 +66,85 @@ func NewValidation(code int, fields map[string]string, msg string) *Error {
 func (e *Error) Error() string {
 	return e.Message
 }
+
+// Unwrap returns the error the Error was created from, so domain sentinels stay reachable
+// through errors.Is.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Problem returns the error in the RFC 9457 problem details format. Instance is the path of the
+// request that failed and traceID lets clients point at the logs of it.
+func (e *Error) Problem(instance string, traceID string) Problem {
+	appCode := e.AppCode
+	if appCode == "" {
+		if spec, ok := Lookup(e); ok {
+			appCode = spec.Code
+		}
+	}
+
+	//title belongs to the type and must not change between occurrences.
+	title := http.StatusText(e.Code)
+	if spec, ok := specOf(appCode); ok {
+		title = spec.Message
+	}
+
+	return Problem{
+		Type:     TypeURI(appCode),
+		Title:    title,
+		Status:   e.Code,
+		Detail:   e.Message,
+		Instance: instance,
+		TraceID:  traceID,
+		Code:     appCode,
+		Fields:   e.Fields,
+	}
+}
+
+// Problem represents an RFC 9457 problem details document, TraceID, Code and Fields are
+// extension members.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	TraceID  string            `json:"traceId,omitempty"`
+	Code     string            `json:"code,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// Encode sends the problem as application/problem+json, it implements the web.Encoder interface.
+func (p Problem) Encode() ([]byte, string, error) {
+	bs, err := json.Marshal(p)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshal: %w", err)
+	}
+	return bs, "application/problem+json", nil
+}
+
+// FieldErrors represents the failed fields of a validation, keyed by field name. Validate methods
+// of request models return it so the failures reach the client as a validation error.
+type FieldErrors map[string]string
+
+// Err returns nil when no field failed, otherwise the field errors themselves.
+func (fe FieldErrors) Err() error {
+	if len(fe) == 0 {
+		return nil
+	}
+	return fe
+}
+
+func (fe FieldErrors) Error() string {
+	fields := make([]string, 0, len(fe))
+	for field := range fe {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	msgs := make([]string, len(fields))
+	for i, field := range fields {
+		msgs[i] = field + ": " + fe[field]
+	}
+	return strings.Join(msgs, ", ")
+}
@@ -0,0 +1,172 @@
+package errs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+func TestRegister(t *testing.T) {
+	errGopher := errors.New("gopher not found")
+	spec := errs.Spec{Code: "TEST_GOPHER_NOT_FOUND", Status: http.StatusNotFound, Message: "gopher not found"}
+	errs.Register(errGopher, spec)
+
+	//registering the same pair again is a no-op.
+	errs.Register(errGopher, spec)
+
+	tests := map[string]struct {
+		sentinel error
+		spec     errs.Spec
+	}{
+		"code_of_another_sentinel": {
+			sentinel: errors.New("other"),
+			spec:     errs.Spec{Code: spec.Code, Status: http.StatusNotFound},
+		},
+		"sentinel_with_another_code": {
+			sentinel: errGopher,
+			spec:     errs.Spec{Code: "TEST_GOPHER_MISSING", Status: http.StatusNotFound},
+		},
+		"sentinel_with_another_status": {
+			sentinel: errGopher,
+			spec:     errs.Spec{Code: spec.Code, Status: http.StatusGone, Message: spec.Message},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected the registration to panic")
+				}
+			}()
+
+			errs.Register(test.sentinel, test.spec)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	errConflict := errors.New("gopher is busy")
+	spec := errs.Spec{Code: "TEST_GOPHER_BUSY", Status: http.StatusConflict, Message: "gopher is busy"}
+	errs.Register(errConflict, spec)
+
+	wrapped := fmt.Errorf("update gopher: %w", errConflict)
+
+	if got, ok := errs.Lookup(wrapped); !ok || got != spec {
+		t.Errorf("spec=%+v, got %+v", spec, got)
+	}
+
+	if _, ok := errs.Lookup(errors.New("gopher is busy")); ok {
+		t.Error("expected an error that only shares the text not to be found")
+	}
+
+	if _, ok := errs.Lookup(nil); ok {
+		t.Error("expected nil not to be found")
+	}
+
+	appErr, ok := errs.Resolve(wrapped)
+	if !ok {
+		t.Fatal("expected the wrapped sentinel to resolve")
+	}
+
+	if appErr.Code != spec.Status || appErr.AppCode != spec.Code || appErr.Message != spec.Message {
+		t.Errorf("expected the error to carry the spec, got %+v", appErr)
+	}
+
+	if !errors.Is(appErr, errConflict) {
+		t.Error("expected the sentinel to stay reachable through the resolved error")
+	}
+
+	if _, ok := errs.Resolve(errors.New("unknown")); ok {
+		t.Error("expected an unregistered error not to resolve")
+	}
+}
+
+func TestProblem(t *testing.T) {
+	errLocked := errors.New("gopher is locked")
+	errs.Register(errLocked, errs.Spec{Code: "TEST_GOPHER_LOCKED", Status: http.StatusLocked, Message: "gopher is locked"})
+
+	tests := map[string]struct {
+		err      *errs.Error
+		expected errs.Problem
+	}{
+		"registered": {
+			err: errs.New(http.StatusLocked, fmt.Errorf("%w: by john", errLocked)),
+			expected: errs.Problem{
+				Type:     "urn:sales:problem:test-gopher-locked",
+				Title:    "gopher is locked",
+				Status:   http.StatusLocked,
+				Detail:   "gopher is locked: by john",
+				Instance: "/v1/gophers",
+				TraceID:  "trace-id",
+				Code:     "TEST_GOPHER_LOCKED",
+			},
+		},
+		"unregistered": {
+			err: errs.Newf(http.StatusBadRequest, "invalid gopher"),
+			expected: errs.Problem{
+				Type:     "about:blank",
+				Title:    http.StatusText(http.StatusBadRequest),
+				Status:   http.StatusBadRequest,
+				Detail:   "invalid gopher",
+				Instance: "/v1/gophers",
+				TraceID:  "trace-id",
+			},
+		},
+		"validation": {
+			err: errs.NewValidation(http.StatusBadRequest, map[string]string{"name": "required"}, "invalid gopher"),
+			expected: errs.Problem{
+				Type:     "urn:sales:problem:validation-failed",
+				Title:    http.StatusText(http.StatusBadRequest),
+				Status:   http.StatusBadRequest,
+				Detail:   "invalid gopher",
+				Instance: "/v1/gophers",
+				TraceID:  "trace-id",
+				Code:     errs.CodeValidation,
+				Fields:   map[string]string{"name": "required"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := test.err.Problem("/v1/gophers", "trace-id")
+
+			if fmt.Sprint(got) != fmt.Sprint(test.expected) {
+				t.Errorf("problem=%+v, got %+v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	p := errs.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusNotFound),
+		Status: http.StatusNotFound,
+	}
+
+	bs, contentType, err := p.Encode()
+	if err != nil {
+		t.Fatalf("encoding problem: %s", err)
+	}
+
+	if contentType != "application/problem+json" {
+		t.Errorf("contentType=%s, got %s", "application/problem+json", contentType)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(bs, &got); err != nil {
+		t.Fatalf("decoding problem: %s", err)
+	}
+
+	//empty extension members are left out.
+	expected := map[string]any{"type": "about:blank", "title": "Not Found", "status": float64(http.StatusNotFound)}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("problem=%v, got %v", expected, got)
+	}
+}
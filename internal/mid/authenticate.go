@@ -2,12 +2,14 @@ package mid
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hamidoujand/sales/internal/auth"
 	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/tracing"
 	"github.com/hamidoujand/sales/internal/web"
 )
 
@@ -18,9 +20,16 @@ func Authenticate(a *auth.Auth) web.Middleware {
 			ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 			defer cancel()
 
-			claims, err := a.Authenticate(ctx, token)
+			//the span ends before the next handler runs, so it only covers the authentication.
+			spanCtx, span := tracing.AddSpan(ctx, "mid.authenticate")
+			claims, err := a.Authenticate(spanCtx, token)
+			tracing.RecordError(span, err)
+			span.End()
+
+			//rejected tokens wrap a registered sentinel, a failing revocation list is answered as
+			//a server error by mid.Error.
 			if err != nil {
-				return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
+				return fmt.Errorf("authenticate: %w", err)
 			}
 
 			//set claims into ctx
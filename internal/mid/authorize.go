@@ -2,14 +2,20 @@ package mid
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hamidoujand/sales/internal/auth"
 	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/tracing"
 	"github.com/hamidoujand/sales/internal/web"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// Authorize checks the claims of the authenticated user against the given rule. For routes that
+// operate on a specific user, the "user_id" path param is used as the owner of the resource.
 func Authorize(a *auth.Auth, rule string) web.Middleware {
 	return func(next web.HandlerFunc) web.HandlerFunc {
 		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -25,7 +31,17 @@ func Authorize(a *auth.Auth, rule string) web.Middleware {
 			if err != nil {
 				return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
 			}
-			if err := a.Authorize(ctx, claims, userId.String(), rule); err != nil {
+			ownerId := userId.String()
+			if id := r.PathValue("user_id"); id != "" {
+				ownerId = id
+			}
+
+			spanCtx, span := tracing.AddSpan(ctx, "mid.authorize", attribute.String("rule", rule))
+			err = a.Authorize(spanCtx, claims, ownerId, rule)
+			tracing.RecordError(span, err)
+			span.End()
+
+			if err != nil {
 				return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
 			}
 
@@ -33,3 +49,52 @@ func Authorize(a *auth.Auth, rule string) web.Middleware {
 		}
 	}
 }
+
+// OwnerFunc returns the id of the user that owns the resource with the given id.
+type OwnerFunc func(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
+
+// AuthorizeOwner looks up the owner of the resource referenced by the param path param and checks
+// the claims against the admin-or-owner rule. Errors of owner are returned as they are, so a
+// registered not found error is answered with its own status.
+func AuthorizeOwner(a *auth.Auth, param string, owner OwnerFunc) web.Middleware {
+	return func(next web.HandlerFunc) web.HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			id, err := uuid.Parse(r.PathValue(param))
+			if err != nil {
+				return errs.Newf(http.StatusBadRequest, "invalid %s: %q", param, r.PathValue(param))
+			}
+
+			claims, err := auth.GetClaims(ctx)
+			if err != nil {
+				return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+			defer cancel()
+
+			spanCtx, span := tracing.AddSpan(ctx, "mid.authorizeOwner", attribute.String(param, id.String()))
+			err = authorizeOwner(spanCtx, a, owner, claims, id)
+			tracing.RecordError(span, err)
+			span.End()
+
+			if err != nil {
+				return err
+			}
+
+			return next(ctx, w, r)
+		}
+	}
+}
+
+func authorizeOwner(ctx context.Context, a *auth.Auth, owner OwnerFunc, claims auth.Claims, id uuid.UUID) error {
+	ownerID, err := owner(ctx, id)
+	if err != nil {
+		return fmt.Errorf("query owner[%s]: %w", id, err)
+	}
+
+	if err := a.Authorize(ctx, claims, ownerID.String(), auth.RuleAdminOrOwner); err != nil {
+		return errs.New(http.StatusUnauthorized, auth.ErrUnauthenticated)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package mid
+
+import (
+	"context"
+
+	"github.com/hamidoujand/sales/internal/sqldb"
+)
+
+type ctxKey int
+
+const trKey ctxKey = 1
+
+func setTran(ctx context.Context, tx sqldb.CommitRollbacker) context.Context {
+	return context.WithValue(ctx, trKey, tx)
+}
+
+// GetTran returns the transaction started by BeginCommitRollback, ok is false when the request
+// does not run within one.
+func GetTran(ctx context.Context) (tx sqldb.CommitRollbacker, ok bool) {
+	tx, ok = ctx.Value(trKey).(sqldb.CommitRollbacker)
+	return tx, ok
+}
@@ -11,6 +11,10 @@ import (
 	"github.com/hamidoujand/sales/internal/web"
 )
 
+// problemJSON is the media type clients list in their Accept header to receive errors as RFC 9457
+// problem details instead of the errs.Error document.
+const problemJSON = "application/problem+json"
+
 func Error(log *slog.Logger) web.Middleware {
 	return func(next web.HandlerFunc) web.HandlerFunc {
 		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -22,26 +26,50 @@ func Error(log *slog.Logger) web.Middleware {
 
 			//we have an err then
 
+			//handlers that picked a status themselves win, registered sentinel errors are
+			//answered with their spec, anything else is untrusted.
 			var trustedErr *errs.Error
 			if !errors.As(err, &trustedErr) {
-				//untrusted error
-				trustedErr = errs.Newf(http.StatusInternalServerError, "internal server error")
+				var ok bool
+				if trustedErr, ok = errs.Resolve(err); !ok {
+					trustedErr = errs.New(http.StatusInternalServerError, err)
+				}
+			}
+
+			if trustedErr.AppCode == "" {
+				if spec, ok := errs.Lookup(trustedErr); ok {
+					trustedErr.AppCode = spec.Code
+				}
 			}
 
-			//trusted error
-			log.Error("request failed",
+			traceID := web.GetTraceID(ctx)
+			attrs := []any{
+				"traceId", traceID,
 				"code", trustedErr.Code,
+				"appCode", trustedErr.AppCode,
 				"funcName", filepath.Base(trustedErr.FuncName),
 				"filename", filepath.Base(trustedErr.Filename),
-				"msg", trustedErr.Message,
-			)
+				"msg", err.Error(),
+			}
+
+			//server errors are ours to fix and no data should leak from them, the trace id is
+			//what clients report back. client errors are part of normal operation.
+			if trustedErr.Code >= http.StatusInternalServerError {
+				log.Error("request failed", attrs...)
+				trustedErr.Message = http.StatusText(trustedErr.Code)
+				if trustedErr.AppCode == "" {
+					trustedErr.AppCode = errs.CodeInternal
+				}
+			} else {
+				log.Info("request failed", attrs...)
+			}
 
-			//internal server errors, not data should leak
-			if trustedErr.Code == http.StatusInternalServerError {
-				trustedErr.Message = http.StatusText(http.StatusInternalServerError)
+			var data any = trustedErr
+			if web.Accepts(r, problemJSON) {
+				data = trustedErr.Problem(r.URL.Path, traceID)
 			}
 
-			if err := web.Respond(ctx, w, trustedErr.Code, trustedErr); err != nil {
+			if err := web.Respond(ctx, w, trustedErr.Code, data); err != nil {
 				//log the error
 				log.Error("responding error to client", "msg", err)
 			}
@@ -2,11 +2,18 @@ package mid_test
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
@@ -15,6 +22,7 @@ import (
 	"github.com/hamidoujand/sales/internal/auth"
 	"github.com/hamidoujand/sales/internal/errs"
 	"github.com/hamidoujand/sales/internal/mid"
+	"github.com/hamidoujand/sales/internal/sqldb"
 	"github.com/hamidoujand/sales/internal/web"
 )
 
@@ -25,7 +33,14 @@ const (
 
 func TestAuthenticate(t *testing.T) {
 	ks := newKeystroe(t)
-	authClient := auth.New(ks, jwt.SigningMethodRS256, "auth-service", ks.activeKid)
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: ks,
+		Issuer:    "auth-service",
+		ActiveKID: ks.activeKid,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
 
 	tests := map[string]struct {
 		userId           string
@@ -108,9 +123,13 @@ func TestAuthenticate(t *testing.T) {
 					t.Fatal("expected to authenticate to fail, but passed")
 				}
 
+				//rejected tokens are left to the registry, mid.Error answers them.
 				var appErr *errs.Error
 				if !errors.As(err, &appErr) {
-					t.Fatalf("expected the returned error to be of type errs.Error, got %T", err)
+					var ok bool
+					if appErr, ok = errs.Resolve(err); !ok {
+						t.Fatalf("expected the returned error to resolve to a status, got %v", err)
+					}
 				}
 
 				if test.errStatus != appErr.Code {
@@ -121,9 +140,98 @@ func TestAuthenticate(t *testing.T) {
 	}
 }
 
+type revocationList struct {
+	revoked bool
+	err     error
+}
+
+func (rl revocationList) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return rl.revoked, rl.err
+}
+
+func TestAuthenticateRevocation(t *testing.T) {
+	tests := map[string]struct {
+		revocations  revocationList
+		expectStatus int
+		expectCode   string
+	}{
+		"revoked_token": {
+			revocations:  revocationList{revoked: true},
+			expectStatus: http.StatusUnauthorized,
+			expectCode:   "TOKEN_REVOKED",
+		},
+		"revocation_list_down": {
+			revocations:  revocationList{err: sql.ErrConnDone},
+			expectStatus: http.StatusInternalServerError,
+			expectCode:   errs.CodeInternal,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ks := newKeystroe(t)
+			authClient, err := auth.New(auth.Config{
+				KeyLookup:   ks,
+				Issuer:      "auth-service",
+				ActiveKID:   ks.activeKid,
+				Revocations: test.revocations,
+			})
+			if err != nil {
+				t.Fatalf("failed to create auth: %s", err)
+			}
+
+			token, err := authClient.GenerateToken(auth.Claims{
+				Roles: []string{roleUser},
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   uuid.NewString(),
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+					IssuedAt:  jwt.NewNumericDate(time.Now()),
+				},
+			})
+			if err != nil {
+				t.Fatalf("failed to generate token: %s", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/auth", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+
+			h := web.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				t.Fatal("expected the request to be refused")
+				return nil
+			})
+
+			withErr := mid.Error(slog.New(slog.NewTextHandler(io.Discard, nil)))(mid.Authenticate(authClient)(h))
+			if err := withErr(r.Context(), w, r); err != nil {
+				t.Fatalf("expected the error to be handled, got %s", err)
+			}
+
+			if w.Code != test.expectStatus {
+				t.Errorf("status=%d, got %d", test.expectStatus, w.Code)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body: %s", err)
+			}
+
+			if body["appCode"] != test.expectCode {
+				t.Errorf("appCode=%s, got %v", test.expectCode, body["appCode"])
+			}
+		})
+	}
+}
+
 func TestAuthorize(t *testing.T) {
 	ks := newKeystroe(t)
-	authClient := auth.New(ks, jwt.SigningMethodRS256, "auth-service", ks.activeKid)
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: ks,
+		Issuer:    "auth-service",
+		ActiveKID: ks.activeKid,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
 	tests := map[string]struct {
 		userId                 uuid.UUID
 		roles                  []string
@@ -229,14 +337,320 @@ func TestAuthorize(t *testing.T) {
 
 }
 
+func TestAuthorizeOwner(t *testing.T) {
+	ks := newKeystroe(t)
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: ks,
+		Issuer:    "auth-service",
+		ActiveKID: ks.activeKid,
+	})
+	if err != nil {
+		t.Fatalf("failed to create auth: %s", err)
+	}
+
+	errNotFound := errors.New("resource not found")
+	ownerID := uuid.New()
+	resourceID := uuid.New()
+
+	owner := func(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+		if id != resourceID {
+			return uuid.Nil, errNotFound
+		}
+		return ownerID, nil
+	}
+
+	tests := map[string]struct {
+		userID    uuid.UUID
+		roles     []string
+		id        string
+		errStatus int   //expected status of an errs.Error, 0 when the request passes.
+		err       error //expected error in the chain, when the status is left to mid.Error.
+	}{
+		"owner_passes":          {userID: ownerID, roles: []string{roleUser}, id: resourceID.String()},
+		"admin_passes":          {userID: uuid.New(), roles: []string{roleAdmin}, id: resourceID.String()},
+		"other_user_is_refused": {userID: uuid.New(), roles: []string{roleUser}, id: resourceID.String(), errStatus: http.StatusUnauthorized},
+		"invalid_id":            {userID: ownerID, roles: []string{roleUser}, id: "not-a-uuid", errStatus: http.StatusBadRequest},
+		"lookup_error_is_kept":  {userID: ownerID, roles: []string{roleUser}, id: uuid.NewString(), err: errNotFound},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := auth.Claims{
+				Roles: test.roles,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    "auth-service",
+					Subject:   test.userID.String(),
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+					IssuedAt:  jwt.NewNumericDate(time.Now()),
+				},
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/widgets/"+test.id, nil)
+			r.SetPathValue("widget_id", test.id)
+			ctx := auth.SetClaims(auth.SetUserId(r.Context(), test.userID), c)
+
+			var called bool
+			h := mid.AuthorizeOwner(authClient, "widget_id", owner)(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				called = true
+				return nil
+			})
+
+			err := h(ctx, httptest.NewRecorder(), r)
+
+			switch {
+			case test.err != nil:
+				if !errors.Is(err, test.err) {
+					t.Fatalf("err=%v, got %v", test.err, err)
+				}
+			case test.errStatus != 0:
+				var appErr *errs.Error
+				if !errors.As(err, &appErr) {
+					t.Fatalf("expected error type to be errs.Error, got %T", err)
+				}
+
+				if appErr.Code != test.errStatus {
+					t.Errorf("status=%d, got %d", test.errStatus, appErr.Code)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("failed to authorize the request: %s", err)
+				}
+			}
+
+			if called != (test.err == nil && test.errStatus == 0) {
+				t.Errorf("expected the handler to be called only when the request passes, called=%t", called)
+			}
+		})
+	}
+}
+
+func TestBeginCommitRollback(t *testing.T) {
+	errHandler := errors.New("handler failed")
+
+	tests := map[string]struct {
+		method       string
+		handlerErr   error
+		commitErr    error
+		expectTx     bool
+		expectCommit bool
+		expectStatus int
+		expectErr    bool
+	}{
+		"get_skips_tx": {
+			method:       http.MethodGet,
+			expectStatus: http.StatusOK,
+		},
+		"post_commits": {
+			method:       http.MethodPost,
+			expectTx:     true,
+			expectCommit: true,
+			expectStatus: http.StatusCreated,
+		},
+		"handler_error_rolls_back": {
+			method:     http.MethodPut,
+			handlerErr: errHandler,
+			expectTx:   true,
+			expectErr:  true,
+		},
+		"commit_error_holds_response": {
+			method:    http.MethodDelete,
+			commitErr: errors.New("connection lost"),
+			expectTx:  true,
+			expectErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tx := &fakeTx{commitErr: test.commitErr}
+			r := httptest.NewRequest(test.method, "/v1/orders", nil)
+			w := httptest.NewRecorder()
+
+			h := web.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				_, ok := mid.GetTran(ctx)
+				if ok != test.expectTx {
+					t.Errorf("inTx=%t, got %t", test.expectTx, ok)
+				}
+
+				if test.handlerErr != nil {
+					return test.handlerErr
+				}
+
+				status := http.StatusOK
+				if test.method == http.MethodPost {
+					status = http.StatusCreated
+				}
+				return web.Respond(ctx, w, status, map[string]string{"status": "ok"})
+			})
+
+			withTx := mid.BeginCommitRollback(slog.New(slog.NewTextHandler(io.Discard, nil)), fakeBeginner{tx: tx})(h)
+			err := withTx(r.Context(), w, r)
+
+			if test.expectErr != (err != nil) {
+				t.Fatalf("expectErr=%t, got %v", test.expectErr, err)
+			}
+
+			if test.expectCommit != tx.committed {
+				t.Errorf("committed=%t, got %t", test.expectCommit, tx.committed)
+			}
+
+			if test.expectTx && !test.expectCommit && !tx.rolledBack {
+				t.Error("expected the transaction to be rolled back")
+			}
+
+			//nothing reaches the client before the commit succeeds.
+			if test.expectErr {
+				if w.Body.Len() != 0 {
+					t.Errorf("expected no response body, got %q", w.Body.String())
+				}
+				return
+			}
+
+			if w.Code != test.expectStatus {
+				t.Errorf("status=%d, got %d", test.expectStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	errWidgetNotFound := errors.New("widget not found")
+	errs.Register(errWidgetNotFound, errs.Spec{Code: "WIDGET_NOT_FOUND", Status: http.StatusNotFound, Message: "no such widget"})
+
+	tests := map[string]struct {
+		accept       string
+		handlerErr   error
+		expectStatus int
+		expectType   string
+		expectBody   map[string]any
+	}{
+		"legacy_document": {
+			handlerErr:   errs.New(http.StatusNotFound, errWidgetNotFound),
+			expectStatus: http.StatusNotFound,
+			expectType:   "application/json",
+			expectBody:   map[string]any{"code": 404.0, "appCode": "WIDGET_NOT_FOUND", "message": "widget not found"},
+		},
+		"problem_with_registered_code": {
+			accept:       "application/problem+json",
+			handlerErr:   errs.Newf(http.StatusNotFound, "%w: id 42", errWidgetNotFound),
+			expectStatus: http.StatusNotFound,
+			expectType:   "application/problem+json",
+			expectBody: map[string]any{
+				"type":     "urn:sales:problem:widget-not-found",
+				"title":    "no such widget",
+				"status":   404.0,
+				"detail":   "widget not found: id 42",
+				"instance": "/v1/widgets/42",
+				"code":     "WIDGET_NOT_FOUND",
+			},
+		},
+		"registered_sentinel_is_resolved": {
+			handlerErr:   fmt.Errorf("query widget[42]: %w: %w", errWidgetNotFound, sql.ErrNoRows),
+			expectStatus: http.StatusNotFound,
+			expectType:   "application/json",
+			expectBody:   map[string]any{"code": 404.0, "appCode": "WIDGET_NOT_FOUND", "message": "no such widget"},
+		},
+		"unregistered_wrapped_error_is_internal": {
+			handlerErr:   fmt.Errorf("query widget[42]: %w", sql.ErrConnDone),
+			expectStatus: http.StatusInternalServerError,
+			expectType:   "application/json",
+			expectBody:   map[string]any{"code": 500.0, "appCode": "INTERNAL", "message": "Internal Server Error"},
+		},
+		"problem_with_fields": {
+			accept:       "application/problem+json, application/json;q=0.5",
+			handlerErr:   errs.NewValidation(http.StatusBadRequest, map[string]string{"name": "name is required"}, "validation failed"),
+			expectStatus: http.StatusBadRequest,
+			expectType:   "application/problem+json",
+			expectBody: map[string]any{
+				"type":     "urn:sales:problem:validation-failed",
+				"title":    "Bad Request",
+				"status":   400.0,
+				"detail":   "validation failed",
+				"instance": "/v1/widgets/42",
+				"code":     "VALIDATION_FAILED",
+				"fields":   map[string]any{"name": "name is required"},
+			},
+		},
+		"wildcard_is_not_opt_in": {
+			accept:       "*/*",
+			handlerErr:   errs.New(http.StatusNotFound, errWidgetNotFound),
+			expectStatus: http.StatusNotFound,
+			expectType:   "application/json",
+		},
+		"untrusted_error_is_hidden": {
+			accept:       "application/problem+json",
+			handlerErr:   errors.New("connection refused by 10.0.0.7"),
+			expectStatus: http.StatusInternalServerError,
+			expectType:   "application/problem+json",
+			expectBody: map[string]any{
+				"type":     "urn:sales:problem:internal",
+				"title":    "Internal Server Error",
+				"status":   500.0,
+				"detail":   "Internal Server Error",
+				"instance": "/v1/widgets/42",
+				"code":     "INTERNAL",
+			},
+		},
+		"other_server_errors_are_hidden": {
+			handlerErr:   errs.Newf(http.StatusServiceUnavailable, "pool exhausted"),
+			expectStatus: http.StatusServiceUnavailable,
+			expectType:   "application/json",
+			expectBody:   map[string]any{"code": 503.0, "appCode": "INTERNAL", "message": "Service Unavailable"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/widgets/42", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+
+			h := web.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return test.handlerErr
+			})
+
+			withErr := mid.Error(slog.New(slog.NewTextHandler(io.Discard, nil)))(h)
+			if err := withErr(r.Context(), w, r); err != nil {
+				t.Fatalf("expected the error to be handled, got %s", err)
+			}
+
+			if w.Code != test.expectStatus {
+				t.Errorf("status=%d, got %d", test.expectStatus, w.Code)
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != test.expectType {
+				t.Errorf("contentType=%q, got %q", test.expectType, ct)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body: %s", err)
+			}
+
+			if test.expectType == "application/problem+json" {
+				if traceID, _ := body["traceId"].(string); traceID == "" {
+					t.Errorf("expected the problem to carry a trace id")
+				}
+				delete(body, "traceId")
+			}
+
+			if test.expectBody != nil && !reflect.DeepEqual(body, test.expectBody) {
+				t.Errorf("body=%v, got %v", test.expectBody, body)
+			}
+		})
+	}
+}
+
 //==============================================================================
 
 type keystore struct {
-	store     map[string]*rsa.PrivateKey
+	store     map[string]crypto.Signer
 	activeKid string
 }
 
-func newKeystroe(t *testing.T) *keystore {
+func newKeystroe(t testing.TB) *keystore {
 	kid := uuid.NewString()
 	private, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -244,17 +658,127 @@ func newKeystroe(t *testing.T) *keystore {
 	}
 
 	return &keystore{
-		store: map[string]*rsa.PrivateKey{
+		store: map[string]crypto.Signer{
 			kid: private,
 		},
 		activeKid: kid,
 	}
 }
 
-func (ks *keystore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
+func (ks *keystore) PrivateKey(kid string) (crypto.Signer, error) {
 	return ks.store[kid], nil
 }
 
-func (ks *keystore) PublicKey(kid string) (*rsa.PublicKey, error) {
-	return &ks.store[kid].PublicKey, nil
+func (ks *keystore) PublicKey(kid string) (crypto.PublicKey, error) {
+	return ks.store[kid].Public(), nil
+}
+
+type fakeBeginner struct {
+	tx *fakeTx
+}
+
+func (b fakeBeginner) Begin(ctx context.Context) (sqldb.CommitRollbacker, error) {
+	return b.tx, nil
+}
+
+type fakeTx struct {
+	commitErr  error
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit() error {
+	if tx.commitErr != nil {
+		return tx.commitErr
+	}
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	if tx.committed {
+		return sql.ErrTxDone
+	}
+	tx.rolledBack = true
+	return nil
+}
+
+//==============================================================================
+// Benchmarks
+
+func BenchmarkAuthenticate(b *testing.B) {
+	ks := newKeystroe(b)
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: ks,
+		Issuer:    "auth-service",
+		ActiveKID: ks.activeKid,
+	})
+	if err != nil {
+		b.Fatalf("failed to create auth: %s", err)
+	}
+
+	c := auth.Claims{
+		Roles: []string{roleUser},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := authClient.GenerateToken(c)
+	if err != nil {
+		b.Fatalf("failed to generate token: %s", err)
+	}
+
+	h := mid.Authenticate(authClient)(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/bench", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	b.ResetTimer()
+	for range b.N {
+		if err := h(r.Context(), w, r); err != nil {
+			b.Fatalf("failed to authenticate: %s", err)
+		}
+	}
+}
+
+func BenchmarkAuthorize(b *testing.B) {
+	ks := newKeystroe(b)
+	authClient, err := auth.New(auth.Config{
+		KeyLookup: ks,
+		Issuer:    "auth-service",
+		ActiveKID: ks.activeKid,
+	})
+	if err != nil {
+		b.Fatalf("failed to create auth: %s", err)
+	}
+
+	userId := uuid.New()
+	c := auth.Claims{
+		Roles: []string{roleUser},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: userId.String(),
+		},
+	}
+
+	h := mid.Authorize(authClient, auth.RuleAdminOrOwner)(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/bench", nil)
+	ctx := auth.SetUserId(r.Context(), userId)
+	ctx = auth.SetClaims(ctx, c)
+	w := httptest.NewRecorder()
+
+	b.ResetTimer()
+	for range b.N {
+		if err := h(ctx, w, r); err != nil {
+			b.Fatalf("failed to authorize: %s", err)
+		}
+	}
 }
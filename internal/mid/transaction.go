@@ -0,0 +1,110 @@
+package mid
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/sqldb"
+	"github.com/hamidoujand/sales/internal/web"
+)
+
+// BeginCommitRollback runs mutating requests within a transaction, handlers reach it through
+// GetTran. The transaction is committed when the handler returns no error and rolled back
+// otherwise, GET, HEAD and OPTIONS requests are passed through untouched.
+//
+// The response is held back until the commit succeeds so clients never see a success for changes
+// that were not stored.
+func BeginCommitRollback(log *slog.Logger, bgn sqldb.Beginner) web.Middleware {
+	return func(next web.HandlerFunc) web.HandlerFunc {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(ctx, w, r)
+			}
+
+			tx, err := bgn.Begin(ctx)
+			if err != nil {
+				return errs.Newf(http.StatusInternalServerError, "begin transaction: %s", err)
+			}
+
+			committed := false
+			defer func() {
+				if committed {
+					return
+				}
+				if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					log.Error("rollback transaction", "err", err)
+				}
+			}()
+
+			bw := newBufferedWriter(w)
+			if err := next(setTran(ctx, tx), bw, r); err != nil {
+				return err
+			}
+
+			if err := tx.Commit(); err != nil {
+				return errs.Newf(http.StatusInternalServerError, "commit transaction: %s", err)
+			}
+			committed = true
+
+			if err := bw.flush(); err != nil {
+				log.Error("writing committed response", "err", err)
+			}
+			return nil
+		}
+	}
+}
+
+// bufferedWriter keeps the response of a handler in memory until it is flushed.
+type bufferedWriter struct {
+	w          http.ResponseWriter
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBufferedWriter(w http.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{
+		w:      w,
+		header: w.Header().Clone(),
+	}
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) WriteHeader(statusCode int) {
+	if bw.statusCode == 0 {
+		bw.statusCode = statusCode
+	}
+}
+
+func (bw *bufferedWriter) Write(bs []byte) (int, error) {
+	if bw.statusCode == 0 {
+		bw.statusCode = http.StatusOK
+	}
+	return bw.body.Write(bs)
+}
+
+func (bw *bufferedWriter) flush() error {
+	header := bw.w.Header()
+	for k, v := range bw.header {
+		header[k] = v
+	}
+
+	if bw.statusCode == 0 {
+		return nil
+	}
+
+	bw.w.WriteHeader(bw.statusCode)
+	if _, err := bw.body.WriteTo(bw.w); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package page
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EncodeCSV returns the items of the page as CSV records, starting with a header row. Items must
+// be structs, columns are named after the json tag of every exported field and fields that are
+// not plain values are written as JSON.
+func (d Document[T]) EncodeCSV() ([][]string, error) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("items of type %s can not be written as csv", typ)
+	}
+
+	var header []string
+	var columns []int
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+
+		header = append(header, name)
+		columns = append(columns, i)
+	}
+
+	records := make([][]string, 0, len(d.Items)+1)
+	records = append(records, header)
+
+	for _, item := range d.Items {
+		v := reflect.ValueOf(item)
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			cell, err := csvCell(v.Field(column))
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", header[i], err)
+			}
+			record[i] = cell
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func csvCell(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return "", nil
+		}
+		return csvCell(v.Elem())
+	case reflect.String:
+		return escapeFormula(v.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	}
+
+	bs, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// escapeFormula prefixes text that a spreadsheet would run as a formula with a quote, numbers are
+// left alone since they are written by this package and can not carry one.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
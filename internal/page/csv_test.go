@@ -0,0 +1,49 @@
+package page_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hamidoujand/sales/internal/page"
+)
+
+type row struct {
+	Name    string `json:"name"`
+	Balance int    `json:"balance"`
+}
+
+func TestEncodeCSV(t *testing.T) {
+	tests := map[string]struct {
+		item     row
+		expected []string
+	}{
+		"plain_text":      {item: row{Name: "Gopher", Balance: 10}, expected: []string{"Gopher", "10"}},
+		"negative_number": {item: row{Name: "Gopher", Balance: -10}, expected: []string{"Gopher", "-10"}},
+		"formula":         {item: row{Name: "=HYPERLINK(\"http://evil\")"}, expected: []string{"'=HYPERLINK(\"http://evil\")", "0"}},
+		"plus":            {item: row{Name: "+1+1"}, expected: []string{"'+1+1", "0"}},
+		"minus":           {item: row{Name: "-1+1"}, expected: []string{"'-1+1", "0"}},
+		"at":              {item: row{Name: "@SUM(A1)"}, expected: []string{"'@SUM(A1)", "0"}},
+		"tab":             {item: row{Name: "\t=1"}, expected: []string{"'\t=1", "0"}},
+		"carriage_return": {item: row{Name: "\r=1"}, expected: []string{"'\r=1", "0"}},
+		"empty":           {item: row{}, expected: []string{"", "0"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc := page.Document[row]{Items: []row{test.item}}
+
+			records, err := doc.EncodeCSV()
+			if err != nil {
+				t.Fatalf("encoding csv: %s", err)
+			}
+
+			if len(records) != 2 || !slices.Equal(records[0], []string{"name", "balance"}) {
+				t.Fatalf("expected a header and a record, got %q", records)
+			}
+
+			if !slices.Equal(records[1], test.expected) {
+				t.Errorf("record=%q, got %q", test.expected, records[1])
+			}
+		})
+	}
+}
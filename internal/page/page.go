@@ -44,3 +44,40 @@ func Parse(page string, rowsPerPage string) (Page, error) {
 
 	return Page{number: number, rows: rows}, nil
 }
+
+// Number returns the page number.
+func (p Page) Number() int {
+	return p.number
+}
+
+// RowsPerPage returns the number of rows per page.
+func (p Page) RowsPerPage() int {
+	return p.rows
+}
+
+// Offset returns the number of rows that must be skipped to reach this page.
+func (p Page) Offset() int {
+	return (p.number - 1) * p.rows
+}
+
+// Document represents a single page of items alongside the paging information.
+type Document[T any] struct {
+	Items       []T `json:"items"`
+	Total       int `json:"total"`
+	Page        int `json:"page"`
+	RowsPerPage int `json:"rowsPerPage"`
+}
+
+// NewDocument constructs a Document, items are never encoded as null.
+func NewDocument[T any](items []T, total int, p Page) Document[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	return Document[T]{
+		Items:       items,
+		Total:       total,
+		Page:        p.number,
+		RowsPerPage: p.rows,
+	}
+}
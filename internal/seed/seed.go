@@ -0,0 +1,358 @@
+// Package seed fills a database with fixture data through the business layer, it is shared by
+// the admin seed command and the integration tests so both work against the same data.
+package seed
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/mail"
+	"strings"
+
+	"github.com/hamidoujand/sales/internal/domain/orderbus"
+	"github.com/hamidoujand/sales/internal/domain/orderbus/orderdb"
+	"github.com/hamidoujand/sales/internal/domain/productbus"
+	"github.com/hamidoujand/sales/internal/domain/productbus/productdb"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/domain/userbus/userdb"
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Config represents the settings of a seeding run, the same Seed always produces the same data.
+type Config struct {
+	Seed          uint64
+	Accounts      bool    //creates the fixed accounts with well-known passwords, for development only.
+	Users         int     //number of generated users, on top of the fixed accounts.
+	AdminRatio    float64 //share of generated users with the ADMIN role.
+	DisabledRatio float64 //share of generated users that are disabled.
+	Products      int     //number of generated products, owned by random seeded users.
+	Orders        int     //number of generated orders, placed on the products of the same run.
+}
+
+// DefaultConfig is used by the admin seed command when no flags are given.
+var DefaultConfig = Config{
+	Seed:          42,
+	Users:         25,
+	AdminRatio:    0.1,
+	DisabledRatio: 0.1,
+	Products:      50,
+	Orders:        20,
+}
+
+// Result holds what a seeding run created, entities that already existed are not included.
+type Result struct {
+	Users    []userbus.User
+	Products []productbus.Product
+	Orders   []orderbus.Order
+	Skipped  int
+}
+
+type account struct {
+	Name     string   `json:"name"`
+	Email    string   `json:"email"`
+	Roles    []string `json:"roles"`
+	Password string   `json:"password"`
+}
+
+type userFixtures struct {
+	Accounts   []account `json:"accounts"`
+	Password   string    `json:"password"`
+	FirstNames []string  `json:"firstNames"`
+	LastNames  []string  `json:"lastNames"`
+}
+
+type productFixtures struct {
+	Adjectives  []string `json:"adjectives"`
+	Nouns       []string `json:"nouns"`
+	MinCost     int64    `json:"minCost"`
+	MaxCost     int64    `json:"maxCost"`
+	MaxQuantity int      `json:"maxQuantity"`
+}
+
+// Run seeds the database, it can be run more than once since existing entities are skipped.
+func Run(ctx context.Context, log *slog.Logger, db *sqlx.DB, cfg Config) (Result, error) {
+	userBus := userbus.New(userdb.NewStore(log, db))
+	productBus := productbus.New(productdb.NewStore(log, db))
+	orderBus := orderbus.New(orderdb.NewStore(log, db))
+
+	res, owners, err := seedUsers(ctx, userBus, cfg)
+	if err != nil {
+		return Result{}, err
+	}
+
+	products, skipped, err := Products(ctx, productBus, owners, cfg)
+	if err != nil {
+		return Result{}, err
+	}
+
+	orders, err := Orders(ctx, orderBus, owners, products, cfg)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res.Products = products
+	res.Orders = orders
+	res.Skipped += skipped
+
+	return res, nil
+}
+
+// Users creates the fixed accounts of the fixtures when cfg.Accounts is set, followed by cfg.Users
+// generated users.
+func Users(ctx context.Context, bus *userbus.UserBus, cfg Config) (Result, error) {
+	res, _, err := seedUsers(ctx, bus, cfg)
+	return res, err
+}
+
+// seedUsers also returns the users that already existed, so later entities of a second run are
+// given the same owners as in the first one.
+func seedUsers(ctx context.Context, bus *userbus.UserBus, cfg Config) (Result, []userbus.User, error) {
+	fx, err := loadUserFixtures()
+	if err != nil {
+		return Result{}, nil, err
+	}
+
+	users := make([]userbus.NewUser, 0, len(fx.Accounts)+cfg.Users)
+	if cfg.Accounts {
+		for _, acc := range fx.Accounts {
+			nu, err := toNewUser(acc)
+			if err != nil {
+				return Result{}, nil, fmt.Errorf("fixture account %s: %w", acc.Email, err)
+			}
+			users = append(users, nu)
+		}
+	}
+
+	rnd := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed))
+	disabled := make(map[string]bool)
+
+	for i := range cfg.Users {
+		first := fx.FirstNames[rnd.IntN(len(fx.FirstNames))]
+		last := fx.LastNames[rnd.IntN(len(fx.LastNames))]
+
+		roles := []userbus.Role{userbus.RoleUser}
+		if rnd.Float64() < cfg.AdminRatio {
+			roles = append(roles, userbus.RoleAdmin)
+		}
+
+		//the index keeps emails unique no matter how often a name repeats.
+		email := fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), i)
+		if rnd.Float64() < cfg.DisabledRatio {
+			disabled[email] = true
+		}
+
+		users = append(users, userbus.NewUser{
+			Name:     first + " " + last,
+			Email:    mail.Address{Address: email},
+			Roles:    roles,
+			Password: fx.Password,
+		})
+	}
+
+	var res Result
+	all := make([]userbus.User, 0, len(users))
+	for _, nu := range users {
+		usr, err := bus.Create(ctx, nu)
+		if err != nil {
+			if !errors.Is(err, userbus.ErrDuplicatedEmail) {
+				return Result{}, nil, fmt.Errorf("creating user %s: %w", nu.Email.Address, err)
+			}
+
+			existing, err := bus.QueryByEmail(ctx, nu.Email)
+			if err != nil {
+				return Result{}, nil, fmt.Errorf("querying user %s: %w", nu.Email.Address, err)
+			}
+
+			all = append(all, existing)
+			res.Skipped++
+			continue
+		}
+
+		if disabled[nu.Email.Address] {
+			enabled := false
+			usr, err = bus.Update(ctx, usr, userbus.UpdateUser{Enabled: &enabled})
+			if err != nil {
+				return Result{}, nil, fmt.Errorf("disabling user %s: %w", nu.Email.Address, err)
+			}
+		}
+
+		all = append(all, usr)
+		res.Users = append(res.Users, usr)
+	}
+
+	return res, all, nil
+}
+
+// Products creates cfg.Products generated products owned by random users of owners, it returns
+// the created products and the number of skipped ones.
+func Products(ctx context.Context, bus *productbus.ProductBus, owners []userbus.User, cfg Config) ([]productbus.Product, int, error) {
+	if cfg.Products == 0 {
+		return nil, 0, nil
+	}
+
+	if len(owners) == 0 {
+		return nil, 0, errors.New("products need at least one owner")
+	}
+
+	fx, err := loadProductFixtures()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	//products use their own stream so changing the number of users keeps them stable.
+	rnd := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed+1))
+
+	var products []productbus.Product
+	var skipped int
+	for i := range cfg.Products {
+		adj := fx.Adjectives[rnd.IntN(len(fx.Adjectives))]
+		noun := fx.Nouns[rnd.IntN(len(fx.Nouns))]
+
+		np := productbus.NewProduct{
+			UserID:   owners[rnd.IntN(len(owners))].ID,
+			Name:     adj + " Gopher " + noun,
+			SKU:      fmt.Sprintf("GPH-%05d", i),
+			Cost:     fx.MinCost + rnd.Int64N(fx.MaxCost-fx.MinCost+1),
+			Quantity: rnd.IntN(fx.MaxQuantity + 1),
+		}
+
+		prd, err := bus.Create(ctx, np)
+		if err != nil {
+			if errors.Is(err, productbus.ErrDuplicatedSKU) {
+				skipped++
+				continue
+			}
+			return nil, 0, fmt.Errorf("creating product %s: %w", np.SKU, err)
+		}
+
+		products = append(products, prd)
+	}
+
+	return products, skipped, nil
+}
+
+// Orders places cfg.Orders generated orders of random users on the given products and moves
+// them through random statuses. Orders have no natural key to skip on, so only products created
+// by the same run are used and a second run places none.
+func Orders(ctx context.Context, bus *orderbus.OrderBus, buyers []userbus.User, products []productbus.Product, cfg Config) ([]orderbus.Order, error) {
+	if cfg.Orders == 0 || len(products) == 0 {
+		return nil, nil
+	}
+
+	if len(buyers) == 0 {
+		return nil, errors.New("orders need at least one buyer")
+	}
+
+	//orders use their own stream so changing the number of users or products keeps them stable.
+	rnd := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed+2))
+
+	var orders []orderbus.Order
+	for range cfg.Orders {
+		lines := 1 + rnd.IntN(3)
+		items := make([]orderbus.NewItem, lines)
+		for i := range items {
+			items[i] = orderbus.NewItem{
+				ProductID: products[rnd.IntN(len(products))].ID,
+				Quantity:  1 + rnd.IntN(3),
+			}
+		}
+
+		ord, err := bus.Create(ctx, orderbus.NewOrder{
+			UserID: buyers[rnd.IntN(len(buyers))].ID,
+			Items:  items,
+		})
+		if err != nil {
+			//sold out products are part of a realistic catalog.
+			if errors.Is(err, orderbus.ErrInsufficientStock) {
+				continue
+			}
+			return nil, fmt.Errorf("creating order: %w", err)
+		}
+
+		//walk the order through the allowed transitions, stopping at a random status.
+		for _, next := range [][]orderbus.Status{
+			{orderbus.StatusPaid, orderbus.StatusCancelled},
+			{orderbus.StatusShipped, orderbus.StatusCancelled},
+		} {
+			if rnd.IntN(2) == 0 {
+				break
+			}
+
+			updated, err := bus.UpdateStatus(ctx, ord, next[rnd.IntN(len(next))])
+			if err != nil {
+				return nil, fmt.Errorf("updating order %s: %w", ord.ID, err)
+			}
+			ord = updated
+
+			if ord.Status.Equal(orderbus.StatusCancelled) {
+				break
+			}
+		}
+
+		orders = append(orders, ord)
+	}
+
+	return orders, nil
+}
+
+func toNewUser(acc account) (userbus.NewUser, error) {
+	email, err := mail.ParseAddress(acc.Email)
+	if err != nil {
+		return userbus.NewUser{}, fmt.Errorf("parsing email: %w", err)
+	}
+
+	roles, err := userbus.ParseSliceOfRoles(acc.Roles)
+	if err != nil {
+		return userbus.NewUser{}, fmt.Errorf("parsing roles: %w", err)
+	}
+
+	return userbus.NewUser{
+		Name:     acc.Name,
+		Email:    *email,
+		Roles:    roles,
+		Password: acc.Password,
+	}, nil
+}
+
+func loadUserFixtures() (userFixtures, error) {
+	bs, err := fixtures.ReadFile("fixtures/users.json")
+	if err != nil {
+		return userFixtures{}, fmt.Errorf("reading user fixtures: %w", err)
+	}
+
+	var fx userFixtures
+	if err := json.Unmarshal(bs, &fx); err != nil {
+		return userFixtures{}, fmt.Errorf("decoding user fixtures: %w", err)
+	}
+
+	if len(fx.FirstNames) == 0 || len(fx.LastNames) == 0 {
+		return userFixtures{}, errors.New("user fixtures need first and last names")
+	}
+
+	return fx, nil
+}
+
+func loadProductFixtures() (productFixtures, error) {
+	bs, err := fixtures.ReadFile("fixtures/products.json")
+	if err != nil {
+		return productFixtures{}, fmt.Errorf("reading product fixtures: %w", err)
+	}
+
+	var fx productFixtures
+	if err := json.Unmarshal(bs, &fx); err != nil {
+		return productFixtures{}, fmt.Errorf("decoding product fixtures: %w", err)
+	}
+
+	if len(fx.Adjectives) == 0 || len(fx.Nouns) == 0 || fx.MaxCost < fx.MinCost {
+		return productFixtures{}, errors.New("product fixtures need adjectives, nouns and a valid cost range")
+	}
+
+	return fx, nil
+}
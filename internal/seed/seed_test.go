@@ -0,0 +1,102 @@
+package seed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hamidoujand/sales/internal/dbtest"
+	"github.com/hamidoujand/sales/internal/domain/userbus"
+	"github.com/hamidoujand/sales/internal/seed"
+)
+
+func TestSeed(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+	defer cancel()
+	database := dbtest.NewDatabase(ctx, t, "seed")
+
+	cfg := seed.Config{
+		Seed:          7,
+		Accounts:      true,
+		Users:         20,
+		AdminRatio:    0.3,
+		DisabledRatio: 0.3,
+		Products:      15,
+		Orders:        10,
+	}
+
+	res := database.Seed(ctx, t, cfg)
+
+	//2 fixed accounts plus the generated ones.
+	if len(res.Users) != 22 {
+		t.Fatalf("users=%d, got %d", 22, len(res.Users))
+	}
+
+	if res.Users[0].Email.Address != "admin@example.com" {
+		t.Errorf("expected the fixed accounts to be created first, got %s", res.Users[0].Email.Address)
+	}
+
+	var admins, disabled int
+	for _, usr := range res.Users {
+		for _, role := range usr.Roles {
+			if role.Equal(userbus.RoleAdmin) {
+				admins++
+			}
+		}
+
+		if !usr.Enabled {
+			disabled++
+		}
+	}
+
+	if admins <= 1 || admins == len(res.Users) {
+		t.Errorf("expected a mix of admins, got %d admins", admins)
+	}
+
+	if disabled == 0 || disabled == len(res.Users) {
+		t.Errorf("expected a mix of enabled states, got %d disabled", disabled)
+	}
+
+	if len(res.Products) != cfg.Products {
+		t.Fatalf("products=%d, got %d", cfg.Products, len(res.Products))
+	}
+
+	owners := make(map[uuid.UUID]bool)
+	for _, usr := range res.Users {
+		owners[usr.ID] = true
+	}
+
+	for _, prd := range res.Products {
+		if !owners[prd.UserID] {
+			t.Errorf("product %s is owned by an unknown user %s", prd.SKU, prd.UserID)
+		}
+
+		if prd.Cost <= 0 {
+			t.Errorf("product %s: expected a positive cost, got %d", prd.SKU, prd.Cost)
+		}
+	}
+
+	if len(res.Orders) == 0 {
+		t.Fatal("expected orders to be placed")
+	}
+
+	placed := make(map[uuid.UUID]bool)
+	for _, prd := range res.Products {
+		placed[prd.ID] = true
+	}
+
+	for _, ord := range res.Orders {
+		for _, item := range ord.Items {
+			if !placed[item.ProductID] {
+				t.Errorf("order %s has an unknown product %s", ord.ID, item.ProductID)
+			}
+		}
+	}
+
+	//same seed produces the same users and products, so the second run only skips.
+	again := database.Seed(ctx, t, cfg)
+	if len(again.Users) != 0 || len(again.Products) != 0 || len(again.Orders) != 0 || again.Skipped != 22+cfg.Products {
+		t.Errorf("expected every entity to be skipped, got users=%d products=%d orders=%d skipped=%d", len(again.Users), len(again.Products), len(again.Orders), again.Skipped)
+	}
+}
@@ -0,0 +1,182 @@
+package sqldb
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed sql/*.sql
+var migrationFiles embed.FS
+
+// ErrDirty is returned when the last migration failed half way, the schema has to be fixed by
+// hand and the version forced before migrating again.
+var ErrDirty = errors.New("database schema is dirty")
+
+// Migration represents one embedded migration.
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// MigrationStatus represents the state of the database schema.
+type MigrationStatus struct {
+	Version    uint //0 when no migration is applied.
+	Dirty      bool
+	Migrations []Migration
+}
+
+// Applied reports whether the migration is applied to the database.
+func (s MigrationStatus) Applied(m Migration) bool {
+	return m.Version <= s.Version
+}
+
+// Pending returns the migrations that are not applied yet.
+func (s MigrationStatus) Pending() []Migration {
+	var pending []Migration
+	for _, m := range s.Migrations {
+		if !s.Applied(m) {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// Migrator runs the embedded migrations against a database.
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// NewMigrator creates a Migrator for the database.
+func NewMigrator(db *sqlx.DB, dbname string) (*Migrator, error) {
+	dirver, err := postgres.WithInstance(db.DB, &postgres.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("creating postgres driver: %w", err)
+	}
+
+	src, err := iofs.New(migrationFiles, "sql") //prefix of the path: ie: "sql/init.sql"
+	if err != nil {
+		return nil, fmt.Errorf("creating an iofs source: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, dbname, dirver)
+	if err != nil {
+		return nil, fmt.Errorf("creating a migrate instance: %w", err)
+	}
+
+	return &Migrator{m: m}, nil
+}
+
+// Status returns the current version of the schema next to every embedded migration.
+func (mg *Migrator) Status() (MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	version, dirty, err := mg.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return MigrationStatus{}, fmt.Errorf("version: %w", err)
+	}
+
+	return MigrationStatus{
+		Version:    version,
+		Dirty:      dirty,
+		Migrations: migrations,
+	}, nil
+}
+
+// Up applies the next n migrations, every pending one when n <= 0.
+func (mg *Migrator) Up(n int) error {
+	var err error
+	if n <= 0 {
+		err = mg.m.Up()
+	} else {
+		err = mg.m.Steps(n)
+	}
+
+	return mg.result("up", err)
+}
+
+// Down rolls back the last n migrations, n must be positive so the whole schema is never dropped
+// by accident.
+func (mg *Migrator) Down(n int) error {
+	if n <= 0 {
+		return errors.New("down needs a positive number of steps")
+	}
+
+	return mg.result("down", mg.m.Steps(-n))
+}
+
+// Goto migrates up or down to the given version.
+func (mg *Migrator) Goto(version uint) error {
+	return mg.result("goto", mg.m.Migrate(version))
+}
+
+// Force sets the version without running any migration and clears the dirty flag, it is used
+// after fixing a failed migration by hand. -1 means no migration is applied.
+func (mg *Migrator) Force(version int) error {
+	if err := mg.m.Force(version); err != nil {
+		return fmt.Errorf("force: %w", err)
+	}
+	return nil
+}
+
+func (mg *Migrator) result(op string, err error) error {
+	var dirty migrate.ErrDirty
+	switch {
+	case err == nil, errors.Is(err, migrate.ErrNoChange):
+		return nil
+	case errors.As(err, &dirty):
+		return fmt.Errorf("migration %s: version %d: %w", op, dirty.Version, ErrDirty)
+	default:
+		return fmt.Errorf("migration %s: %w", op, err)
+	}
+}
+
+// Migrate applies every pending migration.
+func Migrate(ctx context.Context, db *sqlx.DB, dbname string) error {
+	mg, err := NewMigrator(db, dbname)
+	if err != nil {
+		return err
+	}
+
+	return mg.Up(0)
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		m, err := source.Parse(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("parsing migration %s: %w", entry.Name(), err)
+		}
+
+		if m.Direction != source.Up {
+			continue
+		}
+
+		migrations = append(migrations, Migration{Version: m.Version, Name: m.Identifier})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
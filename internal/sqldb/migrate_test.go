@@ -0,0 +1,103 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/sales/pkg/docker"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("failed to list migrations: %s", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+
+	//versions must be sequential so up/down steps line up with the files.
+	for i, m := range migrations {
+		if m.Version != uint(i+1) {
+			t.Errorf("migration %s: version=%d, got %d", m.Name, i+1, m.Version)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	c, err := docker.StartContainer("postgres:17.2", "test_postgres_migrator", "5432", []string{"-e", "POSTGRES_PASSWORD=password"}, nil)
+	if err != nil {
+		t.Fatalf("failed to start postgres container: %s", err)
+	}
+	defer func() { _ = docker.StopContainer(c.Name) }()
+
+	db, err := Open(Config{
+		Host:       c.HostPort,
+		User:       "postgres",
+		Password:   "password",
+		Name:       "postgres",
+		DisableTLS: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open a conn: %s", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	if err := StatusCheck(ctx, db); err != nil {
+		t.Fatalf("statusCheck failed: %s", err)
+	}
+
+	mg, err := NewMigrator(db, "postgres")
+	if err != nil {
+		t.Fatalf("failed to create migrator: %s", err)
+	}
+
+	status, err := mg.Status()
+	if err != nil {
+		t.Fatalf("failed to get status: %s", err)
+	}
+
+	latest := status.Migrations[len(status.Migrations)-1].Version
+	if status.Version != 0 || len(status.Pending()) != len(status.Migrations) {
+		t.Fatalf("expected a fresh database, got version %d", status.Version)
+	}
+
+	if err := mg.Up(0); err != nil {
+		t.Fatalf("failed to migrate up: %s", err)
+	}
+
+	if err := mg.Down(1); err != nil {
+		t.Fatalf("failed to migrate down: %s", err)
+	}
+
+	if status, _ = mg.Status(); status.Version != latest-1 {
+		t.Errorf("version=%d, got %d", latest-1, status.Version)
+	}
+
+	if err := mg.Goto(latest); err != nil {
+		t.Fatalf("failed to goto %d: %s", latest, err)
+	}
+
+	//simulate a migration that failed half way.
+	if _, err := db.ExecContext(ctx, "UPDATE schema_migrations SET dirty = true"); err != nil {
+		t.Fatalf("failed to mark schema dirty: %s", err)
+	}
+
+	if err := mg.Up(0); !errors.Is(err, ErrDirty) {
+		t.Fatalf("err=%v, got %v", ErrDirty, err)
+	}
+
+	if err := mg.Force(int(latest)); err != nil {
+		t.Fatalf("failed to force version: %s", err)
+	}
+
+	if status, _ = mg.Status(); status.Dirty || status.Version != latest {
+		t.Errorf("expected clean version %d, got %d dirty=%t", latest, status.Version, status.Dirty)
+	}
+}
@@ -0,0 +1,253 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamidoujand/sales/internal/tracing"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExecContext runs the query on a db or a transaction.
+func ExecContext(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, args ...any) (_ sql.Result, err error) {
+	ctx, span := startSpan(ctx, "database.ExecContext", query)
+	defer func() { endSpan(span, err) }()
+
+	log.DebugContext(ctx, "database.ExecContext", "query", positionalQuery{query: query, args: args})
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	return res, nil
+}
+
+// NamedExecContext runs the named query on a db or a transaction.
+func NamedExecContext(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any) (err error) {
+	ctx, span := startSpan(ctx, "database.NamedExecContext", query)
+	defer func() { endSpan(span, err) }()
+
+	log.DebugContext(ctx, "database.NamedExecContext", "query", namedQuery{query: query, data: data})
+
+	if _, err := sqlx.NamedExecContext(ctx, db, query, data); err != nil {
+		return translate(err)
+	}
+	return nil
+}
+
+// NamedQueryStruct runs the named query and scans the first row into dest, ErrNotFound is
+// returned when there are no rows.
+func NamedQueryStruct(ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any, dest any) (err error) {
+	ctx, span := startSpan(ctx, "database.NamedQueryStruct", query)
+	defer func() { endSpan(span, err) }()
+
+	log.DebugContext(ctx, "database.NamedQueryStruct", "query", namedQuery{query: query, data: data})
+
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
+	if err != nil {
+		return translate(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return translate(err)
+		}
+		return ErrNotFound
+	}
+
+	if err := rows.StructScan(dest); err != nil {
+		return fmt.Errorf("structScan: %w", err)
+	}
+
+	return nil
+}
+
+// NamedQuerySlice runs the named query and scans every row into dest.
+func NamedQuerySlice[T any](ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, data any, dest *[]T) (err error) {
+	ctx, span := startSpan(ctx, "database.NamedQuerySlice", query)
+	defer func() { endSpan(span, err) }()
+
+	log.DebugContext(ctx, "database.NamedQuerySlice", "query", namedQuery{query: query, data: data})
+
+	rows, err := sqlx.NamedQueryContext(ctx, db, query, data)
+	if err != nil {
+		return translate(err)
+	}
+	defer rows.Close()
+
+	return scanSlice(rows, dest)
+}
+
+// QuerySlice runs the query with positional args and scans every row into dest.
+func QuerySlice[T any](ctx context.Context, log *slog.Logger, db sqlx.ExtContext, query string, dest *[]T, args ...any) (err error) {
+	ctx, span := startSpan(ctx, "database.QuerySlice", query)
+	defer func() { endSpan(span, err) }()
+
+	log.DebugContext(ctx, "database.QuerySlice", "query", positionalQuery{query: query, args: args})
+
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return translate(err)
+	}
+	defer rows.Close()
+
+	return scanSlice(rows, dest)
+}
+
+func scanSlice[T any](rows *sqlx.Rows, dest *[]T) error {
+	var slice []T
+	for rows.Next() {
+		var v T
+		if err := rows.StructScan(&v); err != nil {
+			return fmt.Errorf("structScan: %w", err)
+		}
+		slice = append(slice, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return translate(err)
+	}
+
+	*dest = slice
+	return nil
+}
+
+// translate maps driver errors to the errors of this package.
+func translate(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case uniqueViolationCode:
+		return fmt.Errorf("%w: %w", ErrDuplicatedEntry, err)
+	case foreignKeyViolationCode:
+		return fmt.Errorf("%w: %w", ErrForeignKeyViolation, err)
+	case checkViolationCode:
+		return fmt.Errorf("%w: %w", ErrCheckViolation, err)
+	case serializationFailureCode:
+		return fmt.Errorf("%w: %w", ErrSerializationFailure, err)
+	case undefinedTableCode:
+		return fmt.Errorf("%w: %w", ErrUndefinedTable, err)
+	}
+
+	return err
+}
+
+//==============================================================================
+// query tracing
+
+// startSpan starts the span of a statement, only the query text is recorded since the args may
+// carry personal data.
+func startSpan(ctx context.Context, name string, query string) (context.Context, trace.Span) {
+	return tracing.AddSpan(ctx, name,
+		semconv.DBSystemPostgreSQL,
+		semconv.DBQueryText(compact(query)),
+	)
+}
+
+// endSpan ends the span of a statement, missing rows are an answer and not a failure.
+func endSpan(span trace.Span, err error) {
+	if !errors.Is(err, ErrNotFound) {
+		tracing.RecordError(span, err)
+	}
+	span.End()
+}
+
+//==============================================================================
+// query logging
+
+// namedQuery fills in the params of a named query only when the log record is handled, so the
+// query is not rebuilt for every statement while debug logs are off.
+type namedQuery struct {
+	query string
+	data  any
+}
+
+func (q namedQuery) LogValue() slog.Value {
+	return slog.StringValue(namedString(q.query, q.data))
+}
+
+// positionalQuery is the namedQuery of queries with $N params.
+type positionalQuery struct {
+	query string
+	args  []any
+}
+
+func (q positionalQuery) LogValue() slog.Value {
+	return slog.StringValue(positionalString(q.query, q.args))
+}
+
+// namedString returns the named query with its params filled in, only meant for logging.
+func namedString(query string, data any) string {
+	query, args, err := sqlx.Named(query, data)
+	if err != nil {
+		return err.Error()
+	}
+
+	//split first so question marks inside the values are left alone.
+	parts := strings.Split(query, "?")
+	var b strings.Builder
+	for i, part := range parts {
+		b.WriteString(part)
+		if i < len(args) && i < len(parts)-1 {
+			b.WriteString(argString(args[i]))
+		}
+	}
+
+	return compact(b.String())
+}
+
+// positionalString returns the query with its $N params filled in, only meant for logging.
+func positionalString(query string, args []any) string {
+	//replace from the last param so $1 does not match the start of $10.
+	for i := len(args); i > 0; i-- {
+		query = strings.ReplaceAll(query, "$"+strconv.Itoa(i), argString(args[i-1]))
+	}
+
+	return compact(query)
+}
+
+func argString(arg any) string {
+	if valuer, ok := arg.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return err.Error()
+		}
+		arg = v
+	}
+
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + v + "'"
+	case []byte:
+		//hashes and other binary data stay out of the logs.
+		return fmt.Sprintf("'<%d bytes>'", len(v))
+	case time.Time:
+		return "'" + v.Format(time.RFC3339Nano) + "'"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func compact(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash BYTEA UNIQUE NOT NULL,
+    access_jti TEXT NOT NULL,
+    access_expires_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    revoke_reason TEXT,
+    date_created TIMESTAMP NOT NULL,
+    CONSTRAINT refresh_tokens_revoke_reason_check
+        CHECK (revoke_reason IN ('used','revoked') AND revoked_at IS NOT NULL OR revoke_reason IS NULL AND revoked_at IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens(family_id);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens(expires_at);
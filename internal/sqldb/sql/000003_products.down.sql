@@ -0,0 +1 @@
+DROP TABLE products;
\ No newline at end of file
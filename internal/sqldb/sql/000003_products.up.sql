@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS products(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    sku TEXT UNIQUE NOT NULL,
+    cost BIGINT NOT NULL CHECK (cost >= 0),
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS products_user_id_idx ON products(user_id);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS orders(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    status TEXT NOT NULL,
+    total BIGINT NOT NULL CHECK (total >= 0),
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS orders_user_id_idx ON orders(user_id);
+
+-- items keep their unit cost, so the history survives price changes and deleted products.
+CREATE TABLE IF NOT EXISTS order_items(
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    cost BIGINT NOT NULL CHECK (cost >= 0),
+    PRIMARY KEY (order_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS order_items_product_id_idx ON order_items(product_id);
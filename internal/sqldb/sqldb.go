@@ -2,29 +2,35 @@ package sqldb
 
 import (
 	"context"
-	"embed"
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	"github.com/golang-migrate/migrate/v4/source/iofs"
-	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
 
-const uniqueViolationCode = "23505"
+// postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolationCode      = "23505"
+	foreignKeyViolationCode  = "23503"
+	checkViolationCode       = "23514"
+	serializationFailureCode = "40001"
+	undefinedTableCode       = "42P01"
+)
 
+// Errors returned by the query helpers, the driver error stays wrapped for the details.
 var (
-	ErrDuplicatedEntry = errors.New("duplicated entry")
+	ErrNotFound             = errors.New("not found")
+	ErrDuplicatedEntry      = errors.New("duplicated entry")
+	ErrForeignKeyViolation  = errors.New("foreign key violation")
+	ErrCheckViolation       = errors.New("check violation")
+	ErrSerializationFailure = errors.New("serialization failure")
+	ErrUndefinedTable       = errors.New("undefined table")
 )
 
-//go:embed sql/*.sql
-var migrationFiles embed.FS
-
 type Config struct {
 	Host         string
 	User         string
@@ -100,38 +106,11 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	return nil
 }
 
-func Migrate(ctx context.Context, db *sqlx.DB, dbname string) error {
-	dirver, err := postgres.WithInstance(db.DB, &postgres.Config{})
-	if err != nil {
-		return fmt.Errorf("creating postgres driver: %w", err)
-	}
-
-	src, err := iofs.New(migrationFiles, "sql") //prefix of the path: ie: "sql/init.sql"
-	if err != nil {
-		return fmt.Errorf("creating an iofs source: %w", err)
-	}
-
-	m, err := migrate.NewWithInstance("iofs", src, dbname, dirver)
-	if err != nil {
-		return fmt.Errorf("creating a migrate instance: %w", err)
-	}
-
-	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return fmt.Errorf("migration up: %w", err)
-	}
+// likeEscaper escapes the wildcards of LIKE patterns, queries using it declare ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 
-	return nil
-}
-
-func NamedExecContext(ctx context.Context, db *sqlx.DB, query string, data any) error {
-	if _, err := db.NamedExecContext(ctx, query, data); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			if pgErr.Code == uniqueViolationCode {
-				return ErrDuplicatedEntry
-			}
-		}
-		return fmt.Errorf("namedExecContext: %w", err)
-	}
-	return nil
+// Contains returns a LIKE pattern that matches values containing s literally, the wildcards of
+// s are escaped so user input can not widen the match. Use it with ESCAPE '\'.
+func Contains(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
 }
@@ -1,11 +1,20 @@
 package sqldb
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hamidoujand/sales/pkg/docker"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func TestDatabaseConn(t *testing.T) {
@@ -43,3 +52,114 @@ func TestDatabaseConn(t *testing.T) {
 		t.Fatalf("statusCheck failed: %s", err)
 	}
 }
+
+func TestTranslate(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		target error
+	}{
+		"no_rows":               {err: sql.ErrNoRows, target: ErrNotFound},
+		"unique_violation":      {err: &pgconn.PgError{Code: "23505"}, target: ErrDuplicatedEntry},
+		"foreign_key_violation": {err: &pgconn.PgError{Code: "23503"}, target: ErrForeignKeyViolation},
+		"check_violation":       {err: &pgconn.PgError{Code: "23514"}, target: ErrCheckViolation},
+		"serialization_failure": {err: &pgconn.PgError{Code: "40001"}, target: ErrSerializationFailure},
+		"undefined_table":       {err: &pgconn.PgError{Code: "42P01"}, target: ErrUndefinedTable},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := translate(fmt.Errorf("wrapped: %w", test.err))
+			if !errors.Is(err, test.target) {
+				t.Errorf("err=%v, got %v", test.target, err)
+			}
+
+			//the driver error stays reachable for its details.
+			var pgErr *pgconn.PgError
+			if _, ok := test.err.(*pgconn.PgError); ok && !errors.As(err, &pgErr) {
+				t.Error("expected the pg error to stay wrapped")
+			}
+		})
+	}
+
+	other := errors.New("connection refused")
+	if err := translate(other); err != other {
+		t.Errorf("err=%v, got %v", other, err)
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"plain":     {input: "gopher", expected: `%gopher%`},
+		"percent":   {input: "100%", expected: `%100\%%`},
+		"under":     {input: "a_b", expected: `%a\_b%`},
+		"backslash": {input: `a\b`, expected: `%a\\b%`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Contains(test.input); got != test.expected {
+				t.Errorf("pattern=%s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	id := uuid.MustParse("2d4c6c43-8a0a-4a66-b1b8-1d4d1c8a2e9f")
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	named := namedString(`
+	SELECT id FROM users
+	WHERE id = :id AND name = :name AND password_hash = :hash AND date_created > :created;`,
+		map[string]any{"id": id, "name": "why?", "hash": []byte("secret"), "created": created},
+	)
+
+	expected := "SELECT id FROM users WHERE id = '2d4c6c43-8a0a-4a66-b1b8-1d4d1c8a2e9f' AND name = 'why?' AND password_hash = '<6 bytes>' AND date_created > '2025-01-02T03:04:05Z';"
+	if named != expected {
+		t.Errorf("query=%q, got %q", expected, named)
+	}
+
+	args := make([]any, 10)
+	for i := range args {
+		args[i] = i + 1
+	}
+	args[9] = nil
+
+	positional := positionalString("SELECT $1, $2, $10;", args)
+	if expected := "SELECT 1, 2, NULL;"; positional != expected {
+		t.Errorf("query=%q, got %q", expected, positional)
+	}
+}
+
+func TestQueryLoggedLazily(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	arg := &countingValuer{}
+	log.Debug("query", "query", positionalQuery{query: "SELECT $1;", args: []any{arg}})
+	if arg.calls != 0 {
+		t.Errorf("expected the query not to be built while debug logs are off, got %d calls", arg.calls)
+	}
+
+	log = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	log.Debug("query", "query", positionalQuery{query: "SELECT $1;", args: []any{arg}})
+	if arg.calls != 1 {
+		t.Errorf("expected the query to be built once, got %d calls", arg.calls)
+	}
+
+	if !strings.Contains(buf.String(), `query="SELECT 'value';"`) {
+		t.Errorf("expected the logged query to have its params filled in, got %q", buf.String())
+	}
+}
+
+type countingValuer struct {
+	calls int
+}
+
+func (v *countingValuer) Value() (driver.Value, error) {
+	v.calls++
+	return "value", nil
+}
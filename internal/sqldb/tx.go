@@ -0,0 +1,87 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CommitRollbacker represents a transaction that can be committed or rolled back.
+type CommitRollbacker interface {
+	Commit() error
+	Rollback() error
+}
+
+// Beginner represents a value that can begin a transaction.
+type Beginner interface {
+	Begin(ctx context.Context) (CommitRollbacker, error)
+}
+
+type dbBeginner struct {
+	db *sqlx.DB
+}
+
+// NewBeginner returns a Beginner that starts transactions on the db.
+func NewBeginner(db *sqlx.DB) Beginner {
+	return &dbBeginner{db: db}
+}
+
+func (b *dbBeginner) Begin(ctx context.Context) (CommitRollbacker, error) {
+	tx, err := b.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginTxx: %w", err)
+	}
+	return tx, nil
+}
+
+// GetExtContext returns the executor of a transaction started by a Beginner of this package,
+// stores use it to run their queries within the transaction.
+func GetExtContext(tx CommitRollbacker) (sqlx.ExtContext, error) {
+	ec, ok := tx.(sqlx.ExtContext)
+	if !ok {
+		return nil, fmt.Errorf("transaction of type %T can not execute queries", tx)
+	}
+	return ec, nil
+}
+
+// ExecUnderTx begins a transaction and hands it to fn, buses bound to the transaction are created
+// with their NewWithTx. The transaction is committed when fn returns no error and rolled back
+// otherwise.
+func ExecUnderTx(ctx context.Context, b Beginner, fn func(tx CommitRollbacker) error) error {
+	tx, err := b.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("rollback: %w: %w", rbErr, err)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
+
+// InTx runs fn within a transaction on db. When db is already a transaction fn joins it and the
+// owner of that transaction decides about the commit, otherwise a transaction is started and
+// committed when fn returns no error.
+func InTx(ctx context.Context, db sqlx.ExtContext, fn func(tx sqlx.ExtContext) error) error {
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		return fn(db)
+	case *sqlx.DB:
+		return ExecUnderTx(ctx, NewBeginner(db), func(tx CommitRollbacker) error {
+			return fn(tx.(*sqlx.Tx))
+		})
+	default:
+		return fmt.Errorf("unsupported executor of type %T", db)
+	}
+}
@@ -0,0 +1,101 @@
+// Package tracing provides the OpenTelemetry support of the service, spans are exported to an
+// OTLP/HTTP collector and the W3C trace context is propagated across services.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName names the instrumentation of the service.
+const TracerName = "github.com/hamidoujand/sales"
+
+// Config represents the settings of the tracing support.
+type Config struct {
+	ServiceName string
+	Build       string
+	Endpoint    string  //host:port of the OTLP/HTTP collector, spans are not exported when empty.
+	URLPath     string  //defaults to /v1/traces.
+	Insecure    bool    //sends spans over plain http.
+	Probability float64 //fraction of the new traces that are sampled, between 0 and 1.
+}
+
+// Init installs the global tracer provider and propagator, the returned function flushes the
+// pending spans and must be called on shutdown. Requests that arrive with a sampled traceparent
+// are always recorded so traces stay complete across services.
+func Init(log *slog.Logger, cfg Config) (func(ctx context.Context), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Endpoint == "" {
+		log.Info("tracing", "status", "disabled")
+		return func(context.Context) {}, nil
+	}
+
+	if cfg.Probability < 0 || cfg.Probability > 1 {
+		return nil, fmt.Errorf("probability must be between 0 and 1, got %v", cfg.Probability)
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(cfg.URLPath))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Probability))),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(cfg.Build),
+		)),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Error("tracing", "err", err)
+	}))
+
+	log.Info("tracing", "status", "enabled", "endpoint", cfg.Endpoint, "probability", cfg.Probability)
+
+	shutdown := func(ctx context.Context) {
+		if err := tp.Shutdown(ctx); err != nil {
+			log.Error("tracing", "status", "shutdown", "err", err)
+		}
+	}
+	return shutdown, nil
+}
+
+// AddSpan starts a child span of the span in ctx, the caller ends it.
+func AddSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks the span as failed when err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -2,31 +2,46 @@ package web
 
 import (
 	"context"
+	"encoding/hex"
+	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RequestData represents additional data we want to collect arount each request.
 type RequestData struct {
-	//TODO: traceID should be able to pass it cross processes in microservices, so will be replaced with openTel traceId.
-	TraceID    string
+	TraceID    string //trace id of the request span, shared with the services the request passes through.
 	StartedAt  time.Time
 	StatusCode int
+	Accept     string //accept header of the request, drives the encoding of Respond.
 }
 
 type ctxKey int
 
 const reqKey ctxKey = 1
 
-func setRequestData(ctx context.Context) context.Context {
+func setRequestData(ctx context.Context, r *http.Request) context.Context {
 	rd := RequestData{
-		TraceID:   uuid.NewString(),
+		TraceID:   traceID(ctx),
 		StartedAt: time.Now(),
+		Accept:    r.Header.Get("Accept"),
 	}
 	return context.WithValue(ctx, reqKey, &rd)
 }
 
+// traceID returns the trace id of the span in ctx. Without tracing there is no valid span, a
+// random id in the same format keeps the log lines of a request together.
+func traceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+
+	id := uuid.New()
+	return hex.EncodeToString(id[:])
+}
+
 func GetTraceID(ctx context.Context) string {
 	rd, ok := ctx.Value(reqKey).(*RequestData)
 	if !ok {
@@ -61,3 +76,12 @@ func GetStatusCode(ctx context.Context) int {
 
 	return rd.StatusCode
 }
+
+func getAccept(ctx context.Context) string {
+	rd, ok := ctx.Value(reqKey).(*RequestData)
+	if !ok {
+		return ""
+	}
+
+	return rd.Accept
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/hamidoujand/sales/internal/errs"
+)
+
+// maxBodySize is the largest request body Decode reads.
+const maxBodySize = 1 << 20
+
+// Validator is implemented by request models that validate themselves after decoding, returning
+// errs.FieldErrors reports every failed field to the client.
+type Validator interface {
+	Validate() error
+}
+
+// Decode reads the JSON body of the request into v. The body must be sent as application/json,
+// must not be larger than 1MB and can only hold known fields. When v is a Validator it is
+// validated afterwards.
+func Decode(r *http.Request, v any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errs.Newf(http.StatusUnsupportedMediaType, "content type must be application/json, got %q", r.Header.Get("Content-Type"))
+	}
+
+	body := http.MaxBytesReader(nil, r.Body, maxBodySize)
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	//a second document or trailing garbage means the body is not a single JSON value.
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errs.Newf(http.StatusBadRequest, "request body must hold a single JSON value")
+	}
+
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			var fields errs.FieldErrors
+			if errors.As(err, &fields) {
+				return errs.NewValidation(http.StatusBadRequest, fields, "validation failed")
+			}
+			return errs.Newf(http.StatusBadRequest, "validation failed: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return errs.Newf(http.StatusRequestEntityTooLarge, "request body must not be larger than %d bytes", maxBytesErr.Limit)
+	case errors.Is(err, io.EOF):
+		return errs.Newf(http.StatusBadRequest, "request body must not be empty")
+	case errors.As(err, &syntaxErr):
+		return errs.Newf(http.StatusBadRequest, "request body has malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errs.Newf(http.StatusBadRequest, "request body has malformed JSON")
+	case errors.As(err, &typeErr):
+		return errs.Newf(http.StatusBadRequest, "request body field %q must be of type %s", typeErr.Field, typeErr.Type)
+	default:
+		//unknown fields come back as plain errors from encoding/json.
+		return errs.Newf(http.StatusBadRequest, "decoding request body: %s", err)
+	}
+}
@@ -1,13 +1,62 @@
 package web
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hamidoujand/sales/internal/errs"
 )
 
+// Encoder is implemented by values that choose their own encoding, Respond writes the returned
+// bytes as they are whatever the client accepts.
+type Encoder interface {
+	Encode() (data []byte, contentType string, err error)
+}
+
+// CSVEncoder is implemented by values that can be written as CSV, like pages of list endpoints.
+// Respond uses it when the client prefers text/csv.
+type CSVEncoder interface {
+	EncodeCSV() ([][]string, error)
+}
+
+// Raw is a response of bytes that are already encoded, like a file kept in memory.
+type Raw struct {
+	Data        []byte
+	ContentType string
+	Filename    string //offers the data as a download when set.
+}
+
+// Encode implements the Encoder interface.
+func (r Raw) Encode() ([]byte, string, error) {
+	contentType := r.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return r.Data, contentType, nil
+}
+
+// Stream is a response body that is copied to the client while it is read, so large downloads
+// never sit in memory.
+type Stream struct {
+	Reader      io.Reader
+	ContentType string
+	Size        int64  //sent as Content-Length when greater than zero.
+	Filename    string //offers the data as a download when set.
+}
+
+// Respond writes data with the status code. Values that are an Encoder or a Stream pick their
+// own encoding, everything else is sent as JSON unless the Accept header of the request prefers
+// CSV and data is a CSVEncoder. Errors are always sent as JSON so every client can read them.
 func Respond(ctx context.Context, w http.ResponseWriter, statusCode int, data any) error {
 	if err := ctx.Err(); err != nil {
 		if errors.Is(err, context.Canceled) {
@@ -15,23 +64,170 @@ func Respond(ctx context.Context, w http.ResponseWriter, statusCode int, data an
 		}
 	}
 
-	setStatusCode(ctx, statusCode)
-
 	//no content
 	if statusCode == http.StatusNoContent {
+		setStatusCode(ctx, statusCode)
 		//just write the header
 		w.WriteHeader(statusCode)
 		return nil
 	}
 
-	bs, err := json.Marshal(data)
+	switch v := data.(type) {
+	case Stream:
+		setStatusCode(ctx, statusCode)
+		return writeStream(w, statusCode, v)
+	case *Stream:
+		setStatusCode(ctx, statusCode)
+		return writeStream(w, statusCode, *v)
+	case Raw:
+		setDisposition(w, v.Filename)
+	case *Raw:
+		setDisposition(w, v.Filename)
+	}
+
+	bs, contentType, err := encode(ctx, data)
 	if err != nil {
-		return fmt.Errorf("marshal: %w", err)
+		return err
 	}
 
+	setStatusCode(ctx, statusCode)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(bs)))
 	w.WriteHeader(statusCode)
 	if _, err := w.Write(bs); err != nil {
 		return fmt.Errorf("writing response: %w", err)
 	}
 	return nil
 }
+
+func encode(ctx context.Context, data any) ([]byte, string, error) {
+	if enc, ok := data.(Encoder); ok {
+		bs, contentType, err := enc.Encode()
+		if err != nil {
+			return nil, "", fmt.Errorf("encode: %w", err)
+		}
+		return bs, contentType, nil
+	}
+
+	if _, ok := data.(error); ok {
+		return encodeJSON(data)
+	}
+
+	accept := getAccept(ctx)
+	mediaTypes := acceptedTypes(accept)
+	if len(mediaTypes) == 0 {
+		return encodeJSON(data)
+	}
+
+	for _, mediaType := range mediaTypes {
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			return encodeJSON(data)
+		case "text/csv", "text/*":
+			if enc, ok := data.(CSVEncoder); ok {
+				return encodeCSV(enc)
+			}
+		}
+	}
+
+	return nil, "", errs.Newf(http.StatusNotAcceptable, "none of the accepted media types %q can be produced", accept)
+}
+
+func encodeJSON(data any) ([]byte, string, error) {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshal: %w", err)
+	}
+	return bs, "application/json", nil
+}
+
+func encodeCSV(enc CSVEncoder) ([]byte, string, error) {
+	records, err := enc.EncodeCSV()
+	if err != nil {
+		return nil, "", fmt.Errorf("encodeCSV: %w", err)
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	if err := cw.WriteAll(records); err != nil {
+		return nil, "", fmt.Errorf("writing csv: %w", err)
+	}
+	return buf.Bytes(), "text/csv; charset=utf-8", nil
+}
+
+func writeStream(w http.ResponseWriter, statusCode int, s Stream) error {
+	contentType := s.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if s.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(s.Size, 10))
+	}
+	setDisposition(w, s.Filename)
+
+	w.WriteHeader(statusCode)
+	if _, err := io.Copy(w, s.Reader); err != nil {
+		return fmt.Errorf("streaming response: %w", err)
+	}
+	return nil
+}
+
+func setDisposition(w http.ResponseWriter, filename string) {
+	if filename == "" {
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+}
+
+// Accepts reports whether the Accept header of the request explicitly lists the media type,
+// wildcards do not count so clients opt in to formats like application/problem+json.
+func Accepts(r *http.Request, mediaType string) bool {
+	return slices.Contains(acceptedTypes(r.Header.Get("Accept")), mediaType)
+}
+
+// acceptedTypes returns the media types of the accept header ordered by their quality, types the
+// client refuses with q=0 are left out.
+func acceptedTypes(accept string) []string {
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		if quality > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+		}
+	}
+
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	mediaTypes := make([]string, len(ranges))
+	for i, r := range ranges {
+		mediaTypes[i] = r.mediaType
+	}
+	return mediaTypes
+}
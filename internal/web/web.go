@@ -5,6 +5,13 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+
+	"github.com/hamidoujand/sales/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type HandlerFunc func(ctx context.Context, w http.ResponseWriter, r *http.Request) error
@@ -27,26 +34,44 @@ func (r *Router) HandleFunc(method string, version string, path string, handlerF
 	handler := applyMiddleware(handlerFunc, mids...)
 	handler = applyMiddleware(handler, r.mids...)
 
+	route := path
+	if version != "" {
+		route = "/" + version + path
+	}
+	pattern := fmt.Sprintf("%s %s", method, route)
+
 	h := func(w http.ResponseWriter, req *http.Request) {
 		//this is the actual outer layer that will be called by serveMux , here is where
 		//we call our own custom handler.
 
-		ctx := req.Context()
-		ctx = setRequestData(ctx)
+		//requests continue the trace of the caller when they carry a traceparent header.
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := otel.Tracer(tracing.TracerName).Start(ctx, pattern,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(req.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ctx = setRequestData(ctx, req)
 		if err := handler(ctx, w, req); err != nil {
 			//with proper error handler middleware, we should not get an error in here
 			//if it did, we just log it.
+			tracing.RecordError(span, err)
 			r.log.Error("router", "status", "handlerFunc", "err", err)
 			return
 		}
-	}
 
-	pattern := path
-	if version != "" {
-		pattern = "/" + version + path
+		statusCode := GetStatusCode(ctx)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
+		if statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(statusCode))
+		}
 	}
 
-	pattern = fmt.Sprintf("%s %s", method, pattern)
 	r.ServeMux.HandleFunc(pattern, h)
 }
 
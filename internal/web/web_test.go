@@ -3,13 +3,23 @@ package web_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/hamidoujand/sales/internal/errs"
+	"github.com/hamidoujand/sales/internal/page"
 	"github.com/hamidoujand/sales/internal/web"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type data struct {
@@ -21,7 +31,7 @@ type ctxKey int
 const dataKey ctxKey = 1
 
 func TestRouter(t *testing.T) {
-	log := slog.New(slog.DiscardHandler)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
 	r := web.NewRouter(log, mid1)
 
 	server := httptest.NewServer(r)
@@ -44,6 +54,64 @@ func TestRouter(t *testing.T) {
 	}
 }
 
+func TestRouterTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer tp.Shutdown(context.Background())
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	var gotTraceID string
+	r := web.NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r.HandleFunc(http.MethodGet, "v1", "/products/{product_id}", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		gotTraceID = web.GetTraceID(ctx)
+		return web.Respond(ctx, w, http.StatusInternalServerError, map[string]string{"status": "failed"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/products/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotTraceID != traceID {
+		t.Errorf("traceID=%s, got %s", traceID, gotTraceID)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("spans=%d, got %d", 1, len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "GET /v1/products/{product_id}" {
+		t.Errorf("name=%q, got %q", "GET /v1/products/{product_id}", span.Name())
+	}
+
+	if span.Parent().SpanID().String() != spanID {
+		t.Errorf("parent=%s, got %s", spanID, span.Parent().SpanID())
+	}
+
+	if span.Status().Code != codes.Error {
+		t.Errorf("status=%s, got %s", codes.Error, span.Status().Code)
+	}
+
+	//requests without a traceparent start a trace of their own.
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/products/42", nil))
+	spans = recorder.Ended()
+	if len(spans) != 2 || spans[1].Parent().IsValid() {
+		t.Fatalf("expected a root span for the request without traceparent")
+	}
+
+	if gotTraceID != spans[1].SpanContext().TraceID().String() {
+		t.Errorf("traceID=%s, got %s", spans[1].SpanContext().TraceID(), gotTraceID)
+	}
+}
+
 func mid1(next web.HandlerFunc) web.HandlerFunc {
 	//inject data into ctx
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -132,3 +200,241 @@ func TestRespond(t *testing.T) {
 		t.Fatalf("msg=%s, got %s", msg, received)
 	}
 }
+
+type newItem struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+func (ni newItem) Validate() error {
+	fields := make(errs.FieldErrors)
+	if ni.Name == "" {
+		fields["name"] = "name is required"
+	}
+	if ni.Quantity <= 0 {
+		fields["quantity"] = "quantity must be positive"
+	}
+	return fields.Err()
+}
+
+func TestDecode(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		body        string
+		statusCode  int
+		fields      []string
+	}{
+		"valid": {
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"plush","quantity":2}`,
+		},
+		"wrong_content_type": {
+			contentType: "text/plain",
+			body:        `{"name":"plush","quantity":2}`,
+			statusCode:  http.StatusUnsupportedMediaType,
+		},
+		"missing_content_type": {
+			body:       `{"name":"plush","quantity":2}`,
+			statusCode: http.StatusUnsupportedMediaType,
+		},
+		"unknown_field": {
+			contentType: "application/json",
+			body:        `{"name":"plush","quantity":2,"price":10}`,
+			statusCode:  http.StatusBadRequest,
+		},
+		"wrong_type": {
+			contentType: "application/json",
+			body:        `{"name":"plush","quantity":"2"}`,
+			statusCode:  http.StatusBadRequest,
+		},
+		"malformed": {
+			contentType: "application/json",
+			body:        `{"name":"plush",`,
+			statusCode:  http.StatusBadRequest,
+		},
+		"empty": {
+			contentType: "application/json",
+			statusCode:  http.StatusBadRequest,
+		},
+		"trailing_data": {
+			contentType: "application/json",
+			body:        `{"name":"plush","quantity":2}{}`,
+			statusCode:  http.StatusBadRequest,
+		},
+		"too_large": {
+			contentType: "application/json",
+			body:        `{"name":"` + strings.Repeat("a", 1<<20) + `","quantity":2}`,
+			statusCode:  http.StatusRequestEntityTooLarge,
+		},
+		"invalid_fields": {
+			contentType: "application/json",
+			body:        `{"name":"","quantity":0}`,
+			statusCode:  http.StatusBadRequest,
+			fields:      []string{"name", "quantity"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/items", strings.NewReader(test.body))
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+
+			var ni newItem
+			err := web.Decode(r, &ni)
+
+			if test.statusCode == 0 {
+				if err != nil {
+					t.Fatalf("failed to decode: %s", err)
+				}
+				if ni.Name != "plush" || ni.Quantity != 2 {
+					t.Errorf("item={plush 2}, got %v", ni)
+				}
+				return
+			}
+
+			var appErr *errs.Error
+			if !errors.As(err, &appErr) {
+				t.Fatalf("expected the returned error to be of type errs.Error, got %T", err)
+			}
+
+			if appErr.Code != test.statusCode {
+				t.Errorf("status=%d, got %d", test.statusCode, appErr.Code)
+			}
+
+			for _, field := range test.fields {
+				if _, ok := appErr.Fields[field]; !ok {
+					t.Errorf("expected field %q to fail, got %v", field, appErr.Fields)
+				}
+			}
+		})
+	}
+}
+
+type product struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Cost  int64    `json:"cost"`
+	Tags  []string `json:"tags"`
+	notes string
+}
+
+func TestRespondNegotiation(t *testing.T) {
+	pg, err := page.Parse("1", "10")
+	if err != nil {
+		t.Fatalf("parsing page: %s", err)
+	}
+
+	doc := page.NewDocument([]product{{ID: "1", Name: "Gopher, Plush", Cost: 1999, Tags: []string{"toy"}}}, 1, pg)
+
+	tests := map[string]struct {
+		accept      string
+		data        any
+		statusCode  int
+		contentType string
+		body        string
+		disposition string
+	}{
+		"no_accept_is_json": {
+			data:        doc,
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `{"items":[{"id":"1","name":"Gopher, Plush","cost":1999,"tags":["toy"]}],"total":1,"page":1,"rowsPerPage":10}`,
+		},
+		"csv": {
+			accept:      "text/csv",
+			data:        doc,
+			statusCode:  http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body:        "id,name,cost,tags\n1,\"Gopher, Plush\",1999,\"[\"\"toy\"\"]\"\n",
+		},
+		"quality_prefers_json": {
+			accept:      "text/csv;q=0.5, application/json",
+			data:        doc,
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+		},
+		"csv_fallback_to_any": {
+			accept:      "text/csv, */*;q=0.1",
+			data:        map[string]string{"status": "ok"},
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `{"status":"ok"}`,
+		},
+		"not_acceptable": {
+			accept:     "text/csv",
+			data:       map[string]string{"status": "ok"},
+			statusCode: http.StatusNotAcceptable,
+		},
+		"errors_are_json": {
+			accept:      "text/csv",
+			data:        errs.Newf(http.StatusNotFound, "product not found"),
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `{"code":404,"message":"product not found"}`,
+		},
+		"raw": {
+			accept:      "application/json",
+			data:        web.Raw{Data: []byte("gopher"), ContentType: "text/plain", Filename: "gopher.txt"},
+			statusCode:  http.StatusOK,
+			contentType: "text/plain",
+			body:        "gopher",
+			disposition: `attachment; filename=gopher.txt`,
+		},
+		"stream": {
+			data:        web.Stream{Reader: strings.NewReader("gopher"), Size: 6},
+			statusCode:  http.StatusOK,
+			contentType: "application/octet-stream",
+			body:        "gopher",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := web.NewRouter(slog.New(slog.NewTextHandler(io.Discard, nil)))
+			r.HandleFunc(http.MethodGet, "v1", "/products", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				if err := web.Respond(ctx, w, http.StatusOK, test.data); err != nil {
+					var appErr *errs.Error
+					if errors.As(err, &appErr) {
+						w.WriteHeader(appErr.Code)
+						return nil
+					}
+					return err
+				}
+				return nil
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/products", nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.statusCode {
+				t.Fatalf("status=%d, got %d", test.statusCode, w.Code)
+			}
+
+			if test.statusCode != http.StatusOK {
+				return
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != test.contentType {
+				t.Errorf("contentType=%q, got %q", test.contentType, ct)
+			}
+
+			if cl := w.Header().Get("Content-Length"); cl != strconv.Itoa(w.Body.Len()) {
+				t.Errorf("contentLength=%d, got %q", w.Body.Len(), cl)
+			}
+
+			if test.body != "" && w.Body.String() != test.body {
+				t.Errorf("body=%q, got %q", test.body, w.Body.String())
+			}
+
+			if cd := w.Header().Get("Content-Disposition"); cd != test.disposition {
+				t.Errorf("disposition=%q, got %q", test.disposition, cd)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package jwks_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hamidoujand/sales/pkg/jwks"
+)
+
+func TestRoundTrip(t *testing.T) {
+	key := generateKey(t)
+
+	jwk := jwks.FromRSA("kid", &key.PublicKey)
+	decoded, err := jwk.RSA()
+	if err != nil {
+		t.Fatalf("failed to decode jwk: %s", err)
+	}
+
+	if !decoded.Equal(&key.PublicKey) {
+		t.Fatal("expected decoded key to be equal to the original one")
+	}
+}
+
+func TestRoundTripKeys(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	tests := map[string]struct {
+		key crypto.PublicKey
+		alg string
+	}{
+		"rsa":     {key: &generateKey(t).PublicKey, alg: "RS256"},
+		"p256":    {key: &p256.PublicKey, alg: "ES256"},
+		"p384":    {key: &p384.PublicKey, alg: "ES384"},
+		"ed25519": {key: edPublic, alg: "EdDSA"},
+	}
+
+	type equaler interface {
+		Equal(crypto.PublicKey) bool
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			jwk, err := jwks.FromKey("kid", tt.key)
+			if err != nil {
+				t.Fatalf("failed to encode key: %s", err)
+			}
+
+			if jwk.Alg != tt.alg {
+				t.Errorf("alg=%s, got %s", tt.alg, jwk.Alg)
+			}
+
+			decoded, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatalf("failed to decode jwk: %s", err)
+			}
+
+			if !decoded.(equaler).Equal(tt.key) {
+				t.Fatal("expected decoded key to be equal to the original one")
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var mu sync.Mutex
+	keys := map[string]crypto.PublicKey{
+		"first": &generateKey(t).PublicKey,
+	}
+
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		_ = json.NewEncoder(w).Encode(jwks.NewSet(keys))
+	}))
+	defer server.Close()
+
+	lookup := jwks.NewLookup(server.URL, time.Hour, 0)
+
+	if _, err := lookup.PublicKey("first"); err != nil {
+		t.Fatalf("failed to lookup key: %s", err)
+	}
+
+	//cached
+	if _, err := lookup.PublicKey("first"); err != nil {
+		t.Fatalf("failed to lookup key: %s", err)
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("hits=%d, got %d", 1, hits.Load())
+	}
+
+	//rotation on the remote side, unknown kid forces a refresh.
+	mu.Lock()
+	keys["second"] = &generateKey(t).PublicKey
+	mu.Unlock()
+
+	if _, err := lookup.PublicKey("second"); err != nil {
+		t.Fatalf("failed to lookup rotated key: %s", err)
+	}
+
+	if hits.Load() != 2 {
+		t.Errorf("hits=%d, got %d", 2, hits.Load())
+	}
+
+	if _, err := lookup.PublicKey("unknown"); !errors.Is(err, jwks.ErrNotFound) {
+		t.Errorf("err=%v, got %v", jwks.ErrNotFound, err)
+	}
+
+	if _, err := lookup.PrivateKey("first"); !errors.Is(err, jwks.ErrPrivateKeyNotExists) {
+		t.Errorf("err=%v, got %v", jwks.ErrPrivateKeyNotExists, err)
+	}
+}
+
+func TestLookupMinRefresh(t *testing.T) {
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_ = json.NewEncoder(w).Encode(jwks.Set{})
+	}))
+	defer server.Close()
+
+	lookup := jwks.NewLookup(server.URL, time.Hour, time.Minute)
+
+	for range 5 {
+		if _, err := lookup.PublicKey("unknown"); !errors.Is(err, jwks.ErrNotFound) {
+			t.Fatalf("err=%v, got %v", jwks.ErrNotFound, err)
+		}
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("hits=%d, got %d", 1, hits.Load())
+	}
+}
+
+func TestLookupSlowRemote(t *testing.T) {
+	keys := map[string]crypto.PublicKey{
+		"first": &generateKey(t).PublicKey,
+	}
+
+	release := make(chan struct{})
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//every fetch after the first one hangs until released.
+		if hits.Add(1) > 1 {
+			<-release
+		}
+		_ = json.NewEncoder(w).Encode(jwks.NewSet(keys))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	lookup := jwks.NewLookup(server.URL, time.Hour, 0)
+	if _, err := lookup.PublicKey("first"); err != nil {
+		t.Fatalf("failed to lookup key: %s", err)
+	}
+
+	//unknown kids start a refresh that hangs on the remote, they share it.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+			defer cancel()
+
+			if _, err := lookup.PublicKeyContext(ctx, "unknown"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("err=%v, got %v", context.DeadlineExceeded, err)
+			}
+		}()
+	}
+
+	//cached keys are served while the refresh is in progress.
+	time.Sleep(time.Millisecond * 50)
+	start := time.Now()
+	if _, err := lookup.PublicKey("first"); err != nil {
+		t.Fatalf("failed to lookup key: %s", err)
+	}
+
+	if took := time.Since(start); took > time.Millisecond*50 {
+		t.Errorf("expected the cached key to be served right away, took %s", took)
+	}
+
+	wg.Wait()
+
+	if hits.Load() != 2 {
+		t.Errorf("hits=%d, got %d", 2, hits.Load())
+	}
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	return key
+}
@@ -0,0 +1,174 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound            = errors.New("public key not found")
+	ErrPrivateKeyNotExists = errors.New("remote key sets do not provide private keys")
+)
+
+// Lookup fetches public keys from a remote JWKS url and caches them. It satisfies the
+// auth.KeyLookup interface for verifying tokens only.
+type Lookup struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	inflight    *fetch
+}
+
+// fetch is a refresh in progress, callers that need the keys meanwhile wait for it instead of
+// fetching them again.
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewLookup creates a Lookup, keys are refetched when older than ttl or when an unknown kid
+// is requested, but never more often than minRefresh.
+func NewLookup(url string, ttl time.Duration, minRefresh time.Duration) *Lookup {
+	return &Lookup{
+		url:        url,
+		client:     &http.Client{Timeout: time.Second * 10},
+		ttl:        ttl,
+		minRefresh: minRefresh,
+		keys:       make(map[string]crypto.PublicKey),
+	}
+}
+
+func (l *Lookup) PrivateKey(kid string) (crypto.Signer, error) {
+	return nil, ErrPrivateKeyNotExists
+}
+
+func (l *Lookup) PublicKey(kid string) (crypto.PublicKey, error) {
+	return l.PublicKeyContext(context.Background(), kid)
+}
+
+// PublicKeyContext returns the key of the kid, a refresh it has to wait for is bounded by ctx.
+func (l *Lookup) PublicKeyContext(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	l.mu.RLock()
+	key, ok := l.keys[kid]
+	fresh := time.Since(l.fetchedAt) < l.ttl
+	l.mu.RUnlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+
+	//unknown kid or stale cache, refresh unless we just tried.
+	if err := l.refresh(ctx); err != nil {
+		//serve the stale key rather than failing every request while the remote is down.
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("refresh: %w", err)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	key, ok = l.keys[kid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return key, nil
+}
+
+// refresh fetches the key set without holding the lock, so lookups of cached keys are never
+// blocked by a slow remote. Concurrent refreshes share a single fetch and every caller stops
+// waiting for it when its own ctx is done.
+func (l *Lookup) refresh(ctx context.Context) error {
+	l.mu.Lock()
+	f := l.inflight
+	if f == nil {
+		if !l.lastAttempt.IsZero() && time.Since(l.lastAttempt) < l.minRefresh {
+			l.mu.Unlock()
+			return nil
+		}
+
+		f = &fetch{done: make(chan struct{})}
+		l.inflight = f
+		l.lastAttempt = time.Now()
+
+		//the fetch is shared, so it must outlive the caller that started it.
+		go l.run(context.WithoutCancel(ctx), f)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run fetches the key set and swaps it in, the lock is only held for the swap.
+func (l *Lookup) run(ctx context.Context, f *fetch) {
+	keys, err := l.fetch(ctx)
+
+	l.mu.Lock()
+	if err == nil {
+		l.keys = keys
+		l.fetchedAt = time.Now()
+	}
+	l.inflight = nil
+	f.err = err
+	l.mu.Unlock()
+
+	close(f.done)
+}
+
+func (l *Lookup) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching key set: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching key set: unexpected status %d", resp.StatusCode)
+	}
+
+	var set Set
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.PublicKey()
+		if err != nil {
+			//skip the keys we do not understand.
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
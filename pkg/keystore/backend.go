@@ -0,0 +1,182 @@
+package keystore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backend defines the required behavior in order to fetch the PEM encoded private keys, keyed by
+// kid, and the active kid.
+type Backend interface {
+	Keys(ctx context.Context) (keys map[string][]byte, activeKID string, err error)
+}
+
+// FSBackend reads the keys from a directory laid out by admin genkey:
+//
+//	<kid>-private.pem
+//	<kid>-public.pem
+//	active.txt
+type FSBackend struct {
+	fsys fs.FS
+}
+
+// NewFSBackend creates a backend over the given file system.
+func NewFSBackend(fsys fs.FS) *FSBackend {
+	return &FSBackend{fsys: fsys}
+}
+
+func (b *FSBackend) Keys(ctx context.Context) (map[string][]byte, string, error) {
+	keys := make(map[string][]byte)
+
+	//Example: c3550713-13e7-4a53-977a-dd53cbcb7088-private.pem
+	err := fs.WalkDir(b.fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("trying to open %s: %w", path, err)
+		}
+
+		//skip dirs
+		if d.IsDir() {
+			return nil
+		}
+
+		//skip non-pem files
+		if filepath.Ext(path) != ".pem" {
+			return nil
+		}
+
+		//skip the public ones
+		if strings.HasSuffix(path, "-public.pem") {
+			return nil
+		}
+
+		//get the kid
+		kid := strings.TrimSuffix(d.Name(), "-private.pem")
+
+		file, err := b.fsys.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening file %s: %w", path, err)
+		}
+		defer file.Close()
+
+		//limit the read till 1MB
+		bs, err := io.ReadAll(io.LimitReader(file, 1024*1024))
+		if err != nil {
+			return fmt.Errorf("reading key file %s: %w", path, err)
+		}
+		keys[kid] = bs
+		return nil
+	})
+
+	if err != nil {
+		return nil, "", fmt.Errorf("walkdir: %w", err)
+	}
+
+	//find the active key
+	activeKID, err := b.fsys.Open("active.txt")
+	if err != nil {
+		return nil, "", fmt.Errorf("opening active kid file: %w", err)
+	}
+	defer activeKID.Close()
+
+	kid, err := io.ReadAll(activeKID)
+	if err != nil {
+		return nil, "", fmt.Errorf("readAll: %w", err)
+	}
+
+	return keys, strings.TrimSpace(string(kid)), nil
+}
+
+// VaultBackend reads the keys from a Vault compatible KV version 2 secret. Every field of the
+// secret is a kid holding a PEM encoded private key, except "active" which holds the active kid.
+type VaultBackend struct {
+	addr   string
+	token  string
+	mount  string
+	path   string
+	client *http.Client
+}
+
+// NewVaultBackend creates a backend reading the secret at path of the KV engine mounted at mount.
+func NewVaultBackend(addr string, token string, mount string, path string) *VaultBackend {
+	return &VaultBackend{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		mount:  strings.Trim(mount, "/"),
+		path:   strings.Trim(path, "/"),
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+const vaultActiveField = "active"
+
+func (b *VaultBackend) Keys(ctx context.Context) (map[string][]byte, string, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", b.addr, b.mount, b.path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", b.token)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching secret: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching secret: unexpected status %d", resp.StatusCode)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&secret); err != nil {
+		return nil, "", fmt.Errorf("decoding secret: %w", err)
+	}
+
+	active, ok := secret.Data.Data[vaultActiveField]
+	if !ok {
+		return nil, "", errors.New("secret has no active field")
+	}
+
+	keys := make(map[string][]byte, len(secret.Data.Data))
+	for kid, value := range secret.Data.Data {
+		if kid == vaultActiveField {
+			continue
+		}
+		keys[kid] = []byte(value)
+	}
+
+	return keys, strings.TrimSpace(active), nil
+}
+
+// ReadPassphrase returns value when it is set, otherwise the content of file. Both empty means
+// the keys are not encrypted.
+func ReadPassphrase(value string, file string) ([]byte, error) {
+	if value != "" {
+		return []byte(value), nil
+	}
+
+	if file == "" {
+		return nil, nil
+	}
+
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading passphrase file: %w", err)
+	}
+
+	return []byte(strings.TrimRight(string(bs), "\r\n")), nil
+}
@@ -1,99 +1,238 @@
 package keystore
 
 import (
-	"crypto/rsa"
+	"context"
+	"crypto"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io"
 	"io/fs"
-	"path/filepath"
-	"strings"
+	"log/slog"
+	"sync"
+	"time"
 )
 
 var (
 	ErrNotFound = errors.New("private key not found")
 )
 
+// KeyStore holds the signing keys found in a keys directory. It is safe for concurrent use, the
+// directory can be reloaded while tokens are being signed and verified.
+//
+// Keys removed from the directory are retired: they can not sign anymore but their public key
+// keeps verifying tokens until the grace period ends. The previous active key is retired the same
+// way when the active kid changes, even if it stays in the directory.
 type KeyStore struct {
-	store map[string]*rsa.PrivateKey
+	grace      time.Duration
+	passphrase []byte
+
+	mu      sync.RWMutex
+	backend Backend
+	store   map[string]crypto.Signer
+	retired map[string]retiredKey
+	active  string
+	parsed  map[[sha256.Size]byte]crypto.Signer //keys by the hash of their pem, so reloads skip the KDF.
+}
+
+type retiredKey struct {
+	key        crypto.PublicKey
+	retiredAt  time.Time
+	superseded bool //retired because another kid became active, the key may still be in the backend.
+}
+
+// Config represents the optional settings of a KeyStore.
+type Config struct {
+	GracePeriod time.Duration //how long retired public keys keep verifying, should outlive the tokens.
+	Passphrase  []byte        //decrypts ENCRYPTED PRIVATE KEY blocks.
 }
 
+// New creates a KeyStore that drops retired keys right away.
 func New() *KeyStore {
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig creates a KeyStore with the given settings.
+func NewWithConfig(cfg Config) *KeyStore {
 	return &KeyStore{
-		store: make(map[string]*rsa.PrivateKey),
+		grace:      cfg.GracePeriod,
+		passphrase: cfg.Passphrase,
+		store:      make(map[string]crypto.Signer),
+		retired:    make(map[string]retiredKey),
+		parsed:     make(map[[sha256.Size]byte]crypto.Signer),
 	}
 }
 
+// LoadKeys loads every private key of the fsys and returns the kid stored in active.txt. The
+// fsys is remembered for later calls to Reload.
 func (ks *KeyStore) LoadKeys(fsys fs.FS) (string, error) {
+	return ks.Load(context.Background(), NewFSBackend(fsys))
+}
 
-	//Example: c3550713-13e7-4a53-977a-dd53cbcb7088-private.pem
-	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return fmt.Errorf("trying to open %s: %w", path, err)
-		}
+// Load loads every private key of the backend and returns the active kid. The backend is
+// remembered for later calls to Reload.
+func (ks *KeyStore) Load(ctx context.Context, backend Backend) (string, error) {
+	ks.mu.Lock()
+	ks.backend = backend
+	ks.mu.Unlock()
 
-		//skip dirs
-		if d.IsDir() {
-			return nil
-		}
+	if _, err := ks.reload(ctx); err != nil {
+		return "", err
+	}
 
-		//skip non-pem files
-		if filepath.Ext(path) != ".pem" {
-			return nil
-		}
+	return ks.ActiveKID(), nil
+}
 
-		//skip the public ones
-		if strings.HasSuffix(path, "-public.pem") {
-			return nil
-		}
+// Reload reads the backend again, new keys are added, missing ones are retired and the active
+// kid is switched. The current keys stay in place when the backend returns invalid keys.
+func (ks *KeyStore) Reload(ctx context.Context) error {
+	_, err := ks.reload(ctx)
+	return err
+}
+
+// Watch reloads the keys directory on every interval until ctx is cancelled, a non-positive
+// interval turns the watcher off.
+func (ks *KeyStore) Watch(ctx context.Context, interval time.Duration, log *slog.Logger) {
+	if interval <= 0 {
+		return
+	}
 
-		//get the kid
-		kid := strings.TrimSuffix(d.Name(), "-private.pem")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-		file, err := fsys.Open(path)
-		if err != nil {
-			return fmt.Errorf("opening file %s: %w", path, err)
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := ks.reload(ctx)
+			if err != nil {
+				//do not flood the logs while the directory stays broken.
+				if err.Error() != lastErr {
+					log.Error("keystore reload", "err", err)
+				}
+				lastErr = err.Error()
+				continue
+			}
+			lastErr = ""
+
+			if changed {
+				log.Info("keystore reload", "activeKID", ks.ActiveKID())
+			}
 		}
-		defer file.Close()
+	}
+}
 
-		//limit the read till 1MB
-		bs, err := io.ReadAll(io.LimitReader(file, 1024*1024))
-		if err != nil {
-			// 	return fmt.Errorf("reading key file %s: %w", path, err)
+func (ks *KeyStore) reload(ctx context.Context) (bool, error) {
+	ks.mu.RLock()
+	backend := ks.backend
+	cache := ks.parsed
+	ks.mu.RUnlock()
+
+	if backend == nil {
+		return false, errors.New("no keys backend loaded")
+	}
+
+	pems, active, err := backend.Keys(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	//only keys whose content changed are parsed again, decrypting runs PBKDF2.
+	keys := make(map[string]crypto.Signer, len(pems))
+	parsed := make(map[[sha256.Size]byte]crypto.Signer, len(pems))
+	for kid, bs := range pems {
+		sum := sha256.Sum256(bs)
+		if privateKey, ok := cache[sum]; ok {
+			keys[kid] = privateKey
+			parsed[sum] = privateKey
+			continue
 		}
+
 		block, _ := pem.Decode(bs)
 		if block == nil {
-			return fmt.Errorf("invalid pem data")
+			return false, fmt.Errorf("invalid pem data for kid %s", kid)
 		}
 
-		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		privateKey, err := parsePrivateKey(block, ks.passphrase)
 		if err != nil {
-			return fmt.Errorf("parsing private key: %w", err)
+			return false, fmt.Errorf("parsing private key %s: %w", kid, err)
 		}
-		ks.store[kid] = privateKey
-		return nil
-	})
+		keys[kid] = privateKey
+		parsed[sum] = privateKey
+	}
 
-	if err != nil {
-		return "", fmt.Errorf("walkdir: %w", err)
+	if _, ok := keys[active]; !ok {
+		return false, fmt.Errorf("active kid %q has no private key", active)
 	}
 
-	//find the active key
-	activeKID, err := fsys.Open("active.txt")
-	if err != nil {
-		return "", fmt.Errorf("opening active kid file: %w", err)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := time.Now()
+	changed := active != ks.active
+
+	//retire the keys that are gone from the directory.
+	for kid, key := range ks.store {
+		if _, ok := keys[kid]; !ok {
+			ks.retired[kid] = retiredKey{key: key.Public(), retiredAt: now}
+			changed = true
+		}
 	}
-	kid, err := io.ReadAll(activeKID)
-	if err != nil {
-		return "", fmt.Errorf("readAll: %w", err)
+
+	//the previous active key stops signing and starts its grace period.
+	if prev, ok := ks.store[ks.active]; ok && active != ks.active {
+		if _, exists := keys[ks.active]; exists {
+			ks.retired[ks.active] = retiredKey{key: prev.Public(), retiredAt: now, superseded: true}
+		}
+	}
+
+	for kid := range keys {
+		//superseded keys stay retired while they are in the backend, unless they become active again.
+		if r, ok := ks.retired[kid]; ok && r.superseded && kid != active {
+			delete(keys, kid)
+			continue
+		}
+
+		if _, ok := ks.store[kid]; !ok {
+			changed = true
+		}
+		delete(ks.retired, kid)
 	}
 
-	return string(kid), nil
+	//superseded keys are remembered after their grace period so the backend does not bring them back.
+	for kid, r := range ks.retired {
+		if _, exists := pems[kid]; r.superseded && exists {
+			continue
+		}
+
+		if now.Sub(r.retiredAt) >= ks.grace {
+			delete(ks.retired, kid)
+		}
+	}
+
+	ks.store = keys
+	ks.active = active
+	ks.parsed = parsed
+
+	return changed, nil
+}
+
+// ActiveKID returns the kid of the key that should be used for signing.
+func (ks *KeyStore) ActiveKID() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.active
 }
 
-func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
+// PrivateKey returns the private key of kid, retired keys can not be used for signing.
+func (ks *KeyStore) PrivateKey(kid string) (crypto.Signer, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
 	key, ok := ks.store[kid]
 	if !ok {
 		return nil, ErrNotFound
@@ -102,11 +241,67 @@ func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
 	return key, nil
 }
 
-func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
-	key, ok := ks.store[kid]
-	if !ok {
-		return nil, ErrNotFound
+// PublicKey returns the public key of kid, including retired keys within the grace period.
+func (ks *KeyStore) PublicKey(kid string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if key, ok := ks.store[kid]; ok {
+		return key.Public(), nil
+	}
+
+	if r, ok := ks.retired[kid]; ok && time.Since(r.retiredAt) < ks.grace {
+		return r.key, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// PublicKeys returns the public key of every key in the store, keyed by kid. Retired keys are
+// included until their grace period ends.
+func (ks *KeyStore) PublicKeys() map[string]crypto.PublicKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make(map[string]crypto.PublicKey, len(ks.store)+len(ks.retired))
+	for kid, r := range ks.retired {
+		if time.Since(r.retiredAt) < ks.grace {
+			keys[kid] = r.key
+		}
 	}
 
-	return &key.PublicKey, nil
+	for kid, key := range ks.store {
+		keys[kid] = key.Public()
+	}
+	return keys
+}
+
+// parsePrivateKey parses PKCS1 RSA, SEC1 EC and plain or encrypted PKCS8 (RSA, ECDSA, Ed25519)
+// private keys.
+func parsePrivateKey(block *pem.Block, passphrase []byte) (crypto.Signer, error) {
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		der, err := decryptPKCS8(block.Bytes, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return parsePrivateKey(&pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type: %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported pem block type: %q", block.Type)
+	}
 }
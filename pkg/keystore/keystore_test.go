@@ -1,8 +1,24 @@
 package keystore_test
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hamidoujand/sales/pkg/keystore"
@@ -69,4 +85,365 @@ func TestKeyStore(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to get public key: %s", err)
 	}
+
+	if _, ok := ks.PublicKeys()[kid]; !ok {
+		t.Errorf("expected public keys to contain kid %s", kid)
+	}
+}
+
+func TestKeyStorePKCS8(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %s", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %s", err)
+	}
+
+	tests := map[string]crypto.Signer{
+		"ecdsa":   ecKey,
+		"ed25519": edKey,
+	}
+
+	for name, key := range tests {
+		t.Run(name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			if err != nil {
+				t.Fatalf("marshaling key: %s", err)
+			}
+
+			kid := uuid.NewString()
+			fs := fstest.MapFS{
+				kid + "-private.pem": &fstest.MapFile{Data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})},
+				"active.txt":         &fstest.MapFile{Data: []byte(kid)},
+			}
+
+			ks := keystore.New()
+			if _, err := ks.LoadKeys(fs); err != nil {
+				t.Fatalf("failed to load files: %s", err)
+			}
+
+			public, err := ks.PublicKey(kid)
+			if err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+
+			type equaler interface {
+				Equal(crypto.PublicKey) bool
+			}
+			if !public.(equaler).Equal(key.Public()) {
+				t.Errorf("expected public key to match the generated key")
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	first, second := uuid.NewString(), uuid.NewString()
+
+	fs := fstest.MapFS{
+		first + "-private.pem": &fstest.MapFile{Data: newPEM(t)},
+		"active.txt":           &fstest.MapFile{Data: []byte(first + "\n")},
+	}
+
+	grace := time.Millisecond * 200
+	ks := keystore.NewWithConfig(keystore.Config{GracePeriod: grace})
+	activeKID, err := ks.LoadKeys(fs)
+	if err != nil {
+		t.Fatalf("failed to load files: %s", err)
+	}
+
+	if activeKID != first {
+		t.Errorf("activeKID=%s, got %s", first, activeKID)
+	}
+
+	//an active kid without a key must not replace the current state.
+	fs["active.txt"] = &fstest.MapFile{Data: []byte(second)}
+	if err := ks.Reload(context.Background()); err == nil {
+		t.Fatal("expected reload to fail when the active key is missing")
+	}
+
+	if ks.ActiveKID() != first {
+		t.Errorf("activeKID=%s, got %s", first, ks.ActiveKID())
+	}
+
+	//rotate: add the new key and retire the old one.
+	fs[second+"-private.pem"] = &fstest.MapFile{Data: newPEM(t)}
+	delete(fs, first+"-private.pem")
+
+	if err := ks.Reload(context.Background()); err != nil {
+		t.Fatalf("failed to reload: %s", err)
+	}
+
+	if ks.ActiveKID() != second {
+		t.Errorf("activeKID=%s, got %s", second, ks.ActiveKID())
+	}
+
+	if _, err := ks.PrivateKey(first); !errors.Is(err, keystore.ErrNotFound) {
+		t.Errorf("expected retired key to not sign, got %v", err)
+	}
+
+	if _, err := ks.PublicKey(first); err != nil {
+		t.Errorf("expected retired key to verify within the grace period: %s", err)
+	}
+
+	if len(ks.PublicKeys()) != 2 {
+		t.Errorf("public keys=%d, got %d", 2, len(ks.PublicKeys()))
+	}
+
+	time.Sleep(grace)
+
+	if _, err := ks.PublicKey(first); !errors.Is(err, keystore.ErrNotFound) {
+		t.Errorf("expected retired key to be dropped after the grace period, got %v", err)
+	}
+
+	if len(ks.PublicKeys()) != 1 {
+		t.Errorf("public keys=%d, got %d", 1, len(ks.PublicKeys()))
+	}
+}
+
+func TestActiveSwitch(t *testing.T) {
+	first, second := uuid.NewString(), uuid.NewString()
+
+	fs := fstest.MapFS{
+		first + "-private.pem":  &fstest.MapFile{Data: newPEM(t)},
+		second + "-private.pem": &fstest.MapFile{Data: newPEM(t)},
+		"active.txt":            &fstest.MapFile{Data: []byte(first)},
+	}
+
+	grace := time.Millisecond * 200
+	ks := keystore.NewWithConfig(keystore.Config{GracePeriod: grace})
+	if _, err := ks.LoadKeys(fs); err != nil {
+		t.Fatalf("failed to load files: %s", err)
+	}
+
+	//switch the active kid but keep the previous key in the directory.
+	fs["active.txt"] = &fstest.MapFile{Data: []byte(second)}
+	if err := ks.Reload(context.Background()); err != nil {
+		t.Fatalf("failed to reload: %s", err)
+	}
+
+	if _, err := ks.PrivateKey(first); !errors.Is(err, keystore.ErrNotFound) {
+		t.Errorf("expected the previous active key to not sign, got %v", err)
+	}
+
+	if _, err := ks.PublicKey(first); err != nil {
+		t.Errorf("expected the previous active key to verify within the grace period: %s", err)
+	}
+
+	time.Sleep(grace)
+	if err := ks.Reload(context.Background()); err != nil {
+		t.Fatalf("failed to reload: %s", err)
+	}
+
+	if _, err := ks.PublicKey(first); !errors.Is(err, keystore.ErrNotFound) {
+		t.Errorf("expected the previous active key to be dropped after the grace period, got %v", err)
+	}
+
+	if len(ks.PublicKeys()) != 1 {
+		t.Errorf("public keys=%d, got %d", 1, len(ks.PublicKeys()))
+	}
+
+	//switching back makes the key active again.
+	fs["active.txt"] = &fstest.MapFile{Data: []byte(first)}
+	if err := ks.Reload(context.Background()); err != nil {
+		t.Fatalf("failed to reload: %s", err)
+	}
+
+	if _, err := ks.PrivateKey(first); err != nil {
+		t.Errorf("expected the reactivated key to sign: %s", err)
+	}
+}
+
+func TestWatchDisabled(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		keystore.New().Watch(context.Background(), 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the watcher to return when the interval is zero")
+	}
+}
+
+func TestConcurrentReload(t *testing.T) {
+	kid := uuid.NewString()
+	fs := fstest.MapFS{
+		kid + "-private.pem": &fstest.MapFile{Data: newPEM(t)},
+		"active.txt":         &fstest.MapFile{Data: []byte(kid)},
+	}
+
+	ks := keystore.NewWithConfig(keystore.Config{GracePeriod: time.Minute})
+	if _, err := ks.LoadKeys(fs); err != nil {
+		t.Fatalf("failed to load files: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := ks.Reload(context.Background()); err != nil {
+				t.Errorf("failed to reload: %s", err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			if _, err := ks.PrivateKey(ks.ActiveKID()); err != nil {
+				t.Errorf("failed to get private key: %s", err)
+			}
+			_ = ks.PublicKeys()
+		}()
+	}
+	wg.Wait()
+}
+
+func newPEM(t *testing.T) []byte {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %s", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling key: %s", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestEncryptedKeys(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %s", err)
+	}
+
+	passphrase := []byte("correct horse battery staple")
+	block, err := keystore.EncryptPEM(key, passphrase)
+	if err != nil {
+		t.Fatalf("encrypting key: %s", err)
+	}
+
+	kid := uuid.NewString()
+	fs := fstest.MapFS{
+		kid + "-private.pem": &fstest.MapFile{Data: pem.EncodeToMemory(block)},
+		"active.txt":         &fstest.MapFile{Data: []byte(kid)},
+	}
+
+	tests := map[string]struct {
+		passphrase []byte
+		shouldFail bool
+		err        error
+	}{
+		"correct passphrase": {passphrase: passphrase},
+		"wrong passphrase":   {passphrase: []byte("wrong"), shouldFail: true, err: keystore.ErrIncorrectPassphrase},
+		"no passphrase":      {passphrase: nil, shouldFail: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ks := keystore.NewWithConfig(keystore.Config{Passphrase: test.passphrase})
+			_, err := ks.LoadKeys(fs)
+
+			if test.shouldFail {
+				if err == nil {
+					t.Fatal("expected loading to fail")
+				}
+
+				if test.err != nil && !errors.Is(err, test.err) {
+					t.Fatalf("err=%v, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to load files: %s", err)
+			}
+
+			public, err := ks.PublicKey(kid)
+			if err != nil {
+				t.Fatalf("failed to get public key: %s", err)
+			}
+
+			if !key.Public().(ed25519.PublicKey).Equal(public) {
+				t.Error("expected public key to match the generated key")
+			}
+
+			//an unchanged key must not be decrypted again on reload.
+			before, err := ks.PrivateKey(kid)
+			if err != nil {
+				t.Fatalf("failed to get private key: %s", err)
+			}
+
+			if err := ks.Reload(context.Background()); err != nil {
+				t.Fatalf("failed to reload: %s", err)
+			}
+
+			after, err := ks.PrivateKey(kid)
+			if err != nil {
+				t.Fatalf("failed to get private key: %s", err)
+			}
+
+			if &before.(ed25519.PrivateKey)[0] != &after.(ed25519.PrivateKey)[0] {
+				t.Error("expected the parsed key to be reused")
+			}
+		})
+	}
+}
+
+func TestVaultBackend(t *testing.T) {
+	kid := uuid.NewString()
+	token := "s.token"
+
+	//stub of the KV v2 read endpoint.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if r.URL.Path != "/v1/secret/data/sales/keys" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		resp := map[string]any{
+			"data": map[string]any{
+				"data": map[string]string{
+					"active": kid,
+					kid:      privatePEM,
+				},
+				"metadata": map[string]any{"version": 1},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	ks := keystore.New()
+	activeKID, err := ks.Load(context.Background(), keystore.NewVaultBackend(server.URL, token, "secret", "sales/keys"))
+	if err != nil {
+		t.Fatalf("failed to load keys: %s", err)
+	}
+
+	if activeKID != kid {
+		t.Errorf("activeKID=%s, got %s", kid, activeKID)
+	}
+
+	if _, err := ks.PrivateKey(kid); err != nil {
+		t.Fatalf("failed to get private key: %s", err)
+	}
+
+	forbidden := keystore.New()
+	if _, err := forbidden.Load(context.Background(), keystore.NewVaultBackend(server.URL, "wrong", "secret", "sales/keys")); err == nil {
+		t.Fatal("expected loading with a wrong token to fail")
+	}
 }